@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"temporal-poc/delivery"
+	"temporal-poc/workflows"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// Server exposes starting and tracking workflows over HTTP, so other services don't need a Temporal client.
+type Server struct {
+	client     client.Client
+	deliveries deliveryGetter
+	mux        *http.ServeMux
+}
+
+type ServerOptions struct {
+	// BobWebhookSecret enables POST /webhooks/bob.
+	BobWebhookSecret string
+	// Deliveries enable GET /companies/{companyID}/payslips/{payslipID}/delivery. Worker has to record them in the same
+	// place.
+	Deliveries deliveryGetter
+}
+
+// deliveryGetter is implemented by delivery.FileStore.
+type deliveryGetter interface {
+	Get(ctx context.Context, ref delivery.Ref) (delivery.Delivery, error)
+}
+
+func NewServer(c client.Client, opts ServerOptions) *Server {
+	s := &Server{client: c, deliveries: opts.Deliveries, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /payrolls/{payrollID}/process", s.processPayroll)
+	s.mux.HandleFunc("GET /payrolls/{payrollID}", s.getPayroll)
+	s.mux.HandleFunc("POST /companies/{companyID}/payslips/{payslipID}/push", s.pushPayDetails)
+	s.mux.HandleFunc("GET /companies/{companyID}/payslips/{payslipID}/push", s.getPayDetailsPush)
+	s.mux.HandleFunc("POST /companies/{companyID}/payslips/{payslipID}/batch", s.batchPayDetails)
+	s.mux.HandleFunc("GET /companies/{companyID}/pay-details-batch", s.getPayDetailsBatch)
+	if opts.Deliveries != nil {
+		s.mux.HandleFunc("GET /companies/{companyID}/payslips/{payslipID}/delivery", s.getPayDetailsDelivery)
+	}
+	if opts.BobWebhookSecret != "" {
+		s.mux.Handle("POST /webhooks/bob", NewBobWebhook(c, opts.BobWebhookSecret))
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type Execution struct {
+	WorkflowID string     `json:"workflow_id"`
+	RunID      string     `json:"run_id"`
+	Status     string     `json:"status,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	ClosedAt   *time.Time `json:"closed_at,omitempty"`
+}
+
+type Payroll struct {
+	Execution
+	// Stage is read from the workflow itself. It's empty if no worker could answer the query.
+	Stage        workflows.PayrollStage       `json:"stage,omitempty"`
+	FPSReference workflows.FPSReportReference `json:"fps_reference,omitempty"`
+}
+
+type errorResponse struct {
+	Error string     `json:"error"`
+	Run   *Execution `json:"run,omitempty"`
+}
+
+func (s *Server) processPayroll(w http.ResponseWriter, r *http.Request) {
+	payrollID := r.PathValue("payrollID")
+	options := workflows.ProcessPayrollOptions(payrollID)
+	s.start(w, r, options, workflows.ProcessPayroll, payrollID)
+}
+
+func (s *Server) getPayroll(w http.ResponseWriter, r *http.Request) {
+	execution, ok := s.describe(w, r, workflows.ProcessPayrollWorkflowID(r.PathValue("payrollID")))
+	if !ok {
+		return
+	}
+
+	payroll := Payroll{Execution: execution}
+	value, err := s.client.QueryWorkflow(r.Context(), execution.WorkflowID, execution.RunID, workflows.PayrollStatusQuery)
+	if err == nil {
+		var status workflows.PayrollStatus
+		if value.Get(&status) == nil {
+			payroll.Stage = status.Stage
+			payroll.FPSReference = status.FPSReference
+		}
+	} else {
+		slog.Warn("Unable to query payroll", "WorkflowID", execution.WorkflowID, "Error", err)
+	}
+	writeJSON(w, http.StatusOK, payroll)
+}
+
+func (s *Server) pushPayDetails(w http.ResponseWriter, r *http.Request) {
+	input := workflows.PushPayDetailsInput{
+		CompanyID: r.PathValue("companyID"),
+		PayslipID: r.PathValue("payslipID"),
+	}
+	s.start(w, r, workflows.PushPayDetailsOptions(input), workflows.PushPayDetails, input)
+}
+
+func (s *Server) getPayDetailsPush(w http.ResponseWriter, r *http.Request) {
+	input := workflows.PushPayDetailsInput{
+		CompanyID: r.PathValue("companyID"),
+		PayslipID: r.PathValue("payslipID"),
+	}
+	execution, ok := s.describe(w, r, workflows.PushPayDetailsWorkflowID(input))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, execution)
+}
+
+// batchPayDetails queues payslip for the next batch of its company, instead of pushing it on its own.
+func (s *Server) batchPayDetails(w http.ResponseWriter, r *http.Request) {
+	ref := workflows.PayDetailsRef{
+		CompanyID: r.PathValue("companyID"),
+		PayslipID: r.PathValue("payslipID"),
+	}
+	run, err := s.client.SignalWithStartWorkflow(r.Context(), workflows.PushCompanyPayDetailsWorkflowID(ref.CompanyID),
+		workflows.PayslipReadySignal, ref, workflows.PushCompanyPayDetailsOptions(ref.CompanyID),
+		workflows.PushCompanyPayDetails, workflows.PushCompanyPayDetailsInput{CompanyID: ref.CompanyID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, Execution{WorkflowID: run.GetID(), RunID: run.GetRunID()})
+}
+
+// getPayDetailsBatch describes the batching workflow of the company. What happened to a single payslip is in its
+// delivery.
+func (s *Server) getPayDetailsBatch(w http.ResponseWriter, r *http.Request) {
+	execution, ok := s.describe(w, r, workflows.PushCompanyPayDetailsWorkflowID(r.PathValue("companyID")))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, execution)
+}
+
+// getPayDetailsDelivery shows what happened to pay details of a payslip, however they were pushed.
+func (s *Server) getPayDetailsDelivery(w http.ResponseWriter, r *http.Request) {
+	ref := delivery.Ref{CompanyID: r.PathValue("companyID"), PayslipID: r.PathValue("payslipID")}
+	d, err := s.deliveries.Get(r.Context(), ref)
+	switch {
+	case errors.Is(err, delivery.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	case errors.Is(err, delivery.ErrInvalidRef):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case err != nil:
+		slog.Error("Unable to read delivery", "CompanyID", ref.CompanyID, "PayslipID", ref.PayslipID, "Error", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "unable to read delivery"})
+	default:
+		writeJSON(w, http.StatusOK, d)
+	}
+}
+
+// start responds with 202 when workflow was started, and 409 when ID reuse policy of the workflow doesn't allow it.
+func (s *Server) start(w http.ResponseWriter, r *http.Request, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) {
+	// Otherwise, SDK would quietly return the run that is already in progress.
+	options.WorkflowExecutionErrorWhenAlreadyStarted = true
+
+	run, err := s.client.ExecuteWorkflow(r.Context(), options, workflow, args...)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	switch {
+	case errors.As(err, &alreadyStarted):
+		writeJSON(w, http.StatusConflict, errorResponse{
+			Error: "workflow already started, or ID reuse policy does not allow another run",
+			Run:   &Execution{WorkflowID: options.ID, RunID: alreadyStarted.RunId},
+		})
+	case err != nil:
+		writeError(w, err)
+	default:
+		writeJSON(w, http.StatusAccepted, Execution{WorkflowID: run.GetID(), RunID: run.GetRunID()})
+	}
+}
+
+func (s *Server) describe(w http.ResponseWriter, r *http.Request, workflowID string) (Execution, bool) {
+	resp, err := s.client.DescribeWorkflowExecution(r.Context(), workflowID, "")
+	if err != nil {
+		writeError(w, err)
+		return Execution{}, false
+	}
+
+	info := resp.GetWorkflowExecutionInfo()
+	execution := Execution{
+		WorkflowID: info.GetExecution().GetWorkflowId(),
+		RunID:      info.GetExecution().GetRunId(),
+		Status:     info.GetStatus().String(),
+	}
+	if info.GetStartTime() != nil {
+		startedAt := info.GetStartTime().AsTime()
+		execution.StartedAt = &startedAt
+	}
+	if info.GetCloseTime() != nil {
+		closedAt := info.GetCloseTime().AsTime()
+		execution.ClosedAt = &closedAt
+	}
+	return execution, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var notFound *serviceerror.NotFound
+	var invalid *serviceerror.InvalidArgument
+	switch {
+	case errors.As(err, &notFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found"})
+	case errors.As(err, &invalid):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		writeJSON(w, http.StatusGatewayTimeout, errorResponse{Error: "timed out talking to Temporal"})
+	default:
+		slog.Error("Temporal request failed", "Error", err)
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: "temporal request failed"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("Unable to write response", "Error", err)
+	}
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"temporal-poc/delivery"
+	"temporal-poc/workflows"
+
+	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+
+	temporal   *fakeTemporal
+	deliveries *delivery.MemoryStore
+	server     *Server
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	s.temporal = &fakeTemporal{}
+	s.deliveries = delivery.NewMemoryStore()
+	s.server = NewServer(s.temporal, ServerOptions{Deliveries: s.deliveries})
+}
+
+func (s *ServerTestSuite) get(path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func (s *ServerTestSuite) post(path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	return rec
+}
+
+var startedAt = time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+
+// runningPayroll is how Temporal describes payroll-1 while it's running.
+var runningPayroll = &workflowservice.DescribeWorkflowExecutionResponse{
+	WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+		Execution: &commonpb.WorkflowExecution{WorkflowId: "process-payroll-payroll-1", RunId: "run-1"},
+		Status:    enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+		StartTime: timestamppb.New(startedAt),
+	},
+}
+
+func (s *ServerTestSuite) Test_StartsPayroll() {
+	rec := s.post("/payrolls/payroll-1/process")
+
+	s.Equal(http.StatusAccepted, rec.Code)
+	s.Require().Len(s.temporal.started, 1)
+	s.Equal(workflows.ProcessPayrollWorkflowID("payroll-1"), s.temporal.started[0].ID)
+	s.True(s.temporal.started[0].WorkflowExecutionErrorWhenAlreadyStarted)
+	var execution Execution
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&execution))
+	s.Equal(Execution{WorkflowID: workflows.ProcessPayrollWorkflowID("payroll-1"), RunID: "run-1"}, execution)
+}
+
+func (s *ServerTestSuite) Test_ReturnsConflict_WhenPayrollIsAlreadyStarted() {
+	s.temporal.startErr = serviceerror.NewWorkflowExecutionAlreadyStarted("already started", "", "run-0")
+
+	rec := s.post("/payrolls/payroll-1/process")
+
+	s.Equal(http.StatusConflict, rec.Code)
+	var response errorResponse
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&response))
+	s.Equal(&Execution{WorkflowID: workflows.ProcessPayrollWorkflowID("payroll-1"), RunID: "run-0"}, response.Run)
+}
+
+func (s *ServerTestSuite) Test_MapsTemporalErrors() {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "not found", err: serviceerror.NewNotFound("workflow not found"), status: http.StatusNotFound},
+		{name: "invalid argument", err: serviceerror.NewInvalidArgument("bad workflow ID"), status: http.StatusBadRequest},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, status: http.StatusGatewayTimeout},
+		{name: "anything else", err: errors.New("connection refused"), status: http.StatusBadGateway},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.temporal.describeErr = test.err
+
+			rec := s.get("/payrolls/payroll-1")
+
+			s.Equal(test.status, rec.Code)
+			s.Equal("application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func (s *ServerTestSuite) Test_ShowsPayrollStage() {
+	s.temporal.description = runningPayroll
+	s.temporal.query = workflows.PayrollStatus{Stage: workflows.PayrollStageAwaitingHMRC, FPSReference: "fps-1"}
+
+	rec := s.get("/payrolls/payroll-1")
+
+	s.Equal(http.StatusOK, rec.Code)
+	var payroll Payroll
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&payroll))
+	s.Equal("Running", payroll.Status)
+	s.Equal(startedAt, *payroll.StartedAt)
+	s.Nil(payroll.ClosedAt)
+	s.Equal(workflows.PayrollStageAwaitingHMRC, payroll.Stage)
+	s.Equal(workflows.FPSReportReference("fps-1"), payroll.FPSReference)
+}
+
+func (s *ServerTestSuite) Test_ShowsPayrollWithoutStage_WhenQueryFails() {
+	s.temporal.description = runningPayroll
+	s.temporal.queryErr = errors.New("no worker polls the task queue")
+
+	rec := s.get("/payrolls/payroll-1")
+
+	s.Equal(http.StatusOK, rec.Code)
+	var payroll Payroll
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&payroll))
+	s.Equal("run-1", payroll.RunID)
+	s.Empty(payroll.Stage)
+}
+
+func (s *ServerTestSuite) Test_PushesSinglePayslip() {
+	rec := s.post("/companies/company-1/payslips/payslip-1/push")
+
+	s.Equal(http.StatusAccepted, rec.Code)
+	s.Require().Len(s.temporal.started, 1)
+	s.Equal("push-pay-details-company-1-payslip-1", s.temporal.started[0].ID)
+	s.Equal(workflows.TaskQueueDocuments, s.temporal.started[0].TaskQueue)
+	s.Empty(s.temporal.signals)
+}
+
+func (s *ServerTestSuite) Test_QueuesPayslipForBatchOfCompany() {
+	rec := s.post("/companies/company-1/payslips/payslip-1/batch")
+
+	s.Equal(http.StatusAccepted, rec.Code)
+	s.Require().Len(s.temporal.signals, 1)
+	signal := s.temporal.signals[0]
+	s.Equal("push-company-pay-details-company-1", signal.workflowID)
+	s.Equal(workflows.PayslipReadySignal, signal.name)
+	s.Equal(workflows.PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-1"}, signal.arg)
+	s.Equal(workflows.PushCompanyPayDetailsInput{CompanyID: "company-1"}, signal.input)
+	s.Empty(s.temporal.started)
+}
+
+func (s *ServerTestSuite) Test_ShowsDelivery() {
+	sentAt := time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+	ref := delivery.Ref{CompanyID: "company-1", PayslipID: "payslip-1"}
+	s.Require().NoError(s.deliveries.Record(context.Background(), ref, delivery.Transition{State: delivery.StateSent, At: sentAt}))
+
+	rec := s.get("/companies/company-1/payslips/payslip-1/delivery")
+
+	s.Equal(http.StatusOK, rec.Code)
+	var d delivery.Delivery
+	s.Require().NoError(json.NewDecoder(rec.Body).Decode(&d))
+	s.Equal(delivery.StateSent, d.State)
+	s.Equal(sentAt, d.UpdatedAt)
+}
+
+func (s *ServerTestSuite) Test_ReturnsNotFound_WhenNothingWasRecorded() {
+	rec := s.get("/companies/company-1/payslips/payslip-2/delivery")
+
+	s.Equal(http.StatusNotFound, rec.Code)
+}
+
+// fakeTemporal records workflows started and signaled, and answers describe and query with what it was given.
+// Anything else it's asked panics.
+type fakeTemporal struct {
+	client.Client
+	fakeClient
+	started     []client.StartWorkflowOptions
+	startErr    error
+	description *workflowservice.DescribeWorkflowExecutionResponse
+	describeErr error
+	query       interface{}
+	queryErr    error
+}
+
+func (c *fakeTemporal) ExecuteWorkflow(_ context.Context, options client.StartWorkflowOptions, _ interface{},
+	_ ...interface{}) (client.WorkflowRun, error) {
+	if c.startErr != nil {
+		return nil, c.startErr
+	}
+	c.started = append(c.started, options)
+	return fakeRun{id: options.ID}, nil
+}
+
+func (c *fakeTemporal) SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+	options client.StartWorkflowOptions, workflow interface{}, workflowArgs ...interface{}) (client.WorkflowRun, error) {
+	return c.fakeClient.SignalWithStartWorkflow(ctx, workflowID, signalName, signalArg, options, workflow, workflowArgs...)
+}
+
+func (c *fakeTemporal) DescribeWorkflowExecution(context.Context, string, string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	return c.description, c.describeErr
+}
+
+func (c *fakeTemporal) QueryWorkflow(context.Context, string, string, string, ...interface{}) (converter.EncodedValue, error) {
+	if c.queryErr != nil {
+		return nil, c.queryErr
+	}
+	return fakeValue{value: c.query}, nil
+}
+
+type fakeRun struct {
+	client.WorkflowRun
+	id string
+}
+
+func (r fakeRun) GetID() string    { return r.id }
+func (r fakeRun) GetRunID() string { return "run-1" }
+
+// fakeValue is a query result that was never encoded.
+type fakeValue struct {
+	value interface{}
+}
+
+func (v fakeValue) HasValue() bool {
+	return v.value != nil
+}
+
+func (v fakeValue) Get(valuePtr interface{}) error {
+	reflect.ValueOf(valuePtr).Elem().Set(reflect.ValueOf(v.value))
+	return nil
+}
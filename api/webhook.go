@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/workflows"
+
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	// maxWebhookBody is far more than any Bob event needs.
+	maxWebhookBody = 1 << 20
+	// deliveryTTL covers Bob's retries of deliveries we failed to acknowledge.
+	deliveryTTL = 24 * time.Hour
+)
+
+// signalWithStarter is the part of client.Client webhook needs.
+type signalWithStarter interface {
+	SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+		options client.StartWorkflowOptions, workflow interface{}, workflowArgs ...interface{}) (client.WorkflowRun, error)
+}
+
+// BobWebhook passes employee changes from Bob to SyncCompanyFromBob of their company, starting it if needed.
+type BobWebhook struct {
+	client     signalWithStarter
+	secret     []byte
+	deliveries *deliveries
+}
+
+func NewBobWebhook(c signalWithStarter, secret string) *BobWebhook {
+	return &BobWebhook{
+		client:     c,
+		secret:     []byte(secret),
+		deliveries: &deliveries{ttl: deliveryTTL, now: time.Now, seen: map[string]time.Time{}},
+	}
+}
+
+// ServeHTTP responds with 202 when change was passed on, and 204 to events that are ignored or were already passed
+// on. Anything else makes Bob retry.
+func (h *BobWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "unable to read body"})
+		return
+	}
+	if !bob.VerifySignature(h.secret, body, r.Header.Get(bob.SignatureHeader)) {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid signature"})
+		return
+	}
+	var event bob.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.CompanyID == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid event"})
+		return
+	}
+	if !event.IsEmployeeChange() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Redelivery has the same body, and so the same signature.
+	sum := sha256.Sum256(body)
+	delivery := hex.EncodeToString(sum[:])
+	if !h.deliveries.reserve(delivery) {
+		slog.Info("Ignoring duplicate Bob webhook delivery", "EventType", event.Type)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	companyID := event.CompanyID.String()
+	change := workflows.EmployeeChanged{EmployeeID: event.Data.EmployeeID, ChangedAt: event.TriggeredAt}
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now()
+	}
+	run, err := h.client.SignalWithStartWorkflow(r.Context(), workflows.SyncCompanyFromBobWorkflowID(companyID),
+		workflows.EmployeeChangedSignal, change, workflows.SyncCompanyFromBobOptions(companyID),
+		workflows.SyncCompanyFromBob, workflows.SyncCompanyFromBobInput{CompanyID: companyID})
+	if err != nil {
+		// Bob will retry, it must not look like a duplicate then.
+		h.deliveries.release(delivery)
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, Execution{WorkflowID: run.GetID(), RunID: run.GetRunID()})
+}
+
+// deliveries remembers webhook deliveries already passed on. It's per process, so a duplicate reaching another
+// replica gets through. That's fine, sync coalesces it with the original.
+type deliveries struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// reserve returns false if delivery was seen within ttl.
+func (d *deliveries) reserve(delivery string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	if now.Sub(d.lastPrune) > time.Minute {
+		for key, seenAt := range d.seen {
+			if now.Sub(seenAt) > d.ttl {
+				delete(d.seen, key)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	if seenAt, ok := d.seen[delivery]; ok && now.Sub(seenAt) <= d.ttl {
+		return false
+	}
+	d.seen[delivery] = now
+	return true
+}
+
+func (d *deliveries) release(delivery string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, delivery)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/workflows"
+
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/client"
+)
+
+type BobWebhookTestSuite struct {
+	suite.Suite
+
+	client  *fakeClient
+	webhook *BobWebhook
+}
+
+func TestBobWebhook(t *testing.T) {
+	suite.Run(t, new(BobWebhookTestSuite))
+}
+
+const (
+	secret        = "webhook-secret"
+	employeeEvent = `{"companyId": 42, "type": "employee.updated", "triggeredAt": "2024-04-05T12:00:00Z", "data": {"employeeId": "employee-1"}}`
+)
+
+func (s *BobWebhookTestSuite) SetupTest() {
+	s.client = &fakeClient{}
+	s.webhook = NewBobWebhook(s.client, secret)
+}
+
+func (s *BobWebhookTestSuite) post(body, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/bob", strings.NewReader(body))
+	req.Header.Set(bob.SignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	s.webhook.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *BobWebhookTestSuite) Test_SignalsSyncOfCompany() {
+	rec := s.post(employeeEvent, bob.Sign([]byte(secret), []byte(employeeEvent)))
+
+	s.Equal(http.StatusAccepted, rec.Code)
+	s.Require().Len(s.client.signals, 1)
+	signal := s.client.signals[0]
+	s.Equal("sync-company-from-bob-42", signal.workflowID)
+	s.Equal(workflows.EmployeeChangedSignal, signal.name)
+	s.Equal(workflows.EmployeeChanged{EmployeeID: "employee-1", ChangedAt: time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)}, signal.arg)
+	s.Equal(workflows.SyncCompanyFromBobInput{CompanyID: "42"}, signal.input)
+}
+
+func (s *BobWebhookTestSuite) Test_RejectsInvalidSignature() {
+	rec := s.post(employeeEvent, bob.Sign([]byte("other-secret"), []byte(employeeEvent)))
+
+	s.Equal(http.StatusUnauthorized, rec.Code)
+	s.Empty(s.client.signals)
+}
+
+func (s *BobWebhookTestSuite) Test_IgnoresDuplicateDelivery() {
+	signature := bob.Sign([]byte(secret), []byte(employeeEvent))
+	s.post(employeeEvent, signature)
+
+	rec := s.post(employeeEvent, signature)
+
+	s.Equal(http.StatusNoContent, rec.Code)
+	s.Len(s.client.signals, 1)
+}
+
+func (s *BobWebhookTestSuite) Test_AcceptsRedelivery_WhenSignalFailed() {
+	signature := bob.Sign([]byte(secret), []byte(employeeEvent))
+	s.client.err = errors.New("temporal is down")
+	s.Equal(http.StatusBadGateway, s.post(employeeEvent, signature).Code)
+
+	s.client.err = nil
+	rec := s.post(employeeEvent, signature)
+
+	s.Equal(http.StatusAccepted, rec.Code)
+	s.Len(s.client.signals, 2)
+}
+
+func (s *BobWebhookTestSuite) Test_IgnoresOtherEvents() {
+	body := `{"companyId": 42, "type": "timeoff.request.created", "data": {}}`
+
+	rec := s.post(body, bob.Sign([]byte(secret), []byte(body)))
+
+	s.Equal(http.StatusNoContent, rec.Code)
+	s.Empty(s.client.signals)
+}
+
+func (s *BobWebhookTestSuite) Test_ForgetsDeliveriesAfterTTL() {
+	now := time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+	d := &deliveries{ttl: time.Hour, now: func() time.Time { return now }, seen: map[string]time.Time{}}
+	s.True(d.reserve("delivery-1"))
+	s.False(d.reserve("delivery-1"))
+
+	now = now.Add(2 * time.Hour)
+
+	s.True(d.reserve("delivery-1"))
+}
+
+type signal struct {
+	workflowID string
+	name       string
+	arg        interface{}
+	input      interface{}
+}
+
+// fakeClient records signals, and fails them with err.
+type fakeClient struct {
+	signals []signal
+	err     error
+}
+
+func (c *fakeClient) SignalWithStartWorkflow(_ context.Context, workflowID string, signalName string, signalArg interface{},
+	options client.StartWorkflowOptions, _ interface{}, workflowArgs ...interface{}) (client.WorkflowRun, error) {
+	c.signals = append(c.signals, signal{workflowID: workflowID, name: signalName, arg: signalArg, input: workflowArgs[0]})
+	if c.err != nil {
+		return nil, c.err
+	}
+	return fakeRun{id: options.ID}, nil
+}
@@ -0,0 +1,241 @@
+package bob
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Employee struct {
+	ID string `json:"id"`
+	// CompanyID is a number in Bob, same as in webhooks.
+	CompanyID json.Number `json:"companyId,omitempty"`
+	FirstName string      `json:"firstName"`
+	LastName  string      `json:"surname"`
+	Email     string      `json:"email"`
+	// Salary is yearly, in pennies.
+	Salary      int         `json:"salary"`
+	BankAccount BankAccount `json:"bankAccount"`
+	StartDate   time.Time   `json:"startDate"`
+	// TerminationDate is zero until employee leaves.
+	TerminationDate time.Time `json:"terminationDate"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+type BankAccount struct {
+	SortCode      string `json:"sortCode"`
+	AccountNumber string `json:"accountNumber"`
+}
+
+type EmployeesPage struct {
+	Employees []Employee `json:"employees"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"nextCursor"`
+}
+
+type ListEmployeesRequest struct {
+	// CompanyID lists only employees of that company. Empty lists employees of all companies service user can see.
+	CompanyID string
+	// UpdatedSince filters employees changed at or after that time. Zero lists everyone.
+	UpdatedSince time.Time
+	Cursor       string
+	// Limit of employees per page. Zero leaves it to Bob.
+	Limit int
+}
+
+type PayDetails struct {
+	CompanyID string    `json:"-"`
+	PayslipID string    `json:"-"`
+	Payday    time.Time `json:"payday"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"surname"`
+	Salary    int       `json:"salary"`
+}
+
+// PayslipResult is outcome of a single payslip in a batch. Error is empty when Bob accepted it.
+type PayslipResult struct {
+	PayslipID string `json:"payslipId"`
+	Error     string `json:"error,omitempty"`
+}
+
+type bulkPayslips struct {
+	Payslips []bulkPayslip `json:"payslips"`
+}
+
+type bulkPayslip struct {
+	PayslipID string `json:"payslipId"`
+	PayDetails
+}
+
+type bulkPayslipsResponse struct {
+	Results []PayslipResult `json:"results"`
+}
+
+type Options struct {
+	// URL of the API, e.g. https://api.hibob.com/v1.
+	URL string
+	// ServiceUserID and Token are credentials of Bob service user.
+	ServiceUserID string
+	Token         string
+	// HTTPClient defaults to a client with 30s timeout.
+	HTTPClient *http.Client
+	// RateLimitRetries is how many times a rate limited request is retried, after waiting as long as Bob asks.
+	// Defaults to 3. Negative disables retries.
+	RateLimitRetries int
+	// MaxRetryAfter caps waiting for rate limit. Anything longer is left to activity retries, so activities don't time
+	// out waiting. Defaults to 2s.
+	MaxRetryAfter time.Duration
+}
+
+// Client of Bob API. Errors returned by Bob are *Error, see Error.Retryable.
+type Client struct {
+	opts Options
+}
+
+func NewClient(opts Options) (*Client, error) {
+	if opts.URL == "" || opts.ServiceUserID == "" || opts.Token == "" {
+		return nil, errors.New("bob URL, service user ID and token are required")
+	}
+	if _, err := url.Parse(opts.URL); err != nil {
+		return nil, fmt.Errorf("invalid bob URL: %w", err)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.RateLimitRetries == 0 {
+		opts.RateLimitRetries = 3
+	}
+	if opts.MaxRetryAfter == 0 {
+		opts.MaxRetryAfter = 2 * time.Second
+	}
+	return &Client{opts: opts}, nil
+}
+
+func (c *Client) ListEmployees(ctx context.Context, req ListEmployeesRequest) (EmployeesPage, error) {
+	query := url.Values{}
+	if req.CompanyID != "" {
+		query.Set("companyId", req.CompanyID)
+	}
+	if !req.UpdatedSince.IsZero() {
+		query.Set("updatedSince", req.UpdatedSince.UTC().Format(time.RFC3339Nano))
+	}
+	if req.Cursor != "" {
+		query.Set("cursor", req.Cursor)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+	var page EmployeesPage
+	err := c.do(ctx, http.MethodGet, "/people?"+query.Encode(), nil, &page)
+	return page, err
+}
+
+func (c *Client) PushPayDetails(ctx context.Context, details PayDetails) error {
+	path := fmt.Sprintf("/payroll/companies/%s/payslips/%s", url.PathEscape(details.CompanyID), url.PathEscape(details.PayslipID))
+	return c.do(ctx, http.MethodPut, path, details, nil)
+}
+
+// PushPayDetailsBatch sends pay details of many payslips of a company at once. Bob accepts or rejects each of them on
+// its own, error is returned only if the whole request failed.
+func (c *Client) PushPayDetailsBatch(ctx context.Context, companyID string, batch []PayDetails) ([]PayslipResult, error) {
+	body := bulkPayslips{Payslips: make([]bulkPayslip, len(batch))}
+	for i, details := range batch {
+		body.Payslips[i] = bulkPayslip{PayslipID: details.PayslipID, PayDetails: details}
+	}
+	var resp bulkPayslipsResponse
+	path := fmt.Sprintf("/payroll/companies/%s/payslips", url.PathEscape(companyID))
+	err := c.do(ctx, http.MethodPost, path, body, &resp)
+	return resp.Results, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.opts.URL+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(c.opts.ServiceUserID, c.opts.Token)
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.opts.HTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("calling bob: %w", err)
+		}
+		err = readResponse(resp, result)
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || attempt >= c.opts.RateLimitRetries || apiErr.RetryAfter > c.opts.MaxRetryAfter {
+			return err
+		}
+		select {
+		case <-time.After(apiErr.RetryAfter):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func readResponse(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// Body is only for humans, it's enough to keep the beginning of it.
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &Error{
+			StatusCode: resp.StatusCode,
+			Message:    string(bytes.TrimSpace(message)),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding bob response: %w", err)
+	}
+	return nil
+}
+
+// retryAfter supports only seconds. Bob doesn't send dates.
+func retryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Error is returned when Bob responds with anything but success.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set when rate limited.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("bob responded with %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable tells if the same request can succeed later. Rejected requests (bad credentials, validation, missing
+// employee) will keep failing until someone fixes them.
+func (e *Error) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout || e.StatusCode >= 500
+}
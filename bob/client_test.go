@@ -0,0 +1,130 @@
+package bob
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClientTestSuite struct {
+	suite.Suite
+
+	mux    *http.ServeMux
+	client *Client
+}
+
+func TestClient(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.mux = http.NewServeMux()
+	server := httptest.NewServer(s.mux)
+	s.T().Cleanup(server.Close)
+
+	var err error
+	s.client, err = NewClient(Options{URL: server.URL, ServiceUserID: "user", Token: "secret"})
+	s.Require().NoError(err)
+}
+
+func (s *ClientTestSuite) Test_ListsEmployees() {
+	s.mux.HandleFunc("GET /people", func(w http.ResponseWriter, r *http.Request) {
+		user, token, _ := r.BasicAuth()
+		s.Equal("user", user)
+		s.Equal("secret", token)
+		s.Equal("42", r.URL.Query().Get("companyId"))
+		s.Equal("cursor-1", r.URL.Query().Get("cursor"))
+		s.Equal("50", r.URL.Query().Get("limit"))
+		s.Equal("2024-04-05T12:00:00Z", r.URL.Query().Get("updatedSince"))
+		_ = json.NewEncoder(w).Encode(EmployeesPage{Employees: []Employee{{ID: "employee-2"}}, NextCursor: "cursor-2"})
+	})
+
+	page, err := s.client.ListEmployees(context.Background(), ListEmployeesRequest{
+		CompanyID:    "42",
+		UpdatedSince: time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC),
+		Cursor:       "cursor-1",
+		Limit:        50,
+	})
+
+	s.Require().NoError(err)
+	s.Equal(EmployeesPage{Employees: []Employee{{ID: "employee-2"}}, NextCursor: "cursor-2"}, page)
+}
+
+func (s *ClientTestSuite) Test_RetriesRateLimitedRequests() {
+	calls := 0
+	s.mux.HandleFunc("PUT /payroll/companies/company-1/payslips/payslip-1", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	err := s.client.PushPayDetails(context.Background(), PayDetails{CompanyID: "company-1", PayslipID: "payslip-1"})
+
+	s.NoError(err)
+	s.Equal(2, calls)
+}
+
+func (s *ClientTestSuite) Test_PushesPayDetailsInBatch() {
+	s.mux.HandleFunc("POST /payroll/companies/company-1/payslips", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Payslips []struct {
+				PayslipID string `json:"payslipId"`
+				LastName  string `json:"surname"`
+			} `json:"payslips"`
+		}
+		s.Require().NoError(json.NewDecoder(r.Body).Decode(&body))
+		s.Len(body.Payslips, 2)
+		s.Equal("payslip-2", body.Payslips[1].PayslipID)
+		s.Equal("Doe", body.Payslips[1].LastName)
+		_, _ = w.Write([]byte(`{"results": [{"payslipId": "payslip-1"}, {"payslipId": "payslip-2", "error": "unknown employee"}]}`))
+	})
+
+	results, err := s.client.PushPayDetailsBatch(context.Background(), "company-1", []PayDetails{
+		{CompanyID: "company-1", PayslipID: "payslip-1", LastName: "Smith"},
+		{CompanyID: "company-1", PayslipID: "payslip-2", LastName: "Doe"},
+	})
+
+	s.Require().NoError(err)
+	s.Equal([]PayslipResult{{PayslipID: "payslip-1"}, {PayslipID: "payslip-2", Error: "unknown employee"}}, results)
+}
+
+func (s *ClientTestSuite) Test_GivesUp_WhenRateLimitedForTooLong() {
+	calls := 0
+	s.mux.HandleFunc("GET /people", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// Longer than default cap, which leaves enough of 10s activity timeout for the request itself.
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := s.client.ListEmployees(context.Background(), ListEmployeesRequest{})
+
+	var apiErr *Error
+	s.Require().ErrorAs(err, &apiErr)
+	s.Equal(3*time.Second, apiErr.RetryAfter)
+	s.True(apiErr.Retryable())
+	s.Equal(1, calls)
+}
+
+func (s *ClientTestSuite) Test_ClassifiesErrors() {
+	for status, retryable := range map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+		http.StatusNotFound:            false,
+		http.StatusRequestTimeout:      true,
+		http.StatusInternalServerError: true,
+		http.StatusServiceUnavailable:  true,
+	} {
+		err := &Error{StatusCode: status}
+		s.Equal(retryable, err.Retryable(), "status %d", status)
+	}
+}
@@ -0,0 +1,64 @@
+package bob
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Fake stands in for Bob when it's not configured, so everything can run locally. It pages through its employees
+// like Bob does, and accepts any pay details.
+type Fake struct {
+	Employees []Employee
+	PageSize  int
+}
+
+func NewFake() *Fake {
+	startDate := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Fake{
+		Employees: []Employee{
+			{ID: "employee-1", CompanyID: "1", FirstName: "Joe", LastName: "Smith", Email: "joe@example.com", Salary: 30_000_00, BankAccount: BankAccount{"40-47-84", "70872490"}, StartDate: startDate, UpdatedAt: updatedAt},
+			{ID: "employee-2", CompanyID: "1", FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Salary: 45_000_00, BankAccount: BankAccount{"20-00-00", "55779911"}, StartDate: startDate, UpdatedAt: updatedAt},
+			{ID: "employee-3", CompanyID: "2", FirstName: "John", LastName: "Brown", Email: "john@example.com", Salary: 52_000_00, BankAccount: BankAccount{"30-94-57", "41523688"}, StartDate: startDate, UpdatedAt: updatedAt},
+		},
+		PageSize: 2,
+	}
+}
+
+func (f *Fake) ListEmployees(_ context.Context, req ListEmployeesRequest) (EmployeesPage, error) {
+	var employees []Employee
+	for _, employee := range f.Employees {
+		if req.CompanyID != "" && employee.CompanyID.String() != req.CompanyID {
+			continue
+		}
+		if !employee.UpdatedAt.Before(req.UpdatedSince) {
+			employees = append(employees, employee)
+		}
+	}
+
+	start, _ := strconv.Atoi(req.Cursor)
+	limit := f.PageSize
+	if req.Limit > 0 {
+		limit = req.Limit
+	}
+	start = min(start, len(employees))
+	end := min(start+limit, len(employees))
+	page := EmployeesPage{Employees: employees[start:end]}
+	if end < len(employees) {
+		page.NextCursor = strconv.Itoa(end)
+	}
+	return page, nil
+}
+
+func (f *Fake) PushPayDetails(context.Context, PayDetails) error {
+	return nil
+}
+
+func (f *Fake) PushPayDetailsBatch(_ context.Context, _ string, batch []PayDetails) ([]PayslipResult, error) {
+	results := make([]PayslipResult, len(batch))
+	for i, details := range batch {
+		results[i] = PayslipResult{PayslipID: details.PayslipID}
+	}
+	return results, nil
+}
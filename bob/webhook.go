@@ -0,0 +1,47 @@
+package bob
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries base64 encoded HMAC-SHA512 of webhook body, keyed with secret of the webhook.
+const SignatureHeader = "Bob-Signature"
+
+// WebhookEvent is what Bob posts to webhooks. Only fields we need are decoded.
+type WebhookEvent struct {
+	// CompanyID is a number in Bob. We treat it as an opaque ID, like everywhere else.
+	CompanyID   json.Number `json:"companyId"`
+	Type        string      `json:"type"`
+	TriggeredAt time.Time   `json:"triggeredAt"`
+	Data        struct {
+		EmployeeID string `json:"employeeId"`
+	} `json:"data"`
+}
+
+// IsEmployeeChange tells if event is about an employee (created, updated, left...), and not e.g. a time off request.
+func (e WebhookEvent) IsEmployeeChange() bool {
+	return strings.HasPrefix(e.Type, "employee.") && e.Data.EmployeeID != ""
+}
+
+// Sign is what Bob does to a webhook body. Handy in tests.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares signature in constant time, so it can't be guessed byte by byte.
+func VerifySignature(secret, body []byte, signature string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(body)
+	return hmac.Equal(decoded, mac.Sum(nil))
+}
@@ -0,0 +1,126 @@
+package chaos
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+)
+
+// ErrorType of injected application errors. Workflows should never depend on it, but it makes injected failures easy
+// to tell apart from real ones in the UI.
+const ErrorType = "ChaosError"
+
+// AnyActivity applies to activities without faults of their own.
+const AnyActivity = "*"
+
+// Fault is what happens to a single activity type. Rates are probabilities between 0 and 1, each attempt rolls once:
+// timeout is checked first, then non-retryable error, then error.
+type Fault struct {
+	// Latency is added before every attempt.
+	Latency time.Duration `yaml:"latency"`
+	// TimeoutRate makes attempt hang until its start-to-close timeout.
+	TimeoutRate           float64 `yaml:"timeout_rate"`
+	NonRetryableErrorRate float64 `yaml:"non_retryable_error_rate"`
+	ErrorRate             float64 `yaml:"error_rate"`
+}
+
+// Interceptor injects faults into activities, before they run. Activity code stays free of fake failures, and they
+// can be switched off or tuned without touching it.
+//
+// Faults are reproducible: each attempt gets its own random source, seeded with the seed, workflow ID, activity ID
+// and attempt number. Same workflow run with the same seed fails the same way, regardless of what else the worker
+// is doing.
+type Interceptor struct {
+	interceptor.WorkerInterceptorBase
+	seed   uint64
+	faults map[string]Fault
+}
+
+// NewInterceptor takes faults by activity type. AnyActivity key matches all the others.
+func NewInterceptor(seed uint64, faults map[string]Fault) *Interceptor {
+	return &Interceptor{seed: seed, faults: faults}
+}
+
+// InterceptActivity is all there is. Workflows are never touched, anything random in them would break determinism.
+func (i *Interceptor) InterceptActivity(_ context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	a := &activityInbound{root: i}
+	a.Next = next
+	return a
+}
+
+func (f Fault) Validate() error {
+	if f.Latency < 0 {
+		return errors.New("latency can't be negative")
+	}
+	for _, rate := range []float64{f.TimeoutRate, f.NonRetryableErrorRate, f.ErrorRate} {
+		if rate < 0 || rate > 1 {
+			return errors.New("rates have to be between 0 and 1")
+		}
+	}
+	if f.TimeoutRate+f.NonRetryableErrorRate+f.ErrorRate > 1 {
+		return errors.New("rates can't add up to more than 1")
+	}
+	return nil
+}
+
+// fault returns fault of the activity type, if there is any.
+func (i *Interceptor) fault(activityType string) (Fault, bool) {
+	if fault, ok := i.faults[activityType]; ok {
+		return fault, true
+	}
+	fault, ok := i.faults[AnyActivity]
+	return fault, ok
+}
+
+func (i *Interceptor) random(info activity.Info) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(info.WorkflowExecution.ID))
+	h.Write([]byte{0})
+	h.Write([]byte(info.ActivityID))
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(info.Attempt)))
+	return rand.New(rand.NewPCG(i.seed, h.Sum64()))
+}
+
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	root *Interceptor
+}
+
+func (a *activityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	info := activity.GetInfo(ctx)
+	fault, ok := a.root.fault(info.ActivityType.Name)
+	if !ok {
+		return a.Next.ExecuteActivity(ctx, in)
+	}
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	logger := activity.GetLogger(ctx)
+	roll := a.root.random(info).Float64()
+	switch {
+	case roll < fault.TimeoutRate:
+		logger.Warn("Chaos: hanging until activity times out")
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case roll < fault.TimeoutRate+fault.NonRetryableErrorRate:
+		logger.Warn("Chaos: failing activity without retries")
+		return nil, temporal.NewNonRetryableApplicationError("chaos: injected non-retryable failure", ErrorType, nil)
+	case roll < fault.TimeoutRate+fault.NonRetryableErrorRate+fault.ErrorRate:
+		logger.Warn("Chaos: failing activity")
+		return nil, temporal.NewApplicationError("chaos: injected failure", ErrorType)
+	}
+	return a.Next.ExecuteActivity(ctx, in)
+}
@@ -0,0 +1,70 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+)
+
+type ChaosTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+}
+
+func TestChaos(t *testing.T) {
+	suite.Run(t, new(ChaosTestSuite))
+}
+
+func Ping(_ context.Context) (string, error) {
+	return "pong", nil
+}
+
+func (s *ChaosTestSuite) run(faults map[string]Fault) error {
+	env := s.NewTestActivityEnvironment()
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{NewInterceptor(1, faults)},
+	})
+	env.RegisterActivity(Ping)
+	_, err := env.ExecuteActivity(Ping)
+	return err
+}
+
+func (s *ChaosTestSuite) Test_PassesThrough_WithoutFaults() {
+	s.NoError(s.run(map[string]Fault{"Other": {ErrorRate: 1}}))
+}
+
+func (s *ChaosTestSuite) Test_InjectsRetryableError() {
+	err := s.run(map[string]Fault{"Ping": {ErrorRate: 1}})
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorType, appErr.Type())
+	s.False(appErr.NonRetryable())
+}
+
+func (s *ChaosTestSuite) Test_InjectsNonRetryableError() {
+	err := s.run(map[string]Fault{AnyActivity: {NonRetryableErrorRate: 1}})
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.True(appErr.NonRetryable())
+}
+
+func (s *ChaosTestSuite) Test_SameSeedFailsSameAttempts() {
+	faults := map[string]Fault{"Ping": {ErrorRate: 0.5}}
+	first := s.run(faults)
+	for range 10 {
+		s.Equal(first == nil, s.run(faults) == nil)
+	}
+}
+
+func (s *ChaosTestSuite) Test_RejectsRatesAboveOne() {
+	s.Error(Fault{ErrorRate: 0.6, TimeoutRate: 0.6}.Validate())
+	s.NoError(Fault{ErrorRate: 0.5, TimeoutRate: 0.5}.Validate())
+}
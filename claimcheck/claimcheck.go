@@ -0,0 +1,102 @@
+package claimcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+const (
+	// EncodingClaimCheck marks payloads that were replaced with a reference to a blob.
+	EncodingClaimCheck = "binary/claim-check"
+	// MetadataSize is size of the original payload. Handy when looking at history in UI without codec server.
+	MetadataSize = "claim-check-size"
+)
+
+// Codec moves payloads bigger than threshold to BlobStore, and keeps only their key in history.
+// It should run after encryption, so blobs are encrypted as well.
+type Codec struct {
+	store     BlobStore
+	threshold int
+}
+
+var _ converter.PayloadCodec = (*Codec)(nil)
+
+func NewCodec(store BlobStore, threshold int) *Codec {
+	return &Codec{store: store, threshold: threshold}
+}
+
+func (c *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, payload := range payloads {
+		if payload.Size() <= c.threshold {
+			result[i] = payload
+			continue
+		}
+
+		data, err := payload.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		// Blobs are content-addressed. Retried activities and identical inputs don't create new blobs.
+		sum := sha256.Sum256(data)
+		key := hex.EncodeToString(sum[:])
+		// PayloadCodec has no context. Timeouts are up to the store.
+		if err := c.store.Put(context.Background(), key, data); err != nil {
+			return nil, fmt.Errorf("storing claim-checked payload: %w", err)
+		}
+
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				converter.MetadataEncoding: []byte(EncodingClaimCheck),
+				MetadataSize:               []byte(strconv.Itoa(len(data))),
+			},
+			Data: []byte(key),
+		}
+	}
+	return result, nil
+}
+
+func (c *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, payload := range payloads {
+		key, ok := Key(payload)
+		if !ok {
+			result[i] = payload
+			continue
+		}
+
+		data, err := c.store.Get(context.Background(), key)
+		if err != nil {
+			return nil, fmt.Errorf("loading claim-checked payload %s: %w", key, err)
+		}
+		decoded := &commonpb.Payload{}
+		if err := decoded.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		result[i] = decoded
+	}
+	return result, nil
+}
+
+// Key returns blob key if payload is a claim check.
+func Key(payload *commonpb.Payload) (string, bool) {
+	if string(payload.GetMetadata()[converter.MetadataEncoding]) != EncodingClaimCheck {
+		return "", false
+	}
+	key := string(payload.GetData())
+	return key, validKey(key)
+}
+
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
@@ -0,0 +1,128 @@
+package claimcheck
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+type CodecTestSuite struct {
+	suite.Suite
+
+	store   *FileStore
+	payload *commonpb.Payload
+}
+
+func TestCodec(t *testing.T) {
+	suite.Run(t, new(CodecTestSuite))
+}
+
+func (s *CodecTestSuite) SetupTest() {
+	s.store = s.newStore()
+	var err error
+	s.payload, err = converter.GetDefaultDataConverter().ToPayload(strings.Repeat("payslip ", 100))
+	s.Require().NoError(err)
+}
+
+func (s *CodecTestSuite) newStore() *FileStore {
+	store, err := NewFileStore(s.T().TempDir())
+	s.Require().NoError(err)
+	return store
+}
+
+func (s *CodecTestSuite) encode(threshold int) *commonpb.Payload {
+	encoded, err := NewCodec(s.store, threshold).Encode([]*commonpb.Payload{s.payload})
+	s.Require().NoError(err)
+	s.Require().Len(encoded, 1)
+	return encoded[0]
+}
+
+func (s *CodecTestSuite) Test_ClaimChecksPayloadsAboveThresholdOnly() {
+	tests := []struct {
+		name        string
+		threshold   int
+		claimCheck  bool
+		storedBlobs int
+	}{
+		{name: "below threshold", threshold: s.payload.Size() + 1, claimCheck: false},
+		{name: "at threshold", threshold: s.payload.Size(), claimCheck: false},
+		{name: "above threshold", threshold: s.payload.Size() - 1, claimCheck: true, storedBlobs: 1},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.store = s.newStore()
+
+			encoded := s.encode(test.threshold)
+
+			_, isClaimCheck := Key(encoded)
+			s.Equal(test.claimCheck, isClaimCheck)
+			if !test.claimCheck {
+				s.Same(s.payload, encoded)
+			}
+			s.Len(s.blobs(), test.storedBlobs)
+		})
+	}
+}
+
+func (s *CodecTestSuite) Test_DecodesWhatItEncoded() {
+	codec := NewCodec(s.store, 100)
+	small, err := converter.GetDefaultDataConverter().ToPayload("payslip-1")
+	s.Require().NoError(err)
+
+	encoded, err := codec.Encode([]*commonpb.Payload{s.payload, small})
+	s.Require().NoError(err)
+	decoded, err := codec.Decode(encoded)
+
+	s.Require().NoError(err)
+	s.Equal(EncodingClaimCheck, string(encoded[0].Metadata[converter.MetadataEncoding]))
+	s.Less(encoded[0].Size(), 200)
+	s.Require().Len(decoded, 2)
+	s.Equal(s.payload.Metadata, decoded[0].Metadata)
+	s.Equal(s.payload.Data, decoded[0].Data)
+	s.Same(small, decoded[1])
+}
+
+func (s *CodecTestSuite) Test_StoresIdenticalPayloadsOnce() {
+	first := s.encode(100)
+	second := s.encode(100)
+
+	s.Equal(first.Data, second.Data)
+	s.Len(s.blobs(), 1)
+}
+
+func (s *CodecTestSuite) Test_FailsToDecode_WhenBlobIsMissing() {
+	encoded := s.encode(100)
+	key, _ := Key(encoded)
+	s.Require().NoError(s.store.Delete(context.Background(), key))
+
+	_, err := NewCodec(s.store, 100).Decode([]*commonpb.Payload{encoded})
+
+	s.ErrorIs(err, ErrNotFound)
+	s.ErrorContains(err, key)
+}
+
+func (s *CodecTestSuite) Test_PassesThroughClaimChecksWithInvalidKey() {
+	payload := &commonpb.Payload{
+		Metadata: map[string][]byte{converter.MetadataEncoding: []byte(EncodingClaimCheck)},
+		Data:     []byte("../../etc/passwd"),
+	}
+
+	decoded, err := NewCodec(s.store, 100).Decode([]*commonpb.Payload{payload})
+
+	s.Require().NoError(err)
+	s.Same(payload, decoded[0])
+}
+
+func (s *CodecTestSuite) blobs() []string {
+	var keys []string
+	s.Require().NoError(s.store.Walk(context.Background(), func(key string, _ time.Time) error {
+		keys = append(keys, key)
+		return nil
+	}))
+	return keys
+}
@@ -0,0 +1,163 @@
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type GCResult struct {
+	Workflows  int
+	Schedules  int
+	Referenced int
+	Deleted    []string
+}
+
+// Collect deletes blobs not referenced by any workflow history that is still retained in the namespace, nor by any
+// schedule. Once workflow is past retention, its history is gone, and so is the last reference to its blobs.
+// Schedules keep arguments of workflows they are yet to start for as long as they exist.
+//
+// Blobs younger than grace period are kept, as they might be in the middle of being written into history.
+func Collect(ctx context.Context, c client.Client, namespace string, store BlobStore, grace time.Duration, dryRun bool) (GCResult, error) {
+	var result GCResult
+	referenced := map[string]bool{}
+
+	var nextPageToken []byte
+	for {
+		// Visibility returns closed workflows too, until they are past retention.
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Namespace:     namespace,
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return result, fmt.Errorf("listing workflows: %w", err)
+		}
+		for _, execution := range resp.GetExecutions() {
+			err := markReferenced(ctx, c, execution.GetExecution().GetWorkflowId(), execution.GetExecution().GetRunId(), referenced)
+			if err != nil {
+				return result, err
+			}
+			result.Workflows++
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			break
+		}
+	}
+
+	schedules, err := markReferencedBySchedules(ctx, c.ScheduleClient(), referenced)
+	if err != nil {
+		return result, err
+	}
+	result.Schedules = schedules
+	result.Referenced = len(referenced)
+
+	cutoff := time.Now().Add(-grace)
+	err = store.Walk(ctx, func(key string, createdAt time.Time) error {
+		if referenced[key] || createdAt.After(cutoff) {
+			return nil
+		}
+		result.Deleted = append(result.Deleted, key)
+		if dryRun {
+			return nil
+		}
+		return store.Delete(ctx, key)
+	})
+	return result, err
+}
+
+func markReferenced(ctx context.Context, c client.Client, workflowID, runID string, referenced map[string]bool) error {
+	history := c.GetWorkflowHistory(ctx, workflowID, runID, false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for history.HasNext() {
+		event, err := history.Next()
+		if err != nil {
+			return fmt.Errorf("reading history of %s: %w", workflowID, err)
+		}
+		walkPayloads(event.ProtoReflect(), func(payload *commonpb.Payload) {
+			if key, ok := Key(payload); ok {
+				referenced[key] = true
+			}
+		})
+	}
+	return nil
+}
+
+// markReferencedBySchedules marks payloads schedules start workflows with: arguments and memo. Description returns them
+// as they are stored, so references are not resolved.
+func markReferencedBySchedules(ctx context.Context, c client.ScheduleClient, referenced map[string]bool) (int, error) {
+	mark := func(value interface{}) {
+		if payload, ok := value.(*commonpb.Payload); ok {
+			if key, ok := Key(payload); ok {
+				referenced[key] = true
+			}
+		}
+	}
+
+	schedules, err := c.List(ctx, client.ScheduleListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("listing schedules: %w", err)
+	}
+	count := 0
+	for schedules.HasNext() {
+		entry, err := schedules.Next()
+		if err != nil {
+			return count, fmt.Errorf("listing schedules: %w", err)
+		}
+		for _, payload := range entry.Memo.GetFields() {
+			mark(payload)
+		}
+		description, err := c.GetHandle(ctx, entry.ID).Describe(ctx)
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			// Deleted since listed, it references nothing anymore.
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("describing schedule %s: %w", entry.ID, err)
+		}
+		if action, ok := description.Schedule.Action.(*client.ScheduleWorkflowAction); ok {
+			for _, arg := range action.Args {
+				mark(arg)
+			}
+			for _, value := range action.Memo {
+				mark(value)
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+// walkPayloads finds payloads anywhere in a message. Every event type keeps them in different fields, and we don't
+// want to miss any of them (inputs, results, failure details, memos, signals...).
+func walkPayloads(m protoreflect.Message, fn func(*commonpb.Payload)) {
+	if payload, ok := m.Interface().(*commonpb.Payload); ok {
+		fn(payload)
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				walkPayloads(list.Get(i).Message(), fn)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				walkPayloads(value.Message(), fn)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			walkPayloads(v.Message(), fn)
+		}
+		return true
+	})
+}
@@ -0,0 +1,236 @@
+package claimcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+type GCTestSuite struct {
+	suite.Suite
+
+	ctx      context.Context
+	store    *FileStore
+	temporal *fakeTemporal
+}
+
+func TestGC(t *testing.T) {
+	suite.Run(t, new(GCTestSuite))
+}
+
+func (s *GCTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	var err error
+	s.store, err = NewFileStore(s.T().TempDir())
+	s.Require().NoError(err)
+	s.temporal = &fakeTemporal{histories: map[string][]*historypb.HistoryEvent{}, schedules: &fakeSchedules{}}
+}
+
+// putBlob stores a blob created age ago, and returns its key.
+func (s *GCTestSuite) putBlob(content string, age time.Duration) string {
+	sum := sha256.Sum256([]byte(content))
+	key := hex.EncodeToString(sum[:])
+	s.Require().NoError(s.store.Put(s.ctx, key, []byte(content)))
+	createdAt := time.Now().Add(-age)
+	s.Require().NoError(os.Chtimes(s.store.path(key), createdAt, createdAt))
+	return key
+}
+
+func claimCheck(key string) *commonpb.Payload {
+	return &commonpb.Payload{
+		Metadata: map[string][]byte{converter.MetadataEncoding: []byte(EncodingClaimCheck)},
+		Data:     []byte(key),
+	}
+}
+
+func (s *GCTestSuite) Test_DeletesOnlyUnreferencedBlobsOlderThanGracePeriod() {
+	input := s.putBlob("workflow input", 48*time.Hour)
+	result := s.putBlob("activity result", 48*time.Hour)
+	memo := s.putBlob("memo", 48*time.Hour)
+	orphaned := s.putBlob("workflow past retention", 48*time.Hour)
+	beingWritten := s.putBlob("activity still running", time.Minute)
+	s.temporal.addWorkflow("payroll-1",
+		&historypb.HistoryEvent{Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+				Input: &commonpb.Payloads{Payloads: []*commonpb.Payload{claimCheck(input)}},
+				Memo:  &commonpb.Memo{Fields: map[string]*commonpb.Payload{"payslips": claimCheck(memo)}},
+			},
+		}},
+	)
+	// On the second page of visibility.
+	s.temporal.addWorkflow("payroll-2",
+		&historypb.HistoryEvent{Attributes: &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{
+			ActivityTaskCompletedEventAttributes: &historypb.ActivityTaskCompletedEventAttributes{
+				Result: &commonpb.Payloads{Payloads: []*commonpb.Payload{claimCheck(result)}},
+			},
+		}},
+	)
+
+	gc, err := Collect(s.ctx, s.temporal, "default", s.store, time.Hour, false)
+
+	s.Require().NoError(err)
+	s.Equal(2, gc.Workflows)
+	s.Equal(3, gc.Referenced)
+	s.Equal([]string{orphaned}, gc.Deleted)
+	s.Equal([]string{input, result, memo, beingWritten}, s.keys(input, result, memo, orphaned, beingWritten))
+}
+
+func (s *GCTestSuite) Test_KeepsBlobsReferencedBySchedules() {
+	arg := s.putBlob("scheduled workflow input", 48*time.Hour)
+	actionMemo := s.putBlob("scheduled workflow memo", 48*time.Hour)
+	scheduleMemo := s.putBlob("schedule memo", 48*time.Hour)
+	orphaned := s.putBlob("schedule deleted since", 48*time.Hour)
+	s.temporal.schedules.add(client.ScheduleListEntry{
+		ID:   "nightly-payroll",
+		Memo: &commonpb.Memo{Fields: map[string]*commonpb.Payload{"note": claimCheck(scheduleMemo)}},
+	}, &client.ScheduleWorkflowAction{
+		Args: []interface{}{claimCheck(arg)},
+		Memo: map[string]interface{}{"payslips": claimCheck(actionMemo)},
+	})
+	// Listed, but deleted before it was described.
+	s.temporal.schedules.entries = append(s.temporal.schedules.entries, client.ScheduleListEntry{ID: "deleted"})
+
+	gc, err := Collect(s.ctx, s.temporal, "default", s.store, time.Hour, false)
+
+	s.Require().NoError(err)
+	s.Equal(1, gc.Schedules)
+	s.Equal(3, gc.Referenced)
+	s.Equal([]string{orphaned}, gc.Deleted)
+}
+
+func (s *GCTestSuite) Test_DeletesNothing_OnDryRun() {
+	orphaned := s.putBlob("workflow past retention", 48*time.Hour)
+
+	gc, err := Collect(s.ctx, s.temporal, "default", s.store, time.Hour, true)
+
+	s.Require().NoError(err)
+	s.Equal([]string{orphaned}, gc.Deleted)
+	s.Equal([]string{orphaned}, s.keys(orphaned))
+}
+
+// keys returns those of keys that are still in the store.
+func (s *GCTestSuite) keys(keys ...string) []string {
+	var kept []string
+	for _, key := range keys {
+		if _, err := s.store.Get(s.ctx, key); err == nil {
+			kept = append(kept, key)
+		}
+	}
+	return kept
+}
+
+// fakeTemporal lists a workflow per visibility page, with histories it was given. Anything else it's asked panics.
+type fakeTemporal struct {
+	client.Client
+	workflowIDs []string
+	histories   map[string][]*historypb.HistoryEvent
+	schedules   *fakeSchedules
+}
+
+func (c *fakeTemporal) addWorkflow(workflowID string, events ...*historypb.HistoryEvent) {
+	c.workflowIDs = append(c.workflowIDs, workflowID)
+	c.histories[workflowID] = events
+}
+
+func (c *fakeTemporal) ListWorkflow(_ context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	page := 0
+	if len(request.NextPageToken) > 0 {
+		page = int(request.NextPageToken[0])
+	}
+	response := &workflowservice.ListWorkflowExecutionsResponse{}
+	if page < len(c.workflowIDs) {
+		response.Executions = []*workflowpb.WorkflowExecutionInfo{{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: c.workflowIDs[page], RunId: "run-1"},
+		}}
+	}
+	if page+1 < len(c.workflowIDs) {
+		response.NextPageToken = []byte{byte(page + 1)}
+	}
+	return response, nil
+}
+
+func (c *fakeTemporal) GetWorkflowHistory(_ context.Context, workflowID string, _ string, _ bool, _ enums.HistoryEventFilterType) client.HistoryEventIterator {
+	return &fakeHistory{events: c.histories[workflowID]}
+}
+
+func (c *fakeTemporal) ScheduleClient() client.ScheduleClient {
+	return c.schedules
+}
+
+// fakeSchedules describes schedules with actions they were given. Those without one are not found.
+type fakeSchedules struct {
+	client.ScheduleClient
+	entries []client.ScheduleListEntry
+	actions map[string]client.ScheduleAction
+}
+
+func (c *fakeSchedules) add(entry client.ScheduleListEntry, action client.ScheduleAction) {
+	if c.actions == nil {
+		c.actions = map[string]client.ScheduleAction{}
+	}
+	c.entries = append(c.entries, entry)
+	c.actions[entry.ID] = action
+}
+
+func (c *fakeSchedules) List(context.Context, client.ScheduleListOptions) (client.ScheduleListIterator, error) {
+	return &fakeScheduleList{entries: c.entries}, nil
+}
+
+func (c *fakeSchedules) GetHandle(_ context.Context, id string) client.ScheduleHandle {
+	return &fakeScheduleHandle{schedules: c, id: id}
+}
+
+type fakeScheduleHandle struct {
+	client.ScheduleHandle
+	schedules *fakeSchedules
+	id        string
+}
+
+func (h *fakeScheduleHandle) Describe(context.Context) (*client.ScheduleDescription, error) {
+	action, ok := h.schedules.actions[h.id]
+	if !ok {
+		return nil, serviceerror.NewNotFound("schedule not found")
+	}
+	return &client.ScheduleDescription{Schedule: client.Schedule{Action: action}}, nil
+}
+
+type fakeScheduleList struct {
+	entries []client.ScheduleListEntry
+}
+
+func (l *fakeScheduleList) HasNext() bool {
+	return len(l.entries) > 0
+}
+
+func (l *fakeScheduleList) Next() (*client.ScheduleListEntry, error) {
+	entry := l.entries[0]
+	l.entries = l.entries[1:]
+	return &entry, nil
+}
+
+type fakeHistory struct {
+	events []*historypb.HistoryEvent
+}
+
+func (h *fakeHistory) HasNext() bool {
+	return len(h.events) > 0
+}
+
+func (h *fakeHistory) Next() (*historypb.HistoryEvent, error) {
+	event := h.events[0]
+	h.events = h.events[1:]
+	return event, nil
+}
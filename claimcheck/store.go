@@ -0,0 +1,99 @@
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps payloads that are too big to be stored in workflow history.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrNotFound if blob doesn't exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// Walk calls fn for every blob in the store, until fn returns an error.
+	Walk(ctx context.Context, fn func(key string, createdAt time.Time) error) error
+}
+
+// FileStore keeps blobs as files in a local directory. Every worker (and codec server) has to see the same directory,
+// so outside of local development it needs to be a shared volume.
+type FileStore struct {
+	dir string
+}
+
+var _ BlobStore = (*FileStore)(nil)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating blob directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Put(_ context.Context, key string, data []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// Write and rename, so nobody can read half-written blob.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) Walk(ctx context.Context, fn func(key string, createdAt time.Time) error) error {
+	return filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || !validKey(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		return fn(entry.Name(), info.ModTime())
+	})
+}
+
+// path spreads blobs into subdirectories, so a single directory doesn't end up with millions of files.
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
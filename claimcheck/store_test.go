@@ -0,0 +1,49 @@
+package claimcheck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const blobKey = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestFileStore_KeepsBlobs(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, blobKey, []byte("payload")))
+	data, err := store.Get(ctx, blobKey)
+	require.NoError(t, err)
+	require.Equal(t, []byte("payload"), data)
+
+	require.NoError(t, store.Delete(ctx, blobKey))
+	_, err = store.Get(ctx, blobKey)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, store.Delete(ctx, blobKey), "deleting a missing blob is not an error")
+}
+
+func TestFileStore_WalksOnlyBlobs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(ctx, blobKey, []byte("payload")))
+	// Left behind by a write that didn't finish.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, blobKey[:2], ".tmp-123"), []byte("pay"), 0o600))
+
+	var keys []string
+	err = store.Walk(ctx, func(key string, createdAt time.Time) error {
+		keys = append(keys, key)
+		require.WithinDuration(t, time.Now(), createdAt, time.Minute)
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{blobKey}, keys)
+}
@@ -0,0 +1,15 @@
+// Command workflowcheck reports non-deterministic code in workflows. Run it directly, or as a vet tool:
+//
+//	go run ./cmd/workflowcheck ./...
+//	go build -o bin/workflowcheck ./cmd/workflowcheck && go vet -vettool=bin/workflowcheck ./...
+package main
+
+import (
+	"temporal-poc/workflowcheck"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(workflowcheck.Analyzer)
+}
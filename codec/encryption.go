@@ -0,0 +1,139 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EncodingEncrypted marks payloads encrypted by Encryption codec. Anything else is passed through untouched,
+	// so histories written before encryption was enabled can still be read.
+	EncodingEncrypted = "binary/encrypted"
+	// MetadataKeyID tells which key payload was encrypted with. Old keys have to be kept in key file for as long as
+	// histories encrypted with them are retained.
+	MetadataKeyID = "encryption-key-id"
+)
+
+// Keys are loaded from YAML file like this one:
+//
+//	active: "2024-06"
+//	keys:
+//	  "2024-06": <base64 encoded 32 bytes>
+//	  "2024-01": <base64 encoded 32 bytes>
+type Keys struct {
+	Active string            `yaml:"active"`
+	Keys   map[string]string `yaml:"keys"`
+}
+
+// Encryption encrypts whole payloads (including their metadata) with AES-256-GCM.
+type Encryption struct {
+	activeKeyID string
+	ciphers     map[string]cipher.AEAD
+}
+
+var _ converter.PayloadCodec = (*Encryption)(nil)
+
+func LoadKeys(path string) (Keys, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Keys{}, fmt.Errorf("reading key file: %w", err)
+	}
+	var keys Keys
+	if err := yaml.Unmarshal(raw, &keys); err != nil {
+		return Keys{}, fmt.Errorf("parsing key file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func NewEncryption(keys Keys) (*Encryption, error) {
+	if _, ok := keys.Keys[keys.Active]; !ok {
+		return nil, fmt.Errorf("active key %q is not among keys", keys.Active)
+	}
+
+	ciphers := make(map[string]cipher.AEAD, len(keys.Keys))
+	for id, encoded := range keys.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q has to be 32 bytes long, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		ciphers[id], err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Encryption{activeKeyID: keys.Active, ciphers: ciphers}, nil
+}
+
+func (e *Encryption) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	aead := e.ciphers[e.activeKeyID]
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, payload := range payloads {
+		plaintext, err := payload.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		// Key ID is authenticated too, so it can't be swapped without decryption failing.
+		data := aead.Seal(nonce, nonce, plaintext, []byte(e.activeKeyID))
+
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				converter.MetadataEncoding: []byte(EncodingEncrypted),
+				MetadataKeyID:              []byte(e.activeKeyID),
+			},
+			Data: data,
+		}
+	}
+	return result, nil
+}
+
+func (e *Encryption) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, payload := range payloads {
+		if string(payload.GetMetadata()[converter.MetadataEncoding]) != EncodingEncrypted {
+			result[i] = payload
+			continue
+		}
+
+		keyID := string(payload.GetMetadata()[MetadataKeyID])
+		aead, ok := e.ciphers[keyID]
+		if !ok {
+			return nil, fmt.Errorf("payload was encrypted with unknown key %q", keyID)
+		}
+		data := payload.GetData()
+		if len(data) < aead.NonceSize() {
+			return nil, fmt.Errorf("encrypted payload is too short")
+		}
+		plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(keyID))
+		if err != nil {
+			return nil, fmt.Errorf("decrypting payload with key %q: %w", keyID, err)
+		}
+
+		decoded := &commonpb.Payload{}
+		if err := decoded.Unmarshal(plaintext); err != nil {
+			return nil, err
+		}
+		result[i] = decoded
+	}
+	return result, nil
+}
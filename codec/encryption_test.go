@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+type EncryptionTestSuite struct {
+	suite.Suite
+
+	payload *commonpb.Payload
+}
+
+func TestEncryption(t *testing.T) {
+	suite.Run(t, new(EncryptionTestSuite))
+}
+
+func (s *EncryptionTestSuite) SetupTest() {
+	var err error
+	s.payload, err = converter.GetDefaultDataConverter().ToPayload(map[string]string{"nino": "QQ123456C"})
+	s.Require().NoError(err)
+}
+
+// key is 32 bytes of b, base64 encoded like in key file.
+func key(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func (s *EncryptionTestSuite) newEncryption(keys Keys) *Encryption {
+	encryption, err := NewEncryption(keys)
+	s.Require().NoError(err)
+	return encryption
+}
+
+func (s *EncryptionTestSuite) encrypt(encryption *Encryption) *commonpb.Payload {
+	encoded, err := encryption.Encode([]*commonpb.Payload{s.payload})
+	s.Require().NoError(err)
+	s.Require().Len(encoded, 1)
+	return encoded[0]
+}
+
+func (s *EncryptionTestSuite) Test_DecryptsWhatItEncrypted() {
+	encryption := s.newEncryption(Keys{Active: "2024-06", Keys: map[string]string{"2024-06": key(1)}})
+
+	encrypted := s.encrypt(encryption)
+	decoded, err := encryption.Decode([]*commonpb.Payload{encrypted})
+
+	s.Require().NoError(err)
+	s.Equal(EncodingEncrypted, string(encrypted.Metadata[converter.MetadataEncoding]))
+	s.Equal("2024-06", string(encrypted.Metadata[MetadataKeyID]))
+	s.NotContains(string(encrypted.Data), "QQ123456C")
+	s.Require().Len(decoded, 1)
+	s.Equal(s.payload.Metadata, decoded[0].Metadata)
+	s.Equal(s.payload.Data, decoded[0].Data)
+}
+
+func (s *EncryptionTestSuite) Test_DecryptsWithKeyRotatedOut_WhileItIsKept() {
+	old := s.encrypt(s.newEncryption(Keys{Active: "2024-01", Keys: map[string]string{"2024-01": key(1)}}))
+	rotated := s.newEncryption(Keys{Active: "2024-06", Keys: map[string]string{"2024-06": key(2), "2024-01": key(1)}})
+
+	decoded, err := rotated.Decode([]*commonpb.Payload{old})
+
+	s.Require().NoError(err)
+	s.Equal(s.payload.Data, decoded[0].Data)
+	s.Equal("2024-06", string(s.encrypt(rotated).Metadata[MetadataKeyID]))
+}
+
+func (s *EncryptionTestSuite) Test_PassesUnencryptedPayloadsThrough() {
+	encryption := s.newEncryption(Keys{Active: "2024-06", Keys: map[string]string{"2024-06": key(1)}})
+
+	decoded, err := encryption.Decode([]*commonpb.Payload{s.payload})
+
+	s.Require().NoError(err)
+	s.Same(s.payload, decoded[0])
+}
+
+func (s *EncryptionTestSuite) Test_FailsToDecrypt() {
+	// Both IDs have the same key, so only authenticated key ID tells them apart.
+	keys := Keys{Active: "2024-06", Keys: map[string]string{"2024-06": key(1), "2024-01": key(1)}}
+	tests := []struct {
+		name   string
+		keys   Keys
+		tamper func(payload *commonpb.Payload)
+		err    string
+	}{
+		{
+			name:   "key was removed from key file",
+			keys:   Keys{Active: "2025-01", Keys: map[string]string{"2025-01": key(2)}},
+			tamper: func(*commonpb.Payload) {},
+			err:    `payload was encrypted with unknown key "2024-06"`,
+		},
+		{
+			name:   "key ID was swapped",
+			keys:   keys,
+			tamper: func(payload *commonpb.Payload) { payload.Metadata[MetadataKeyID] = []byte("2024-01") },
+			err:    `decrypting payload with key "2024-01"`,
+		},
+		{
+			name:   "data was changed",
+			keys:   keys,
+			tamper: func(payload *commonpb.Payload) { payload.Data[len(payload.Data)-1] ^= 1 },
+			err:    `decrypting payload with key "2024-06"`,
+		},
+		{
+			name:   "data was cut short",
+			keys:   keys,
+			tamper: func(payload *commonpb.Payload) { payload.Data = payload.Data[:4] },
+			err:    "encrypted payload is too short",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			encrypted := s.encrypt(s.newEncryption(keys))
+			test.tamper(encrypted)
+
+			_, err := s.newEncryption(test.keys).Decode([]*commonpb.Payload{encrypted})
+
+			s.ErrorContains(err, test.err)
+		})
+	}
+}
+
+func (s *EncryptionTestSuite) Test_RejectsInvalidKeys() {
+	tests := []struct {
+		name string
+		keys Keys
+		err  string
+	}{
+		{name: "active key is missing", keys: Keys{Active: "2024-06", Keys: map[string]string{"2024-01": key(1)}}, err: `active key "2024-06" is not among keys`},
+		{name: "key is not base64", keys: Keys{Active: "2024-06", Keys: map[string]string{"2024-06": "not base64!"}}, err: `key "2024-06" is not valid base64`},
+		{name: "key is too short", keys: Keys{Active: "2024-06", Keys: map[string]string{"2024-06": base64.StdEncoding.EncodeToString([]byte("short"))}}, err: "has to be 32 bytes long, got 5"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			_, err := NewEncryption(test.keys)
+
+			s.ErrorContains(err, test.err)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.temporal.io/sdk/converter"
+)
+
+type ServerOptions struct {
+	// AllowedOrigins are origins of Temporal UI allowed to call codec server from the browser.
+	AllowedOrigins []string
+	// Tokens are accepted as "Authorization: Bearer <token>". Without tokens, anyone who can reach the server can
+	// decrypt payloads, so it should be left empty only locally.
+	Tokens []string
+}
+
+// NewServer exposes codecs as a remote codec compatible with Temporal UI and CLI (POST /encode and /decode).
+func NewServer(options ServerOptions, codecs ...converter.PayloadCodec) http.Handler {
+	handler := converter.NewPayloadCodecHTTPHandler(codecs...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && slices.Contains(options.AllowedOrigins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Namespace")
+			w.Header().Add("Vary", "Origin")
+		}
+		// Preflight requests never carry credentials.
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if len(options.Tokens) > 0 && !authorized(r, options.Tokens) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func authorized(r *http.Request, tokens []string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	for _, allowed := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+
+	encryption *Encryption
+	server     *httptest.Server
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+const uiOrigin = "https://temporal-ui.example"
+
+func (s *ServerTestSuite) SetupTest() {
+	var err error
+	s.encryption, err = NewEncryption(Keys{Active: "2024-06", Keys: map[string]string{"2024-06": key(1)}})
+	s.Require().NoError(err)
+	s.server = httptest.NewServer(NewServer(ServerOptions{AllowedOrigins: []string{uiOrigin}, Tokens: []string{"secret"}}, s.encryption))
+	s.T().Cleanup(s.server.Close)
+}
+
+// remoteCodec calls the server like Temporal CLI does, with Authorization header set to authorization.
+func (s *ServerTestSuite) remoteCodec(authorization string) converter.PayloadCodec {
+	return converter.NewRemotePayloadCodec(converter.RemotePayloadCodecOptions{
+		Endpoint: s.server.URL,
+		ModifyRequest: func(r *http.Request) error {
+			if authorization != "" {
+				r.Header.Set("Authorization", authorization)
+			}
+			return nil
+		},
+	})
+}
+
+func (s *ServerTestSuite) Test_DecodesPayloads() {
+	payload, err := converter.GetDefaultDataConverter().ToPayload("QQ123456C")
+	s.Require().NoError(err)
+	encrypted, err := s.encryption.Encode([]*commonpb.Payload{payload})
+	s.Require().NoError(err)
+
+	decoded, err := s.remoteCodec("Bearer secret").Decode(encrypted)
+
+	s.Require().NoError(err)
+	s.Require().Len(decoded, 1)
+	s.Equal(payload.Data, decoded[0].Data)
+}
+
+// request sends request to the server, with headers set when they are not empty.
+func (s *ServerTestSuite) request(method string, headers map[string]string) *http.Response {
+	request, err := http.NewRequest(method, s.server.URL+"/decode", strings.NewReader(`{"payloads":[]}`))
+	s.Require().NoError(err)
+	for name, value := range headers {
+		if value != "" {
+			request.Header.Set(name, value)
+		}
+	}
+	response, err := http.DefaultClient.Do(request)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { _ = response.Body.Close() })
+	return response
+}
+
+func (s *ServerTestSuite) Test_RejectsRequestsWithoutValidToken() {
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{name: "no token", authorization: "", status: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guess", status: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "Basic c2VjcmV0", status: http.StatusUnauthorized},
+		{name: "valid token", authorization: "Bearer secret", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			response := s.request(http.MethodPost, map[string]string{"Authorization": test.authorization, "Content-Type": "application/json"})
+
+			s.Equal(test.status, response.StatusCode)
+		})
+	}
+}
+
+func (s *ServerTestSuite) Test_AnswersPreflight_OfAllowedOriginsOnly() {
+	tests := []struct {
+		name   string
+		origin string
+		allow  string
+	}{
+		{name: "allowed origin", origin: uiOrigin, allow: uiOrigin},
+		{name: "other origin", origin: "https://evil.example", allow: ""},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			// Browsers send preflight without credentials, so it's answered without a token.
+			response := s.request(http.MethodOptions, map[string]string{"Origin": test.origin, "Access-Control-Request-Method": http.MethodPost})
+
+			s.Equal(http.StatusNoContent, response.StatusCode)
+			s.Equal(test.allow, response.Header.Get("Access-Control-Allow-Origin"))
+			if test.allow != "" {
+				s.Contains(response.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+				s.Equal("true", response.Header.Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
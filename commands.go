@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"temporal-poc/claimcheck"
+	"temporal-poc/delivery"
+	"temporal-poc/histories"
+	"temporal-poc/schedules"
+	"temporal-poc/workflows"
+
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+)
+
+func processPayroll(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("payroll process", flag.ExitOnError)
+	wait := fs.Bool("wait", false, "wait until payroll is processed")
+	e, err := setup(fs, args, "<payroll-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	payrollID := e.args[0]
+	run, err := e.client.ExecuteWorkflow(ctx, workflows.ProcessPayrollOptions(payrollID), workflows.ProcessPayroll, payrollID)
+	if err != nil {
+		return err
+	}
+	return reportRun(ctx, run, *wait)
+}
+
+func payrollStatus(ctx context.Context, args []string) error {
+	e, err := setup(flag.NewFlagSet("payroll status", flag.ExitOnError), args, "<payroll-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	resp, err := e.client.DescribeWorkflowExecution(ctx, workflows.ProcessPayrollWorkflowID(e.args[0]), "")
+	if err != nil {
+		return err
+	}
+	info := resp.GetWorkflowExecutionInfo()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Workflow ID:\t%s\n", info.GetExecution().GetWorkflowId())
+	fmt.Fprintf(tw, "Run ID:\t%s\n", info.GetExecution().GetRunId())
+	fmt.Fprintf(tw, "Status:\t%s\n", info.GetStatus())
+	fmt.Fprintf(tw, "Started:\t%s\n", info.GetStartTime().AsTime().Format(time.RFC3339))
+	if info.GetCloseTime() != nil {
+		fmt.Fprintf(tw, "Closed:\t%s\n", info.GetCloseTime().AsTime().Format(time.RFC3339))
+	}
+	// Stage is known only to the workflow itself. If no worker is around to answer, we just skip it.
+	value, err := e.client.QueryWorkflow(ctx, info.GetExecution().GetWorkflowId(), info.GetExecution().GetRunId(), workflows.PayrollStatusQuery)
+	var status workflows.PayrollStatus
+	if err == nil && value.Get(&status) == nil {
+		fmt.Fprintf(tw, "Stage:\t%s\n", status.Stage)
+	}
+	fmt.Fprintf(tw, "Pending activities:\t%d\n", len(resp.GetPendingActivities()))
+	fmt.Fprintf(tw, "Pending child workflows:\t%d\n", len(resp.GetPendingChildren()))
+	if len(status.FPSErrors) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "CODE\tPAYROLL ID\tFIELD\tMESSAGE")
+		for _, fpsError := range status.FPSErrors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", fpsError.Code, fpsError.PayrollID, fpsError.Field, fpsError.Message)
+		}
+	}
+	return tw.Flush()
+}
+
+func payrollsNeedingAttention(ctx context.Context, args []string) error {
+	e, err := setup(flag.NewFlagSet("payroll attention", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKFLOW ID\tSTAGE\tFPS ERRORS")
+	var nextPageToken []byte
+	for {
+		resp, err := e.client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Namespace: e.cfg.Temporal.Namespace,
+			Query: fmt.Sprintf("WorkflowType = 'ProcessPayroll' AND %s = true AND ExecutionStatus = 'Running'",
+				workflows.NeedsAttention.GetName()),
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return err
+		}
+		for _, execution := range resp.GetExecutions() {
+			workflowID := execution.GetExecution().GetWorkflowId()
+			// Same as with pay details, visibility may lag behind a correction already signalled.
+			value, err := e.client.QueryWorkflow(ctx, workflowID, execution.GetExecution().GetRunId(), workflows.PayrollStatusQuery)
+			var status workflows.PayrollStatus
+			if err == nil {
+				err = value.Get(&status)
+			}
+			if err != nil {
+				fmt.Fprintf(tw, "%s\t?\t%s\n", workflowID, err)
+				continue
+			}
+			if status.Stage != workflows.PayrollStageAwaitingFPSCorrection {
+				continue
+			}
+			codes := make([]string, 0, len(status.FPSErrors))
+			for _, fpsError := range status.FPSErrors {
+				codes = append(codes, fpsError.Code)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", workflowID, status.Stage, strings.Join(codes, ", "))
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return tw.Flush()
+		}
+	}
+}
+
+func correctPayroll(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("payroll correct", flag.ExitOnError)
+	note := fs.String("note", "", "what was corrected, kept in workflow history")
+	e, err := setup(fs, args, "<payroll-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	workflowID := workflows.ProcessPayrollWorkflowID(e.args[0])
+	err = e.client.SignalWorkflow(ctx, workflowID, "", workflows.FPSCorrectedSignal, workflows.FPSCorrection{Note: *note})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Asked %s to submit FPS again\n", workflowID)
+	return nil
+}
+
+func pushPayDetails(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("paydetails push", flag.ExitOnError)
+	wait := fs.Bool("wait", false, "wait until pay details are pushed")
+	e, err := setup(fs, args, "<company-id>", "<payslip-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	input := workflows.PushPayDetailsInput{
+		CompanyID: e.args[0],
+		PayslipID: e.args[1],
+	}
+	run, err := e.client.ExecuteWorkflow(ctx, workflows.PushPayDetailsOptions(input), workflows.PushPayDetails, input)
+	if err != nil {
+		return err
+	}
+	return reportRun(ctx, run, *wait)
+}
+
+func payDetailsDelivery(ctx context.Context, args []string) error {
+	cfg, args, err := parse(flag.NewFlagSet("paydetails delivery", flag.ExitOnError), args, "<company-id>", "<payslip-id>")
+	if err != nil {
+		return err
+	}
+	// Deliveries in memory of the worker can't be read from here.
+	if cfg.Deliveries.Dir == "" {
+		return errors.New("deliveries directory is not set")
+	}
+	store, err := delivery.NewFileStore(cfg.Deliveries.Dir)
+	if err != nil {
+		return err
+	}
+	d, err := store.Get(ctx, delivery.Ref{CompanyID: args[0], PayslipID: args[1]})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "State:\t%s\n", d.State)
+	fmt.Fprintf(tw, "Attempts:\t%d\n", d.Attempts)
+	if d.LastError != "" {
+		fmt.Fprintf(tw, "Last error:\t%s\n", d.LastError)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "AT\tSTATE\tERROR")
+	for _, transition := range d.Transitions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", transition.At.Format(time.RFC3339), transition.State, transition.Error)
+	}
+	return tw.Flush()
+}
+
+func payDetailsNeedingAttention(ctx context.Context, args []string) error {
+	e, err := setup(flag.NewFlagSet("paydetails attention", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKFLOW ID\tSTAGE\tSINCE\tERROR")
+	var nextPageToken []byte
+	for {
+		resp, err := e.client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Namespace: e.cfg.Temporal.Namespace,
+			Query: fmt.Sprintf("WorkflowType = 'PushPayDetails' AND %s = true AND ExecutionStatus = 'Running'",
+				workflows.NeedsAttention.GetName()),
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return err
+		}
+		for _, execution := range resp.GetExecutions() {
+			workflowID := execution.GetExecution().GetWorkflowId()
+			// Visibility lags a bit, so push may have been resolved already. Status says, so we skip those.
+			value, err := e.client.QueryWorkflow(ctx, workflowID, execution.GetExecution().GetRunId(), workflows.PayDetailsPushStatusQuery)
+			var status workflows.PayDetailsPushStatus
+			if err == nil {
+				err = value.Get(&status)
+			}
+			if err != nil {
+				fmt.Fprintf(tw, "%s\t?\t-\t%s\n", workflowID, err)
+				continue
+			}
+			if status.Stage != workflows.PayDetailsPushStageNeedsAttention && status.Stage != workflows.PayDetailsPushStageEscalated {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", workflowID, status.Stage, status.NeedsAttentionSince.Format(time.RFC3339), status.LastError)
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return tw.Flush()
+		}
+	}
+}
+
+func retryPayDetails(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("paydetails retry", flag.ExitOnError)
+	payslipID := fs.String("payslip", "", "push pay details of this payslip instead, e.g. after they were corrected")
+	e, err := setup(fs, args, "<company-id>", "<payslip-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	workflowID := workflows.PushPayDetailsWorkflowID(workflows.PushPayDetailsInput{CompanyID: e.args[0], PayslipID: e.args[1]})
+	if *payslipID == "" {
+		err = e.client.SignalWorkflow(ctx, workflowID, "", workflows.RetrySignal, nil)
+	} else {
+		err = e.client.SignalWorkflow(ctx, workflowID, "", workflows.RetryWithNewDataSignal, workflows.RetryWithNewData{PayslipID: *payslipID})
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Asked %s to retry\n", workflowID)
+	return nil
+}
+
+func abandonPayDetails(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("paydetails abandon", flag.ExitOnError)
+	reason := fs.String("reason", "", "why pay details won't be pushed, kept in workflow history")
+	e, err := setup(fs, args, "<company-id>", "<payslip-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	workflowID := workflows.PushPayDetailsWorkflowID(workflows.PushPayDetailsInput{CompanyID: e.args[0], PayslipID: e.args[1]})
+	err = e.client.SignalWorkflow(ctx, workflowID, "", workflows.AbandonSignal, workflows.Abandon{Reason: *reason})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Asked %s to abandon push\n", workflowID)
+	return nil
+}
+
+func resyncFromBob(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("bob resync", flag.ExitOnError)
+	wait := fs.Bool("wait", false, "wait until every employee is synced")
+	e, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	input := workflows.SyncDataFromBobInput{FullResync: true}
+	run, err := e.client.ExecuteWorkflow(ctx, workflows.FullResyncFromBobOptions(), workflows.SyncDataFromBob, input)
+	if err != nil {
+		return err
+	}
+	return reportRun(ctx, run, *wait)
+}
+
+func collectBlobs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("claimcheck gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print blobs that would be deleted")
+	grace := fs.Duration("grace", 24*time.Hour, "blobs younger than this are never deleted")
+	e, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	if e.cfg.ClaimCheck.Dir == "" {
+		return errors.New("claim check directory is not configured")
+	}
+	store, err := claimcheck.NewFileStore(e.cfg.ClaimCheck.Dir)
+	if err != nil {
+		return err
+	}
+
+	result, err := claimcheck.Collect(ctx, e.client, e.cfg.Temporal.Namespace, store, *grace, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, key := range result.Deleted {
+		fmt.Println(key)
+	}
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	fmt.Printf("%s %d blob(s). %d blob(s) referenced by %d workflow(s) and %d schedule(s).\n", verb, len(result.Deleted), result.Referenced, result.Workflows, result.Schedules)
+	return nil
+}
+
+func downloadHistories(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("histories download", flag.ExitOnError)
+	query := fs.String("query", "ExecutionStatus = 'Completed'", "visibility query selecting workflows")
+	limit := fs.Int("limit", 20, "max number of histories to download, 0 for no limit")
+	dir := fs.String("dir", "testdata/histories", "where to write histories")
+	e, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	codecs, err := e.cfg.Codecs()
+	if err != nil {
+		return err
+	}
+	files, err := histories.Download(ctx, e.client, histories.DownloadOptions{
+		Namespace: e.cfg.Temporal.Namespace,
+		Query:     *query,
+		Limit:     *limit,
+		Dir:       *dir,
+		Codecs:    codecs,
+	})
+	for _, file := range files {
+		fmt.Println(file)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded %d history(ies)\n", len(files))
+	return nil
+}
+
+func reportRun(ctx context.Context, run client.WorkflowRun, wait bool) error {
+	fmt.Printf("Started workflow %s (run %s)\n", run.GetID(), run.GetRunID())
+	if !wait {
+		return nil
+	}
+	if err := run.Get(ctx, nil); err != nil {
+		return fmt.Errorf("workflow failed: %w", err)
+	}
+	fmt.Println("Workflow completed")
+	return nil
+}
+
+func listSchedules(ctx context.Context, args []string) error {
+	e, err := setup(flag.NewFlagSet("schedules list", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	schedules, err := e.client.ScheduleClient().List(ctx, client.ScheduleListOptions{})
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tWORKFLOW\tPAUSED\tNEXT RUN\tNOTE")
+	for schedules.HasNext() {
+		schedule, err := schedules.Next()
+		if err != nil {
+			return err
+		}
+		nextRun := "-"
+		if len(schedule.NextActionTimes) > 0 {
+			nextRun = schedule.NextActionTimes[0].Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\n", schedule.ID, schedule.WorkflowType.Name, schedule.Paused, nextRun, schedule.Note)
+	}
+	return tw.Flush()
+}
+
+func syncSchedules(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schedules sync", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only print what would change")
+	e, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	reconciler, err := schedules.NewReconciler(e.client.ScheduleClient(), desiredSchedules())
+	if err != nil {
+		return err
+	}
+	plan, err := reconciler.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	plan.Print(os.Stdout)
+	if *dryRun || !plan.HasChanges() {
+		return nil
+	}
+	return reconciler.Apply(ctx, plan)
+}
+
+func pauseSchedule(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schedules pause", flag.ExitOnError)
+	note := fs.String("note", "", "why schedule is paused")
+	e, err := setup(fs, args, "<schedule-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	err = e.client.ScheduleClient().GetHandle(ctx, e.args[0]).Pause(ctx, client.SchedulePauseOptions{Note: *note})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Paused schedule %s\n", e.args[0])
+	return nil
+}
+
+func unpauseSchedule(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schedules unpause", flag.ExitOnError)
+	note := fs.String("note", "", "why schedule is unpaused")
+	e, err := setup(fs, args, "<schedule-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	err = e.client.ScheduleClient().GetHandle(ctx, e.args[0]).Unpause(ctx, client.ScheduleUnpauseOptions{Note: *note})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Unpaused schedule %s\n", e.args[0])
+	return nil
+}
+
+func triggerSchedule(ctx context.Context, args []string) error {
+	e, err := setup(flag.NewFlagSet("schedules trigger", flag.ExitOnError), args, "<schedule-id>")
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	// Overlap policy of the schedule applies. Triggering while previous run is in progress might be skipped.
+	err = e.client.ScheduleClient().GetHandle(ctx, e.args[0]).Trigger(ctx, client.ScheduleTriggerOptions{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Triggered schedule %s\n", e.args[0])
+	return nil
+}
@@ -0,0 +1,577 @@
+package config
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/chaos"
+	"temporal-poc/claimcheck"
+	"temporal-poc/codec"
+	"temporal-poc/delivery"
+	"temporal-poc/employees"
+	"temporal-poc/hmrc"
+	"temporal-poc/logging"
+	"temporal-poc/workflows"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/worker"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Temporal    Temporal    `yaml:"temporal"`
+	ClaimCheck  ClaimCheck  `yaml:"claim_check"`
+	Deliveries  Deliveries  `yaml:"deliveries"`
+	Employees   Employees   `yaml:"employees"`
+	Worker      Worker      `yaml:"worker"`
+	Log         Log         `yaml:"log"`
+	Metrics     Metrics     `yaml:"metrics"`
+	Chaos       Chaos       `yaml:"chaos"`
+	Bob         Bob         `yaml:"bob"`
+	HMRC        HMRC        `yaml:"hmrc"`
+	CodecServer CodecServer `yaml:"codec_server"`
+}
+
+type Temporal struct {
+	Address   string `yaml:"address"`
+	Namespace string `yaml:"namespace"`
+	TLS       TLS    `yaml:"tls"`
+	// EncryptionKeyFile enables payload encryption. See codec.Keys for the format.
+	EncryptionKeyFile string `yaml:"encryption_key_file"`
+}
+
+// TLS is used only when a client certificate is configured. CA and server name are optional on top of that.
+type TLS struct {
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+// ClaimCheck moves payloads bigger than threshold out of workflow history, into a blob store.
+// It's enabled only when directory is set.
+type ClaimCheck struct {
+	Dir            string `yaml:"dir"`
+	ThresholdBytes int    `yaml:"threshold_bytes"`
+}
+
+// Deliveries of pay details are kept in memory of the worker, unless directory is set. API and CLI can read them only
+// from the directory.
+type Deliveries struct {
+	Dir string `yaml:"dir"`
+}
+
+// Employees synced from Bob are kept in memory of the worker, unless directory is set. Restarted worker then has none
+// of them, and has to sync everyone again.
+type Employees struct {
+	Dir string `yaml:"dir"`
+}
+
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type Metrics struct {
+	// Listen is where worker serves Prometheus metrics on /metrics. Empty disables the endpoint.
+	Listen string `yaml:"listen"`
+}
+
+// Bob is faked when URL is not set.
+type Bob struct {
+	URL           string `yaml:"url"`
+	ServiceUserID string `yaml:"service_user_id"`
+	Token         string `yaml:"token"`
+	// WebhookSecret verifies signatures of Bob webhooks. API doesn't receive them without it.
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// HMRC credentials are of the Government Gateway account submitting RTI documents.
+type HMRC struct {
+	SenderID string `yaml:"sender_id"`
+	Password string `yaml:"password"`
+	// Mode is live, test-in-live or test. See hmrc.Mode.
+	Mode     hmrc.Mode `yaml:"mode"`
+	VendorID string    `yaml:"vendor_id"`
+	// FakeDir keeps submissions of fake HMRC, so it answers polls of FPS submitted before worker restarted.
+	FakeDir string `yaml:"fake_dir"`
+}
+
+// CodecServer decodes payloads for Temporal UI.
+type CodecServer struct {
+	// Tokens UI can pass as access token. Without any, anyone who can reach codec server can decrypt payloads.
+	Tokens []string `yaml:"tokens"`
+	// AllowedOrigins of Temporal UI.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// Chaos injects faults into activities, so retries and failure handling can be seen in action.
+type Chaos struct {
+	Enabled bool `yaml:"enabled"`
+	// Seed makes faults reproducible. Zero picks a random one, which is logged on worker start.
+	Seed uint64 `yaml:"seed"`
+	// Activities maps activity type to its faults. chaos.AnyActivity ("*") applies to all the others.
+	Activities map[string]chaos.Fault `yaml:"activities"`
+}
+
+type Worker struct {
+	// Queues polled by this process. Same binary can run all of them, or just a subset.
+	Queues []string `yaml:"queues"`
+	// Defaults apply to every queue. Anything set in QueueOptions takes precedence.
+	Defaults     QueueOptions            `yaml:"defaults"`
+	QueueOptions map[string]QueueOptions `yaml:"queue_options"`
+}
+
+// QueueOptions tune a single worker. Zero means "not set", and SDK default is used.
+type QueueOptions struct {
+	MaxConcurrentActivities    int `yaml:"max_concurrent_activities"`
+	MaxConcurrentWorkflowTasks int `yaml:"max_concurrent_workflow_tasks"`
+	// ActivitiesPerSecond limits a single worker process.
+	ActivitiesPerSecond float64 `yaml:"activities_per_second"`
+	// TaskQueueActivitiesPerSecond is enforced by server, across all workers polling the queue.
+	TaskQueueActivitiesPerSecond float64 `yaml:"task_queue_activities_per_second"`
+	ActivityPollers              int     `yaml:"activity_pollers"`
+	WorkflowPollers              int     `yaml:"workflow_pollers"`
+}
+
+// Default is what you get when running locally with docker compose.
+func Default() Config {
+	return Config{
+		Temporal: Temporal{
+			Address:   client.DefaultHostPort,
+			Namespace: client.DefaultNamespace,
+		},
+		Metrics: Metrics{
+			Listen: ":2112",
+		},
+		CodecServer: CodecServer{
+			AllowedOrigins: []string{"http://localhost:8080"},
+		},
+		// Nothing reaches HMRC, unless asked for.
+		HMRC: HMRC{
+			Mode: hmrc.ModeTest,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		ClaimCheck: ClaimCheck{
+			// Temporal warns about payloads from 256KB. We want to stay well below that.
+			ThresholdBytes: 64 * 1024,
+		},
+		// Activities used to fail this way on their own. It's a PoC after all, failures are there to be seen.
+		Chaos: Chaos{
+			Enabled: true,
+			Activities: map[string]chaos.Fault{
+				"SubmitFPS":                        {Latency: 3 * time.Second, ErrorRate: 0.3},
+				"PollFPS":                          {Latency: time.Second, ErrorRate: 0.3},
+				"SchedulePayment":                  {Latency: time.Second, ErrorRate: 0.3},
+				"IsPaymentPaid":                    {Latency: time.Second, ErrorRate: 0.3},
+				"ReconcileInAccountingIntegration": {Latency: time.Second},
+				"PushPayDetailsToBob":              {Latency: time.Second, ErrorRate: 0.3},
+			},
+		},
+		Worker: Worker{
+			Queues: slices.Clone(workflows.AllTaskQueues),
+			QueueOptions: map[string]QueueOptions{
+				// Bob won't be happy if we call it too often.
+				workflows.TaskQueueBobSync: {TaskQueueActivitiesPerSecond: 5},
+				// Reporting FPS takes a while. There is no point in having many of them waiting on HMRC.
+				workflows.TaskQueuePayroll: {MaxConcurrentActivities: 10},
+				// Bank API limits are shared between all workers.
+				workflows.TaskQueuePayments:  {TaskQueueActivitiesPerSecond: 20},
+				workflows.TaskQueueDocuments: {TaskQueueActivitiesPerSecond: 10},
+			},
+		},
+	}
+}
+
+// field describes a single setting, and how it can be overridden by environment variable and flag.
+type field struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var fields = []field{
+	{"address", "TEMPORAL_ADDRESS", "Temporal frontend host:port", setString(func(c *Config) *string { return &c.Temporal.Address })},
+	{"namespace", "TEMPORAL_NAMESPACE", "Temporal namespace", setString(func(c *Config) *string { return &c.Temporal.Namespace })},
+	{"tls-cert", "TEMPORAL_TLS_CERT", "path to client certificate", setString(func(c *Config) *string { return &c.Temporal.TLS.CertFile })},
+	{"tls-key", "TEMPORAL_TLS_KEY", "path to client private key", setString(func(c *Config) *string { return &c.Temporal.TLS.KeyFile })},
+	{"tls-ca", "TEMPORAL_TLS_CA", "path to server CA certificate", setString(func(c *Config) *string { return &c.Temporal.TLS.CAFile })},
+	{"tls-server-name", "TEMPORAL_TLS_SERVER_NAME", "override server name used to verify certificate", setString(func(c *Config) *string { return &c.Temporal.TLS.ServerName })},
+	{"encryption-keys", "TEMPORAL_ENCRYPTION_KEY_FILE", "path to payload encryption key file, encryption is off without it", setString(func(c *Config) *string { return &c.Temporal.EncryptionKeyFile })},
+	{"claim-check-dir", "CLAIM_CHECK_DIR", "directory for payloads too big for history, claim check is off without it", setString(func(c *Config) *string { return &c.ClaimCheck.Dir })},
+	{"claim-check-threshold", "CLAIM_CHECK_THRESHOLD", "size in bytes above which payloads are moved out of history", setInt(func(c *Config) *int { return &c.ClaimCheck.ThresholdBytes })},
+	{"deliveries-dir", "DELIVERIES_DIR", "directory for delivery status of pay details, worker keeps it in memory without it", setString(func(c *Config) *string { return &c.Deliveries.Dir })},
+	{"employees-dir", "EMPLOYEES_DIR", "directory for employees synced from Bob, worker keeps them in memory without it", setString(func(c *Config) *string { return &c.Employees.Dir })},
+	{"log-level", "LOG_LEVEL", "debug, info, warn or error", setString(func(c *Config) *string { return &c.Log.Level })},
+	{"log-format", "LOG_FORMAT", "json or text", setString(func(c *Config) *string { return &c.Log.Format })},
+	{"metrics-listen", "METRICS_LISTEN", "address worker serves Prometheus metrics on, empty disables them", setString(func(c *Config) *string { return &c.Metrics.Listen })},
+	{"bob-url", "BOB_URL", "Bob API URL, fake Bob is used without it", setString(func(c *Config) *string { return &c.Bob.URL })},
+	{"bob-service-user", "BOB_SERVICE_USER_ID", "ID of Bob service user", setString(func(c *Config) *string { return &c.Bob.ServiceUserID })},
+	{"bob-token", "BOB_TOKEN", "token of Bob service user, prefer env over flag", setString(func(c *Config) *string { return &c.Bob.Token })},
+	{"bob-webhook-secret", "BOB_WEBHOOK_SECRET", "secret of Bob webhooks, API doesn't receive them without it", setString(func(c *Config) *string { return &c.Bob.WebhookSecret })},
+	{"hmrc-sender-id", "HMRC_SENDER_ID", "Government Gateway sender ID", setString(func(c *Config) *string { return &c.HMRC.SenderID })},
+	{"hmrc-password", "HMRC_PASSWORD", "Government Gateway password, prefer env over flag", setString(func(c *Config) *string { return &c.HMRC.Password })},
+	{"hmrc-mode", "HMRC_MODE", "live, test-in-live or test", setString(func(c *Config) *string { return (*string)(&c.HMRC.Mode) })},
+	{"hmrc-vendor-id", "HMRC_VENDOR_ID", "vendor ID HMRC issued to our software", setString(func(c *Config) *string { return &c.HMRC.VendorID })},
+	{"hmrc-fake-dir", "HMRC_FAKE_DIR", "directory for FPS submitted to fake HMRC, it forgets them on restart without it", setString(func(c *Config) *string { return &c.HMRC.FakeDir })},
+	{"codec-server-tokens", "CODEC_SERVER_TOKENS", "comma separated tokens UI passes to codec server, prefer env over flag", setList(func(c *Config) *[]string { return &c.CodecServer.Tokens })},
+	{"cors-origins", "CODEC_SERVER_CORS_ORIGINS", "comma separated origins of Temporal UI, allowed by codec server", setList(func(c *Config) *[]string { return &c.CodecServer.AllowedOrigins })},
+	{"chaos", "CHAOS_ENABLED", "true or false, whether to inject faults into activities", setBool(func(c *Config) *bool { return &c.Chaos.Enabled })},
+	{"chaos-seed", "CHAOS_SEED", "seed of injected faults, 0 picks a random one", setUint(func(c *Config) *uint64 { return &c.Chaos.Seed })},
+	{"queues", "WORKER_QUEUES", "comma separated task queues to poll: " + strings.Join(workflows.AllTaskQueues, ","), setList(func(c *Config) *[]string { return &c.Worker.Queues })},
+	{"max-concurrent-activities", "WORKER_MAX_CONCURRENT_ACTIVITIES", "max activities executed at once per queue, unless queue sets its own", setInt(func(c *Config) *int { return &c.Worker.Defaults.MaxConcurrentActivities })},
+	{"max-concurrent-workflow-tasks", "WORKER_MAX_CONCURRENT_WORKFLOW_TASKS", "max workflow tasks executed at once per queue, unless queue sets its own", setInt(func(c *Config) *int { return &c.Worker.Defaults.MaxConcurrentWorkflowTasks })},
+}
+
+// Load registers flags on fs, parses args and builds config. Each source overrides the previous one:
+// defaults, YAML file (-config or CONFIG_FILE), environment variables, flags.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (Config, error) {
+	path := fs.String("config", getenv("CONFIG_FILE"), "path to YAML config file")
+
+	// Flags are applied last, so we only record them while parsing.
+	var fromFlags []func(*Config) error
+	for _, f := range fields {
+		fs.Func(f.flag, f.usage+" (env "+f.env+")", func(value string) error {
+			// Fail early on malformed values, so flag package can print usage.
+			if err := f.set(&Config{}, value); err != nil {
+				return err
+			}
+			fromFlags = append(fromFlags, func(c *Config) error { return f.set(c, value) })
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, f := range fields {
+		value := getenv(f.env)
+		if value == "" {
+			continue
+		}
+		if err := f.set(&cfg, value); err != nil {
+			return Config{}, fmt.Errorf("env %s: %w", f.env, err)
+		}
+	}
+	for _, apply := range fromFlags {
+		if err := apply(&cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// Typos in config file should not be silently ignored.
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Temporal.Address == "" {
+		errs = append(errs, errors.New("temporal address is required"))
+	}
+	if c.Temporal.Namespace == "" {
+		errs = append(errs, errors.New("temporal namespace is required"))
+	}
+	tlsConfig := c.Temporal.TLS
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		errs = append(errs, errors.New("TLS cert and key have to be provided together"))
+	}
+	if tlsConfig.CertFile == "" && (tlsConfig.CAFile != "" || tlsConfig.ServerName != "") {
+		errs = append(errs, errors.New("TLS CA and server name require TLS cert and key"))
+	}
+	if _, err := c.Logger(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Bob.URL != "" && (c.Bob.ServiceUserID == "" || c.Bob.Token == "") {
+		errs = append(errs, errors.New("bob service user ID and token are required with bob URL"))
+	}
+	if !c.HMRC.Mode.Valid() {
+		errs = append(errs, fmt.Errorf("unknown HMRC mode %q", c.HMRC.Mode))
+	}
+	if c.ClaimCheck.ThresholdBytes <= 0 {
+		errs = append(errs, errors.New("claim check threshold has to be positive"))
+	}
+	if len(c.Worker.Queues) == 0 {
+		errs = append(errs, errors.New("at least one task queue is required"))
+	}
+	for _, queue := range c.Worker.Queues {
+		if !slices.Contains(workflows.AllTaskQueues, queue) {
+			errs = append(errs, fmt.Errorf("unknown task queue %q", queue))
+		}
+	}
+	if err := c.Worker.Defaults.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("worker defaults: %w", err))
+	}
+	for queue, options := range c.Worker.QueueOptions {
+		if !slices.Contains(workflows.AllTaskQueues, queue) {
+			errs = append(errs, fmt.Errorf("options for unknown task queue %q", queue))
+		}
+		if err := options.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("task queue %q: %w", queue, err))
+		}
+	}
+	for activityType, fault := range c.Chaos.Activities {
+		if err := fault.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("chaos of %q activity: %w", activityType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o QueueOptions) validate() error {
+	if o.MaxConcurrentActivities < 0 || o.MaxConcurrentWorkflowTasks < 0 || o.ActivityPollers < 0 || o.WorkflowPollers < 0 {
+		return errors.New("concurrency limits can't be negative")
+	}
+	if o.ActivitiesPerSecond < 0 || o.TaskQueueActivitiesPerSecond < 0 {
+		return errors.New("rate limits can't be negative")
+	}
+	return nil
+}
+
+func (c Config) ClientOptions() (client.Options, error) {
+	logger, err := c.Logger()
+	if err != nil {
+		return client.Options{}, err
+	}
+	options := client.Options{
+		HostPort:  c.Temporal.Address,
+		Namespace: c.Temporal.Namespace,
+		Logger:    log.NewStructuredLogger(logger),
+	}
+	tlsConfig, err := c.Temporal.TLS.load()
+	if err != nil {
+		return client.Options{}, err
+	}
+	options.ConnectionOptions.TLS = tlsConfig
+
+	codecs, err := c.Codecs()
+	if err != nil {
+		return client.Options{}, err
+	}
+	if len(codecs) > 0 {
+		options.DataConverter = converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), codecs...)
+	}
+	return options, nil
+}
+
+// Logger writes to stderr. Stdout is left for output of CLI commands.
+func (c Config) Logger() (*slog.Logger, error) {
+	return logging.New(os.Stderr, c.Log.Level, c.Log.Format)
+}
+
+// Codecs are ordered as data converter expects them: payloads are encrypted first, and only then claim-checked.
+// This way, blobs are encrypted as well.
+func (c Config) Codecs() ([]converter.PayloadCodec, error) {
+	var codecs []converter.PayloadCodec
+	if c.ClaimCheck.Dir != "" {
+		store, err := claimcheck.NewFileStore(c.ClaimCheck.Dir)
+		if err != nil {
+			return nil, err
+		}
+		codecs = append(codecs, claimcheck.NewCodec(store, c.ClaimCheck.ThresholdBytes))
+	}
+
+	encryption, err := c.Encryption()
+	if err != nil {
+		return nil, err
+	}
+	if encryption != nil {
+		codecs = append(codecs, encryption)
+	}
+	return codecs, nil
+}
+
+// Encryption returns nil if payload encryption is not configured.
+func (c Config) Encryption() (*codec.Encryption, error) {
+	if c.Temporal.EncryptionKeyFile == "" {
+		return nil, nil
+	}
+	keys, err := codec.LoadKeys(c.Temporal.EncryptionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return codec.NewEncryption(keys)
+}
+
+// WorkerOptions for a single task queue: queue's own options, with worker-wide defaults filling the gaps.
+func (c Config) WorkerOptions(queue string) worker.Options {
+	o := c.Worker.QueueOptions[queue]
+	d := c.Worker.Defaults
+	return worker.Options{
+		MaxConcurrentActivityExecutionSize:     cmp.Or(o.MaxConcurrentActivities, d.MaxConcurrentActivities),
+		MaxConcurrentWorkflowTaskExecutionSize: cmp.Or(o.MaxConcurrentWorkflowTasks, d.MaxConcurrentWorkflowTasks),
+		WorkerActivitiesPerSecond:              cmp.Or(o.ActivitiesPerSecond, d.ActivitiesPerSecond),
+		TaskQueueActivitiesPerSecond:           cmp.Or(o.TaskQueueActivitiesPerSecond, d.TaskQueueActivitiesPerSecond),
+		MaxConcurrentActivityTaskPollers:       cmp.Or(o.ActivityPollers, d.ActivityPollers),
+		MaxConcurrentWorkflowTaskPollers:       cmp.Or(o.WorkflowPollers, d.WorkflowPollers),
+	}
+}
+
+// BobClient returns fake Bob, unless URL is configured.
+func (c Config) BobClient() (workflows.BobClient, error) {
+	if c.Bob.URL == "" {
+		return bob.NewFake(), nil
+	}
+	return bob.NewClient(bob.Options{
+		URL:           c.Bob.URL,
+		ServiceUserID: c.Bob.ServiceUserID,
+		Token:         c.Bob.Token,
+	})
+}
+
+func (c Config) DeliveryStore() (delivery.Store, error) {
+	if c.Deliveries.Dir == "" {
+		return delivery.NewMemoryStore(), nil
+	}
+	return delivery.NewFileStore(c.Deliveries.Dir)
+}
+
+func (c Config) EmployeeStore() (employees.Store, error) {
+	if c.Employees.Dir == "" {
+		return employees.NewMemoryStore(), nil
+	}
+	return employees.NewFileStore(c.Employees.Dir)
+}
+
+func (c Config) GovTalkOptions() hmrc.Options {
+	return hmrc.Options{
+		SenderID:       c.HMRC.SenderID,
+		Password:       c.HMRC.Password,
+		Mode:           c.HMRC.Mode,
+		VendorID:       c.HMRC.VendorID,
+		Product:        "temporal-poc",
+		ProductVersion: "1.0",
+	}
+}
+
+// FakeHMRC is what worker submits FPS to, until we talk to the real one.
+func (c Config) FakeHMRC() *workflows.FakeHMRC {
+	fake := workflows.NewFakeHMRC()
+	fake.Dir = c.HMRC.FakeDir
+	return fake
+}
+
+// ChaosInterceptor returns nil if chaos is disabled.
+func (c Config) ChaosInterceptor() *chaos.Interceptor {
+	if !c.Chaos.Enabled {
+		return nil
+	}
+	return chaos.NewInterceptor(c.Chaos.Seed, c.Chaos.Activities)
+}
+
+func (t TLS) load() (*tls.Config, error) {
+	if t.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS key pair: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ServerName:   t.ServerName,
+	}
+	if t.CAFile != "" {
+		ca, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func setString(target func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*target(c) = value
+		return nil
+	}
+}
+
+func setInt(target func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*target(c) = i
+		return nil
+	}
+}
+
+func setUint(target func(*Config) *uint64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		*target(c) = i
+		return nil
+	}
+}
+
+func setBool(target func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*target(c) = b
+		return nil
+	}
+}
+
+func setList(target func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target(c) = list
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"temporal-poc/chaos"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+
+	env map[string]string
+}
+
+func TestConfig(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (s *ConfigTestSuite) SetupTest() {
+	s.env = map[string]string{}
+}
+
+func (s *ConfigTestSuite) load(args ...string) (Config, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args, func(name string) string { return s.env[name] })
+}
+
+// file writes YAML config, and returns its path.
+func (s *ConfigTestSuite) file(yaml string) string {
+	path := filepath.Join(s.T().TempDir(), "config.yaml")
+	s.Require().NoError(os.WriteFile(path, []byte(yaml), 0o600))
+	return path
+}
+
+func (s *ConfigTestSuite) Test_LoadsDefaults_WhenNothingIsSet() {
+	cfg, err := s.load()
+
+	s.Require().NoError(err)
+	s.Equal(Default(), cfg)
+}
+
+func (s *ConfigTestSuite) Test_EachSourceOverridesThePreviousOne() {
+	path := s.file(`
+temporal:
+  address: yaml:7233
+  namespace: yaml
+log:
+  level: warn
+chaos:
+  activities:
+    SubmitFPS:
+      error_rate: 0.1
+`)
+	s.env["CONFIG_FILE"] = path
+	s.env["TEMPORAL_ADDRESS"] = "env:7233"
+	s.env["LOG_LEVEL"] = "error"
+
+	cfg, err := s.load("-log-level", "debug")
+
+	s.Require().NoError(err)
+	s.Equal("env:7233", cfg.Temporal.Address)
+	s.Equal("yaml", cfg.Temporal.Namespace)
+	s.Equal("debug", cfg.Log.Level)
+	s.Equal(Default().Log.Format, cfg.Log.Format)
+	// Activity listed in the file replaces its built-in faults, the rest are kept.
+	s.Equal(chaos.Fault{ErrorRate: 0.1}, cfg.Chaos.Activities["SubmitFPS"])
+	s.Equal(Default().Chaos.Activities["PollFPS"], cfg.Chaos.Activities["PollFPS"])
+}
+
+func (s *ConfigTestSuite) Test_FlagPointsToConfigFile() {
+	s.env["CONFIG_FILE"] = s.file("temporal:\n  namespace: from-env-file\n")
+
+	cfg, err := s.load("-config", s.file("temporal:\n  namespace: from-flag-file\n"))
+
+	s.Require().NoError(err)
+	s.Equal("from-flag-file", cfg.Temporal.Namespace)
+}
+
+func (s *ConfigTestSuite) Test_LoadsExampleConfig() {
+	cfg, err := s.load("-config", "../config.example.yaml")
+
+	s.Require().NoError(err)
+	s.Equal(time.Second, cfg.Chaos.Activities["PushPayDetailsToBob"].Latency)
+}
+
+func (s *ConfigTestSuite) Test_ParsesValuesOfEachType() {
+	s.env["WORKER_QUEUES"] = " payroll, ,bob-sync "
+	s.env["CHAOS_ENABLED"] = "false"
+	s.env["CHAOS_SEED"] = "42"
+	s.env["CODEC_SERVER_TOKENS"] = "token-1,token-2"
+
+	cfg, err := s.load("-claim-check-threshold", "1024")
+
+	s.Require().NoError(err)
+	s.Equal([]string{"payroll", "bob-sync"}, cfg.Worker.Queues)
+	s.False(cfg.Chaos.Enabled)
+	s.Equal(uint64(42), cfg.Chaos.Seed)
+	s.Equal(1024, cfg.ClaimCheck.ThresholdBytes)
+	s.Equal([]string{"token-1", "token-2"}, cfg.CodecServer.Tokens)
+	s.Equal(Default().CodecServer.AllowedOrigins, cfg.CodecServer.AllowedOrigins)
+}
+
+// Test_EveryFieldSetsSomething catches fields pointing at the wrong setting, or sharing flag or env with another.
+func (s *ConfigTestSuite) Test_EveryFieldSetsSomething() {
+	flags, envs := map[string]bool{}, map[string]bool{}
+	for _, f := range fields {
+		s.Run(f.flag, func() {
+			s.False(flags[f.flag], "flag is used twice")
+			s.False(envs[f.env], "env is used twice")
+			flags[f.flag], envs[f.env] = true, true
+
+			cfg := Default()
+			for _, value := range []string{"payroll", "7", "false"} {
+				if f.set(&cfg, value) == nil {
+					break
+				}
+			}
+			s.False(reflect.DeepEqual(Default(), cfg), "nothing changed")
+		})
+	}
+}
+
+func (s *ConfigTestSuite) Test_RejectsMalformedValues() {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{name: "env", env: map[string]string{"CHAOS_SEED": "-1"}, err: `env CHAOS_SEED: "-1" is not a whole number`},
+		{name: "flag", args: []string{"-chaos", "maybe"}, err: `"maybe" is not true or false`},
+		{name: "unknown field in file", args: []string{"-config", s.file("temporal:\n  adress: typo:7233\n")}, err: "field adress not found"},
+		{name: "missing file", args: []string{"-config", "missing.yaml"}, err: "reading config file"},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.env = test.env
+
+			_, err := s.load(test.args...)
+
+			s.ErrorContains(err, test.err)
+		})
+	}
+}
+
+func (s *ConfigTestSuite) Test_Validate() {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		err    string
+	}{
+		{name: "no address", change: func(c *Config) { c.Temporal.Address = "" }, err: "temporal address is required"},
+		{name: "TLS key without cert", change: func(c *Config) { c.Temporal.TLS.KeyFile = "key.pem" }, err: "TLS cert and key have to be provided together"},
+		{name: "bad log level", change: func(c *Config) { c.Log.Level = "loud" }, err: "loud"},
+		{name: "bob without credentials", change: func(c *Config) { c.Bob.URL = "https://bob.example" }, err: "bob service user ID and token are required with bob URL"},
+		{name: "unknown HMRC mode", change: func(c *Config) { c.HMRC.Mode = "production" }, err: `unknown HMRC mode "production"`},
+		{name: "no queues", change: func(c *Config) { c.Worker.Queues = nil }, err: "at least one task queue is required"},
+		{name: "unknown queue", change: func(c *Config) { c.Worker.Queues = []string{"payroll", "payrol"} }, err: `unknown task queue "payrol"`},
+		{name: "options of unknown queue", change: func(c *Config) { c.Worker.QueueOptions["payrol"] = QueueOptions{} }, err: `options for unknown task queue "payrol"`},
+		{name: "negative concurrency", change: func(c *Config) { c.Worker.Defaults.ActivityPollers = -1 }, err: "worker defaults: concurrency limits can't be negative"},
+		{name: "negative rate limit", change: func(c *Config) { c.Worker.QueueOptions["payroll"] = QueueOptions{ActivitiesPerSecond: -1} }, err: `task queue "payroll": rate limits can't be negative`},
+		{name: "chaos rate above 1", change: func(c *Config) { c.Chaos.Activities["SubmitFPS"] = chaos.Fault{ErrorRate: 1.5} }, err: `chaos of "SubmitFPS" activity: rates have to be between 0 and 1`},
+		{name: "chaos rates adding up above 1", change: func(c *Config) { c.Chaos.Activities["*"] = chaos.Fault{ErrorRate: 0.6, TimeoutRate: 0.6} }, err: `chaos of "*" activity: rates can't add up to more than 1`},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			cfg := Default()
+			test.change(&cfg)
+
+			s.ErrorContains(cfg.Validate(), test.err)
+		})
+	}
+}
+
+func (s *ConfigTestSuite) Test_Validate_ReportsEveryProblem() {
+	s.env["WORKER_QUEUES"] = "unknown"
+	s.env["HMRC_MODE"] = "production"
+
+	_, err := s.load()
+
+	s.ErrorContains(err, `unknown task queue "unknown"`)
+	s.ErrorContains(err, `unknown HMRC mode "production"`)
+}
@@ -0,0 +1,94 @@
+// Package delivery keeps track of what happened to pay details pushed to Bob, so customers can see it.
+package delivery
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("delivery not found")
+	ErrInvalidRef = errors.New("company and payslip ID are required, and can't be . or ..")
+)
+
+type State string
+
+const (
+	StateSending State = "sending"
+	// StateAttemptFailed means Bob didn't take pay details. Push may still be retried.
+	StateAttemptFailed State = "attempt-failed"
+	StateSent          State = "sent"
+	StateFailed        State = "failed"
+	// StateSuperseded means pay details of another payslip were pushed instead, and these never will be.
+	StateSuperseded State = "superseded"
+	// StateAbandoned means an operator gave up on pushing pay details, Transition.Reason tells why.
+	StateAbandoned State = "abandoned"
+)
+
+// Ref identifies delivery of pay details of a payslip.
+type Ref struct {
+	CompanyID string
+	PayslipID string
+}
+
+func (r Ref) valid() bool {
+	for _, id := range []string{r.CompanyID, r.PayslipID} {
+		if id == "" || id == "." || id == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+type Transition struct {
+	State State     `json:"state"`
+	At    time.Time `json:"at"`
+	// Error tells why Bob didn't take pay details.
+	Error string `json:"error,omitempty"`
+	// Reason is given by operator abandoning push.
+	Reason string `json:"reason,omitempty"`
+}
+
+type Delivery struct {
+	CompanyID string `json:"company_id"`
+	PayslipID string `json:"payslip_id"`
+	State     State  `json:"state"`
+	// Attempts counts pushes that Bob took, or that failed.
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Transitions, oldest first.
+	Transitions []Transition `json:"transitions"`
+}
+
+// Store is implemented by MemoryStore, and FileStore.
+type Store interface {
+	// Record appends transition to delivery, creating it if needed.
+	Record(ctx context.Context, ref Ref, transition Transition) error
+	// Get returns ErrNotFound if nothing was recorded for the payslip yet.
+	Get(ctx context.Context, ref Ref) (Delivery, error)
+}
+
+func (d *Delivery) apply(transition Transition) {
+	// Activity retried after it recorded, but before it completed, records the same transition again. Failed attempts
+	// are the exception, they repeat on their own.
+	if n := len(d.Transitions); n > 0 && transition.State != StateAttemptFailed {
+		if last := d.Transitions[n-1]; last.State == transition.State && last.Error == transition.Error {
+			return
+		}
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = transition.At
+	}
+	d.State = transition.State
+	d.UpdatedAt = transition.At
+	if transition.Error != "" {
+		d.Attempts++
+		d.LastError = transition.Error
+	} else if transition.State == StateSent {
+		d.Attempts++
+	}
+	d.Transitions = append(d.Transitions, transition)
+}
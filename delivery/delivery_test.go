@@ -0,0 +1,135 @@
+package delivery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// StoreTestSuite runs against every Store, they have to behave the same.
+type StoreTestSuite struct {
+	suite.Suite
+
+	ctx      context.Context
+	newStore func(t *testing.T) Store
+	store    Store
+}
+
+func TestMemoryStore(t *testing.T) {
+	suite.Run(t, &StoreTestSuite{newStore: func(*testing.T) Store { return NewMemoryStore() }})
+}
+
+func TestFileStore(t *testing.T) {
+	suite.Run(t, &StoreTestSuite{newStore: func(t *testing.T) Store {
+		store, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}})
+}
+
+var (
+	ref       = Ref{CompanyID: "company-1", PayslipID: "payslip-1"}
+	startedAt = time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+)
+
+func (s *StoreTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.store = s.newStore(s.T())
+}
+
+func (s *StoreTestSuite) record(state State, after time.Duration, err string) {
+	s.Require().NoError(s.store.Record(s.ctx, ref, Transition{State: state, At: startedAt.Add(after), Error: err}))
+}
+
+func (s *StoreTestSuite) Test_RecordsTransitions() {
+	s.record(StateSending, 0, "")
+	s.record(StateAttemptFailed, time.Second, "bob is down")
+	s.record(StateSent, 3*time.Second, "")
+
+	d, err := s.store.Get(s.ctx, ref)
+
+	s.Require().NoError(err)
+	s.Equal(StateSent, d.State)
+	s.Equal(2, d.Attempts)
+	s.Equal("bob is down", d.LastError)
+	s.Equal(startedAt, d.CreatedAt)
+	s.Equal(startedAt.Add(3*time.Second), d.UpdatedAt)
+	s.Equal([]Transition{
+		{State: StateSending, At: startedAt},
+		{State: StateAttemptFailed, At: startedAt.Add(time.Second), Error: "bob is down"},
+		{State: StateSent, At: startedAt.Add(3 * time.Second)},
+	}, d.Transitions)
+}
+
+func (s *StoreTestSuite) Test_IgnoresRepeatedTransition() {
+	s.record(StateSent, 0, "")
+	s.record(StateSent, time.Second, "")
+
+	d, err := s.store.Get(s.ctx, ref)
+
+	s.Require().NoError(err)
+	s.Equal(1, d.Attempts)
+	s.Len(d.Transitions, 1)
+}
+
+func (s *StoreTestSuite) Test_IgnoresRepeatedFailure() {
+	s.record(StateFailed, 0, "payslip rejected")
+	s.record(StateFailed, time.Second, "payslip rejected")
+
+	d, err := s.store.Get(s.ctx, ref)
+
+	s.Require().NoError(err)
+	s.Equal(1, d.Attempts)
+}
+
+func (s *StoreTestSuite) Test_CountsEveryFailedAttempt() {
+	for i := range 3 {
+		s.record(StateAttemptFailed, time.Duration(i)*time.Second, "bob is down")
+	}
+
+	d, err := s.store.Get(s.ctx, ref)
+
+	s.Require().NoError(err)
+	s.Equal(3, d.Attempts)
+}
+
+func (s *StoreTestSuite) Test_AbandoningIsNotAnAttempt() {
+	s.record(StateAttemptFailed, 0, "bob is down")
+	abandoned := Transition{State: StateAbandoned, At: startedAt.Add(time.Hour), Reason: "employee left"}
+	s.Require().NoError(s.store.Record(s.ctx, ref, abandoned))
+
+	d, err := s.store.Get(s.ctx, ref)
+
+	s.Require().NoError(err)
+	s.Equal(StateAbandoned, d.State)
+	s.Equal(1, d.Attempts)
+	s.Equal("bob is down", d.LastError)
+	s.Equal("employee left", d.Transitions[1].Reason)
+}
+
+func (s *StoreTestSuite) Test_ReturnsNotFound() {
+	_, err := s.store.Get(s.ctx, ref)
+
+	s.ErrorIs(err, ErrNotFound)
+}
+
+func (s *StoreTestSuite) Test_RejectsRefsThatCouldEscapeDirectory() {
+	err := s.store.Record(s.ctx, Ref{CompanyID: "..", PayslipID: "payslip-1"}, Transition{State: StateSent})
+
+	s.ErrorIs(err, ErrInvalidRef)
+}
+
+func (s *StoreTestSuite) Test_KeepsPayslipsOfCompanyApart() {
+	s.record(StateSent, 0, "")
+	other := Ref{CompanyID: "company-1", PayslipID: "payslip/2"}
+	s.Require().NoError(s.store.Record(s.ctx, other, Transition{State: StateFailed, At: startedAt}))
+
+	d, err := s.store.Get(s.ctx, other)
+
+	s.Require().NoError(err)
+	s.Equal(StateFailed, d.State)
+}
@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore keeps every delivery as a JSON file in a local directory, so API and CLI can read what worker recorded.
+// Updates are serialised within a process only. Outside of local development, there should be a database instead.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating delivery directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Record(ctx context.Context, ref Ref, transition Transition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.Get(ctx, ref)
+	if errors.Is(err, ErrNotFound) {
+		d = Delivery{CompanyID: ref.CompanyID, PayslipID: ref.PayslipID}
+	} else if err != nil {
+		return err
+	}
+	d.apply(transition)
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	path := s.path(ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// Write and rename, so nobody can read half-written delivery.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Get(_ context.Context, ref Ref) (Delivery, error) {
+	if !ref.valid() {
+		return Delivery{}, ErrInvalidRef
+	}
+	data, err := os.ReadFile(s.path(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Delivery{}, ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+	var d Delivery
+	if err := json.Unmarshal(data, &d); err != nil {
+		return Delivery{}, fmt.Errorf("reading delivery of %s/%s: %w", ref.CompanyID, ref.PayslipID, err)
+	}
+	return d, nil
+}
+
+// path has a directory per company. IDs are escaped, so they can't point outside of it.
+func (s *FileStore) path(ref Ref) string {
+	return filepath.Join(s.dir, url.PathEscape(ref.CompanyID), url.PathEscape(ref.PayslipID)+".json")
+}
@@ -0,0 +1,46 @@
+package delivery
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryStore keeps deliveries for as long as the process runs. Only the worker itself can see them.
+type MemoryStore struct {
+	mu         sync.Mutex
+	deliveries map[Ref]Delivery
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: map[Ref]Delivery{}}
+}
+
+func (s *MemoryStore) Record(_ context.Context, ref Ref, transition Transition) error {
+	if !ref.valid() {
+		return ErrInvalidRef
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[ref]
+	if !ok {
+		d = Delivery{CompanyID: ref.CompanyID, PayslipID: ref.PayslipID}
+	}
+	d.apply(transition)
+	s.deliveries[ref] = d
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, ref Ref) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[ref]
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	// Transitions are appended in place, caller must not see that.
+	d.Transitions = slices.Clone(d.Transitions)
+	return d, nil
+}
@@ -0,0 +1,179 @@
+package employees
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"temporal-poc/bob"
+)
+
+var ErrNotFound = errors.New("employee not found")
+
+// Store keeps versions of employees. Implemented by MemoryStore and FileStore.
+type Store interface {
+	// Changes diffs employees against their current versions. Nothing is saved, so events can be published before
+	// it is.
+	Changes(ctx context.Context, employees []bob.Employee, syncedAt time.Time) (Changes, error)
+	// Save appends versions, and closes the ones they replace.
+	Save(ctx context.Context, versions []Version) error
+	// AsOf returns employee as it was at the given time.
+	AsOf(ctx context.Context, employeeID string, at time.Time) (bob.Employee, error)
+}
+
+// Version of an employee, effective from the time Bob changed it until the next version. Old versions are kept, so
+// payroll can see employees as they were on any date.
+type Version struct {
+	bob.Employee
+	EffectiveFrom time.Time
+	// EffectiveTo is zero for the current version.
+	EffectiveTo time.Time
+	SyncedAt    time.Time
+}
+
+type EventType string
+
+const (
+	EventNewStarter         EventType = "NewStarter"
+	EventLeaver             EventType = "Leaver"
+	EventSalaryChanged      EventType = "SalaryChanged"
+	EventBankDetailsChanged EventType = "BankDetailsChanged"
+)
+
+// Event tells that something payroll cares about changed. It carries no PII, whoever reacts to it reads the employee
+// from the store.
+type Event struct {
+	Type       EventType
+	EmployeeID string
+	// EffectiveAt is the termination date for leavers, and the time of the change for everything else.
+	EffectiveAt time.Time
+}
+
+type Summary struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+func (s Summary) Add(other Summary) Summary {
+	return Summary{
+		Created:   s.Created + other.Created,
+		Updated:   s.Updated + other.Updated,
+		Unchanged: s.Unchanged + other.Unchanged,
+	}
+}
+
+// Changes are what saving employees would change.
+type Changes struct {
+	Summary Summary
+	Events  []Event
+	// Versions to store. Unchanged employees are left out.
+	Versions []Version
+}
+
+// Diff compares employee from Bob with its current version, nil if there is none. Employees older than the current
+// version (e.g. synced again because of watermark overlap) are unchanged. Other changes (name, email...) make a new
+// version without any events.
+func Diff(current *Version, employee bob.Employee, syncedAt time.Time) (Version, []Event, bool) {
+	effectiveFrom := employee.UpdatedAt
+	if effectiveFrom.IsZero() {
+		effectiveFrom = syncedAt
+	}
+	version := Version{Employee: employee, EffectiveFrom: effectiveFrom, SyncedAt: syncedAt}
+
+	if current == nil {
+		events := []Event{{Type: EventNewStarter, EmployeeID: employee.ID, EffectiveAt: effectiveFrom}}
+		if !employee.TerminationDate.IsZero() {
+			events = append(events, Event{Type: EventLeaver, EmployeeID: employee.ID, EffectiveAt: employee.TerminationDate})
+		}
+		return version, events, true
+	}
+	if effectiveFrom.Before(current.EffectiveFrom) || same(current.Employee, employee) {
+		return Version{}, nil, false
+	}
+
+	var events []Event
+	if current.TerminationDate.IsZero() && !employee.TerminationDate.IsZero() {
+		events = append(events, Event{Type: EventLeaver, EmployeeID: employee.ID, EffectiveAt: employee.TerminationDate})
+	}
+	if current.Salary != employee.Salary {
+		events = append(events, Event{Type: EventSalaryChanged, EmployeeID: employee.ID, EffectiveAt: effectiveFrom})
+	}
+	if current.BankAccount != employee.BankAccount {
+		events = append(events, Event{Type: EventBankDetailsChanged, EmployeeID: employee.ID, EffectiveAt: effectiveFrom})
+	}
+	return version, events, true
+}
+
+// same ignores UpdatedAt. Bob bumps it on changes of fields we don't sync.
+func same(a, b bob.Employee) bool {
+	return a.ID == b.ID &&
+		a.FirstName == b.FirstName &&
+		a.LastName == b.LastName &&
+		a.Email == b.Email &&
+		a.Salary == b.Salary &&
+		a.BankAccount == b.BankAccount &&
+		a.StartDate.Equal(b.StartDate) &&
+		a.TerminationDate.Equal(b.TerminationDate)
+}
+
+// diff is Changes of any store, looking current versions up with current.
+func diff(employees []bob.Employee, syncedAt time.Time, current func(employeeID string) (*Version, error)) (Changes, error) {
+	var changes Changes
+	// The same employee can be twice in one batch, if Bob changed it while we were paging.
+	pending := map[string]*Version{}
+	for _, employee := range employees {
+		previous := pending[employee.ID]
+		if previous == nil {
+			var err error
+			if previous, err = current(employee.ID); err != nil {
+				return Changes{}, err
+			}
+		}
+		version, events, changed := Diff(previous, employee, syncedAt)
+		switch {
+		case !changed:
+			changes.Summary.Unchanged++
+			continue
+		case previous == nil:
+			changes.Summary.Created++
+		default:
+			changes.Summary.Updated++
+		}
+		changes.Events = append(changes.Events, events...)
+		changes.Versions = append(changes.Versions, version)
+		pending[employee.ID] = &version
+	}
+	return changes, nil
+}
+
+// appendVersion closes the current version of history. Versions older than the current one are ignored, another sync
+// got there first.
+func appendVersion(history []Version, version Version) ([]Version, bool) {
+	if len(history) > 0 {
+		last := &history[len(history)-1]
+		if version.EffectiveFrom.Before(last.EffectiveFrom) {
+			return history, false
+		}
+		last.EffectiveTo = version.EffectiveFrom
+	}
+	version.EffectiveTo = time.Time{}
+	return append(history, version), true
+}
+
+func asOf(history []Version, at time.Time) (bob.Employee, error) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if !at.Before(history[i].EffectiveFrom) {
+			return history[i].Employee, nil
+		}
+	}
+	return bob.Employee{}, ErrNotFound
+}
+
+func last(history []Version) *Version {
+	if len(history) == 0 {
+		return nil
+	}
+	version := history[len(history)-1]
+	return &version
+}
@@ -0,0 +1,181 @@
+package employees
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"temporal-poc/bob"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// StoreTestSuite runs against every Store, they have to behave the same.
+type StoreTestSuite struct {
+	suite.Suite
+
+	ctx      context.Context
+	newStore func(t *testing.T) Store
+	store    Store
+}
+
+func TestMemoryStore(t *testing.T) {
+	suite.Run(t, &StoreTestSuite{newStore: func(*testing.T) Store { return NewMemoryStore() }})
+}
+
+func TestFileStore(t *testing.T) {
+	suite.Run(t, &StoreTestSuite{newStore: func(t *testing.T) Store {
+		store, err := NewFileStore(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}})
+}
+
+func TestFileStore_KeepsEmployeesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	changes, err := store.Changes(ctx, []bob.Employee{joe}, syncedAt)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, changes.Versions))
+
+	restarted, err := NewFileStore(dir)
+	require.NoError(t, err)
+	changes, err = restarted.Changes(ctx, []bob.Employee{joe}, syncedAt)
+
+	require.NoError(t, err)
+	require.Equal(t, Summary{Unchanged: 1}, changes.Summary)
+	require.Empty(t, changes.Events)
+}
+
+var (
+	hired    = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	raised   = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	syncedAt = time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+	joe      = bob.Employee{ID: "employee-1", FirstName: "Joe", Salary: 30_000_00, BankAccount: bob.BankAccount{SortCode: "40-47-84", AccountNumber: "70872490"}, UpdatedAt: hired}
+)
+
+func (s *StoreTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.store = s.newStore(s.T())
+}
+
+func (s *StoreTestSuite) save(employees ...bob.Employee) Changes {
+	changes, err := s.store.Changes(s.ctx, employees, syncedAt)
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.Save(s.ctx, changes.Versions))
+	return changes
+}
+
+func (s *StoreTestSuite) Test_NewStarter() {
+	changes := s.save(joe)
+
+	s.Equal(Summary{Created: 1}, changes.Summary)
+	s.Equal([]Event{{Type: EventNewStarter, EmployeeID: "employee-1", EffectiveAt: hired}}, changes.Events)
+}
+
+func (s *StoreTestSuite) Test_Unchanged_WhenSyncedAgain() {
+	s.save(joe)
+
+	changes := s.save(joe)
+
+	s.Equal(Summary{Unchanged: 1}, changes.Summary)
+	s.Empty(changes.Events)
+	s.Empty(changes.Versions)
+}
+
+func (s *StoreTestSuite) Test_Unchanged_WhenOnlyUpdatedAtMoved() {
+	s.save(joe)
+	touched := joe
+	touched.UpdatedAt = raised
+
+	s.Equal(Summary{Unchanged: 1}, s.save(touched).Summary)
+}
+
+func (s *StoreTestSuite) Test_SalaryAndBankDetailsChange() {
+	s.save(joe)
+	changed := joe
+	changed.Salary = 35_000_00
+	changed.BankAccount.AccountNumber = "12345678"
+	changed.UpdatedAt = raised
+
+	changes := s.save(changed)
+
+	s.Equal(Summary{Updated: 1}, changes.Summary)
+	s.Equal([]Event{
+		{Type: EventSalaryChanged, EmployeeID: "employee-1", EffectiveAt: raised},
+		{Type: EventBankDetailsChanged, EmployeeID: "employee-1", EffectiveAt: raised},
+	}, changes.Events)
+}
+
+func (s *StoreTestSuite) Test_Leaver() {
+	s.save(joe)
+	left := joe
+	left.TerminationDate = time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)
+	left.UpdatedAt = raised
+
+	changes := s.save(left)
+
+	s.Equal([]Event{{Type: EventLeaver, EmployeeID: "employee-1", EffectiveAt: left.TerminationDate}}, changes.Events)
+}
+
+func (s *StoreTestSuite) Test_UpdatedWithoutEvents_WhenNameChanges() {
+	s.save(joe)
+	renamed := joe
+	renamed.FirstName = "Joseph"
+	renamed.UpdatedAt = raised
+
+	changes := s.save(renamed)
+
+	s.Equal(Summary{Updated: 1}, changes.Summary)
+	s.Empty(changes.Events)
+}
+
+func (s *StoreTestSuite) Test_IgnoresVersionsOlderThanCurrent() {
+	newer := joe
+	newer.Salary = 35_000_00
+	newer.UpdatedAt = raised
+	s.save(newer)
+
+	changes := s.save(joe)
+
+	s.Equal(Summary{Unchanged: 1}, changes.Summary)
+	current, err := s.store.AsOf(s.ctx, "employee-1", syncedAt)
+	s.Require().NoError(err)
+	s.Equal(35_000_00, current.Salary)
+}
+
+func (s *StoreTestSuite) Test_AsOf_ReturnsVersionEffectiveAtTheTime() {
+	s.save(joe)
+	changed := joe
+	changed.Salary = 35_000_00
+	changed.UpdatedAt = raised
+	s.save(changed)
+
+	_, err := s.store.AsOf(s.ctx, "employee-1", hired.Add(-time.Second))
+	s.ErrorIs(err, ErrNotFound)
+	before, err := s.store.AsOf(s.ctx, "employee-1", raised.Add(-time.Second))
+	s.Require().NoError(err)
+	s.Equal(30_000_00, before.Salary)
+	after, err := s.store.AsOf(s.ctx, "employee-1", raised)
+	s.Require().NoError(err)
+	s.Equal(35_000_00, after.Salary)
+}
+
+func (s *StoreTestSuite) Test_DiffsAgainstEarlierChange_InTheSameBatch() {
+	changed := joe
+	changed.Salary = 35_000_00
+	changed.UpdatedAt = raised
+
+	changes := s.save(joe, changed)
+
+	s.Equal(Summary{Created: 1, Updated: 1}, changes.Summary)
+	s.Equal(EventSalaryChanged, changes.Events[1].Type)
+	before, err := s.store.AsOf(s.ctx, "employee-1", raised.Add(-time.Second))
+	s.Require().NoError(err)
+	s.Equal(30_000_00, before.Salary)
+}
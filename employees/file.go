@@ -0,0 +1,113 @@
+package employees
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"temporal-poc/bob"
+)
+
+// FileStore keeps versions of every employee in a JSON file in a local directory, so they survive worker restarts.
+// Incremental sync never fetches unchanged employees again, so losing them would make everyone a new starter. Same as
+// delivery.FileStore, updates are serialised within a process only.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating employees directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Changes(_ context.Context, employees []bob.Employee, syncedAt time.Time) (Changes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return diff(employees, syncedAt, func(employeeID string) (*Version, error) {
+		history, err := s.read(employeeID)
+		return last(history), err
+	})
+}
+
+func (s *FileStore) Save(_ context.Context, versions []Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, version := range versions {
+		history, err := s.read(version.ID)
+		if err != nil {
+			return err
+		}
+		history, ok := appendVersion(history, version)
+		if !ok {
+			continue
+		}
+		if err := s.write(version.ID, history); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) AsOf(_ context.Context, employeeID string, at time.Time) (bob.Employee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history, err := s.read(employeeID)
+	if err != nil {
+		return bob.Employee{}, err
+	}
+	return asOf(history, at)
+}
+
+// read returns no versions of employees never saved.
+func (s *FileStore) read(employeeID string) ([]Version, error) {
+	data, err := os.ReadFile(s.path(employeeID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var history []Version
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("reading employee %s: %w", employeeID, err)
+	}
+	return history, nil
+}
+
+func (s *FileStore) write(employeeID string, history []Version) error {
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	// Write and rename, so nobody can read half-written history.
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(employeeID))
+}
+
+// path escapes employee ID, so it can't point outside of the directory.
+func (s *FileStore) path(employeeID string) string {
+	return filepath.Join(s.dir, url.PathEscape(employeeID)+".json")
+}
@@ -0,0 +1,45 @@
+package employees
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"temporal-poc/bob"
+)
+
+// MemoryStore keeps versions of employees for as long as the process runs. Restarted worker starts with none.
+type MemoryStore struct {
+	mu sync.Mutex
+	// versions by employee ID, oldest first.
+	versions map[string][]Version
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{versions: map[string][]Version{}}
+}
+
+func (s *MemoryStore) Changes(_ context.Context, employees []bob.Employee, syncedAt time.Time) (Changes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return diff(employees, syncedAt, func(employeeID string) (*Version, error) {
+		return last(s.versions[employeeID]), nil
+	})
+}
+
+func (s *MemoryStore) Save(_ context.Context, versions []Version) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, version := range versions {
+		s.versions[version.ID], _ = appendVersion(s.versions[version.ID], version)
+	}
+	return nil
+}
+
+func (s *MemoryStore) AsOf(_ context.Context, employeeID string, at time.Time) (bob.Employee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return asOf(s.versions[employeeID], at)
+}
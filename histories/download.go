@@ -0,0 +1,110 @@
+package histories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/proxy"
+	"go.temporal.io/api/temporalproto"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+// DownloadOptions selects which histories to download, and where to put them.
+type DownloadOptions struct {
+	Namespace string
+	// Query is a visibility query, e.g. "WorkflowType = 'ProcessPayroll' AND ExecutionStatus = 'Completed'".
+	Query string
+	// Limit is max number of histories to download. Zero means no limit.
+	Limit int
+	Dir   string
+	// Codecs decode payloads before they are written, so corpus can be replayed without encryption keys or blob
+	// store. Keep in mind that it means decrypted data ends up on disk.
+	Codecs []converter.PayloadCodec
+}
+
+// Download writes histories of workflows matching the query as JSON files into <dir>/<workflow type>/, in the same
+// format as `temporal workflow show --output json`. Existing files with the same name are overwritten.
+func Download(ctx context.Context, c client.Client, opts DownloadOptions) ([]string, error) {
+	var files []string
+	var nextPageToken []byte
+	for {
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Namespace:     opts.Namespace,
+			Query:         opts.Query,
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return files, fmt.Errorf("listing workflows: %w", err)
+		}
+		for _, execution := range resp.GetExecutions() {
+			if opts.Limit > 0 && len(files) >= opts.Limit {
+				return files, nil
+			}
+			file, err := download(ctx, c, execution.GetType().GetName(), execution.GetExecution(), opts)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, file)
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return files, nil
+		}
+	}
+}
+
+func download(ctx context.Context, c client.Client, workflowType string, execution *commonpb.WorkflowExecution, opts DownloadOptions) (string, error) {
+	history := &historypb.History{}
+	iter := c.GetWorkflowHistory(ctx, execution.GetWorkflowId(), execution.GetRunId(), false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return "", fmt.Errorf("reading history of %s: %w", execution.GetWorkflowId(), err)
+		}
+		history.Events = append(history.Events, event)
+	}
+
+	err := proxy.VisitPayloads(ctx, history, proxy.VisitPayloadsOptions{
+		Visitor: func(_ *proxy.VisitPayloadsContext, payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+			// Same order as converter.CodecDataConverter decodes.
+			var err error
+			for _, codec := range opts.Codecs {
+				if payloads, err = codec.Decode(payloads); err != nil {
+					return nil, err
+				}
+			}
+			return payloads, nil
+		},
+		// Search attributes are never encoded.
+		SkipSearchAttributes: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("decoding history of %s: %w", execution.GetWorkflowId(), err)
+	}
+
+	data, err := temporalproto.CustomJSONMarshalOptions{Indent: "  "}.Marshal(history)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(opts.Dir, workflowType)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, fileName(execution.GetWorkflowId())+"_"+fileName(execution.GetRunId())+".json")
+	return file, os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fileName makes workflow ID safe to use in a file name. Scheduled workflows have timestamps in their IDs.
+func fileName(s string) string {
+	return unsafeChars.ReplaceAllString(s, "-")
+}
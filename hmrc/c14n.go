@@ -0,0 +1,225 @@
+package hmrc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// canonicalBody writes GovTalk Body the way IRmark is computed over it: Canonical XML 1.0 without comments
+// (https://www.w3.org/TR/xml-c14n), of the Body subtree with IRmark elements left out. Namespaces Body inherits
+// from the envelope are declared on it, as inclusive canonicalisation of a subtree does.
+func canonicalBody(document []byte) ([]byte, error) {
+	c := canonicalizer{scopes: []map[string]string{{}}}
+	d := xml.NewDecoder(bytes.NewReader(document))
+	// Prefixes are kept as they are. Canonical form doesn't rewrite them.
+	for {
+		token, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing document: %w", err)
+		}
+		if done := c.token(token); done {
+			return c.out.Bytes(), nil
+		}
+	}
+	return nil, errors.New("document has no Body")
+}
+
+type canonicalizer struct {
+	out bytes.Buffer
+	// scopes has namespaces declared on each open element, outermost first.
+	scopes []map[string]string
+	// rendered has namespaces declared in output, for each open element that is in it.
+	rendered []map[string]string
+	depth    int
+	// bodyDepth is depth of Body, once we are inside it.
+	bodyDepth int
+	// skipDepth is depth of IRmark being left out.
+	skipDepth int
+}
+
+// token returns true after Body ends.
+func (c *canonicalizer) token(token xml.Token) bool {
+	switch t := token.(type) {
+	case xml.StartElement:
+		c.depth++
+		scope := map[string]string{}
+		for _, attr := range t.Attr {
+			if prefix, ok := namespacePrefix(attr.Name); ok {
+				scope[prefix] = attr.Value
+			}
+		}
+		c.scopes = append(c.scopes, scope)
+
+		// Body is a child of GovTalkMessage.
+		if c.bodyDepth == 0 && c.depth == 2 && t.Name.Local == "Body" {
+			c.bodyDepth = c.depth
+		}
+		if c.bodyDepth == 0 || c.skipDepth > 0 {
+			return false
+		}
+		if t.Name.Local == "IRmark" {
+			c.skipDepth = c.depth
+			return false
+		}
+		c.start(t)
+	case xml.EndElement:
+		defer func() {
+			c.scopes = c.scopes[:len(c.scopes)-1]
+			c.depth--
+		}()
+		switch {
+		case c.bodyDepth == 0:
+		case c.skipDepth > 0:
+			if c.depth == c.skipDepth {
+				c.skipDepth = 0
+			}
+		default:
+			c.out.WriteString("</" + qualified(t.Name) + ">")
+			c.rendered = c.rendered[:len(c.rendered)-1]
+			return c.depth == c.bodyDepth
+		}
+	case xml.CharData:
+		if c.bodyDepth > 0 && c.skipDepth == 0 {
+			c.out.WriteString(escapeText(string(t)))
+		}
+	case xml.ProcInst:
+		if c.bodyDepth > 0 && c.skipDepth == 0 {
+			c.out.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				c.out.WriteString(" " + string(t.Inst))
+			}
+			c.out.WriteString("?>")
+		}
+	}
+	// Comments and directives are not in canonical form.
+	return false
+}
+
+func (c *canonicalizer) start(t xml.StartElement) {
+	inherited := map[string]string{}
+	if len(c.rendered) > 0 {
+		inherited = c.rendered[len(c.rendered)-1]
+	}
+	rendered := make(map[string]string, len(inherited))
+	for prefix, uri := range inherited {
+		rendered[prefix] = uri
+	}
+
+	// Every namespace in scope is rendered, unless output parent already did it.
+	var namespaces []string
+	for prefix, uri := range c.inScope() {
+		if current, ok := inherited[prefix]; ok && current == uri {
+			continue
+		}
+		// Empty default namespace needs declaring only to undo a non-empty one.
+		if prefix == "" && uri == "" && inherited[""] == "" {
+			continue
+		}
+		namespaces = append(namespaces, prefix)
+		rendered[prefix] = uri
+	}
+	sort.Strings(namespaces)
+
+	type attribute struct {
+		namespace string
+		name      string
+		value     string
+	}
+	var attrs []attribute
+	for _, attr := range t.Attr {
+		if _, ok := namespacePrefix(attr.Name); ok {
+			continue
+		}
+		// Unprefixed attributes are in no namespace, even if there is a default one.
+		namespace := ""
+		if attr.Name.Space != "" {
+			namespace = c.resolve(attr.Name.Space)
+		}
+		attrs = append(attrs, attribute{namespace: namespace, name: qualified(attr.Name), value: attr.Value})
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].namespace != attrs[j].namespace {
+			return attrs[i].namespace < attrs[j].namespace
+		}
+		return localName(attrs[i].name) < localName(attrs[j].name)
+	})
+
+	c.out.WriteString("<" + qualified(t.Name))
+	for _, prefix := range namespaces {
+		name := "xmlns"
+		if prefix != "" {
+			name += ":" + prefix
+		}
+		c.out.WriteString(" " + name + `="` + escapeAttr(rendered[prefix]) + `"`)
+	}
+	for _, attr := range attrs {
+		c.out.WriteString(" " + attr.name + `="` + escapeAttr(attr.value) + `"`)
+	}
+	c.out.WriteString(">")
+	c.rendered = append(c.rendered, rendered)
+}
+
+// inScope merges declarations of open elements, inner ones win.
+func (c *canonicalizer) inScope() map[string]string {
+	namespaces := map[string]string{}
+	for _, scope := range c.scopes {
+		for prefix, uri := range scope {
+			namespaces[prefix] = uri
+		}
+	}
+	return namespaces
+}
+
+func (c *canonicalizer) resolve(prefix string) string {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if uri, ok := c.scopes[i][prefix]; ok {
+			return uri
+		}
+	}
+	if prefix == "xml" {
+		return "http://www.w3.org/XML/1998/namespace"
+	}
+	return ""
+}
+
+// namespacePrefix returns prefix declared by xmlns or xmlns:prefix attribute.
+func namespacePrefix(name xml.Name) (string, bool) {
+	switch {
+	case name.Space == "" && name.Local == "xmlns":
+		return "", true
+	case name.Space == "xmlns":
+		return name.Local, true
+	}
+	return "", false
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func localName(name string) string {
+	_, local, found := strings.Cut(name, ":")
+	if !found {
+		return name
+	}
+	return local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
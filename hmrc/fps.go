@@ -0,0 +1,380 @@
+package hmrc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"temporal-poc/payroll"
+)
+
+const fpsClass = "HMRC-PAYE-RTI-FPS"
+
+// fpsNamespaces has FPS schemas we build against, by tax year. HMRC publishes a new one every year.
+var fpsNamespaces = map[string]string{
+	"24-25": "http://www.govtalk.gov.uk/taxation/PAYE/RTI/FullPaymentSubmission/24-25/1",
+	"25-26": "http://www.govtalk.gov.uk/taxation/PAYE/RTI/FullPaymentSubmission/25-26/1",
+}
+
+// FPS builds Full Payment Submission of a payroll run. It fails with *ValidationError if the run doesn't fit the
+// schema, there is no point sending it then.
+func FPS(opts Options, run payroll.Run) ([]byte, error) {
+	taxYear, yearEnd := taxYearOf(run.PaymentDate)
+	if err := validate(run, taxYear); err != nil {
+		return nil, err
+	}
+
+	keys := []key{
+		{Type: "TaxOfficeNumber", Value: run.Employer.TaxOfficeNumber},
+		{Type: "TaxOfficeReference", Value: run.Employer.TaxOfficeReference},
+	}
+	submission := fullPaymentSubmission{
+		EmpRefs: empRefs{
+			OfficeNo: run.Employer.TaxOfficeNumber,
+			PayeRef:  run.Employer.TaxOfficeReference,
+			AORef:    run.Employer.AccountsOfficeReference,
+		},
+		RelatedTaxYear: taxYear,
+	}
+	for _, payslip := range run.Payslips {
+		submission.Employees = append(submission.Employees, newFPSEmployee(run, payslip))
+	}
+	content := irEnvelope{
+		Namespace: fpsNamespaces[taxYear],
+		IRheader: irHeader{
+			Keys:            keys,
+			PeriodEnd:       date(yearEnd),
+			DefaultCurrency: "GBP",
+			// IRmark is left out of its own digest, but not whitespace around it. So it has to be there already.
+			IRmark: &irMark{Type: "generic"},
+			Sender: "Employer",
+		},
+		FullPaymentSubmission: submission,
+	}
+	message := envelope(opts, fpsClass, keys, &content)
+	document, err := marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	mark, err := ComputeIRmark(document)
+	if err != nil {
+		return nil, err
+	}
+	content.IRheader.IRmark.Value = mark.Base64()
+	return marshal(message)
+}
+
+// taxYearOf returns tax year as FPS writes it (e.g. 24-25), and its last day. Tax year starts on 6 April.
+func taxYearOf(paymentDate time.Time) (string, time.Time) {
+	start := paymentDate.Year()
+	if paymentDate.Before(time.Date(start, time.April, 6, 0, 0, 0, 0, paymentDate.Location())) {
+		start--
+	}
+	return fmt.Sprintf("%02d-%02d", start%100, (start+1)%100), time.Date(start+1, time.April, 5, 0, 0, 0, 0, time.UTC)
+}
+
+type irEnvelope struct {
+	XMLName               xml.Name              `xml:"IRenvelope"`
+	Namespace             string                `xml:"xmlns,attr"`
+	IRheader              irHeader              `xml:"IRheader"`
+	FullPaymentSubmission fullPaymentSubmission `xml:"FullPaymentSubmission"`
+}
+
+type irHeader struct {
+	Keys            []key   `xml:"Keys>Key"`
+	PeriodEnd       string  `xml:"PeriodEnd"`
+	DefaultCurrency string  `xml:"DefaultCurrency"`
+	IRmark          *irMark `xml:"IRmark"`
+	Sender          string  `xml:"Sender"`
+}
+
+type fullPaymentSubmission struct {
+	EmpRefs        empRefs       `xml:"EmpRefs"`
+	RelatedTaxYear string        `xml:"RelatedTaxYear"`
+	Employees      []fpsEmployee `xml:"Employee"`
+}
+
+type empRefs struct {
+	OfficeNo string `xml:"OfficeNo"`
+	PayeRef  string `xml:"PayeRef"`
+	AORef    string `xml:"AORef"`
+}
+
+type fpsEmployee struct {
+	Details    employeeDetails `xml:"EmployeeDetails"`
+	Employment employment      `xml:"Employment"`
+}
+
+type employeeDetails struct {
+	NINO      string  `xml:"NINO,omitempty"`
+	Forename  string  `xml:"Name>Fore,omitempty"`
+	Surname   string  `xml:"Name>Sur"`
+	Address   address `xml:"Address"`
+	BirthDate string  `xml:"BirthDate"`
+	Gender    string  `xml:"Gender"`
+}
+
+type address struct {
+	Lines    []string `xml:"Line"`
+	Postcode string   `xml:"UKPostcode,omitempty"`
+}
+
+// employment is in the order schema has it, encoding/xml keeps field order.
+type employment struct {
+	Starter       *starter      `xml:"Starter,omitempty"`
+	PayID         string        `xml:"PayId"`
+	LeavingDate   string        `xml:"LeavingDate,omitempty"`
+	FiguresToDate figuresToDate `xml:"FiguresToDate"`
+	Payment       payment       `xml:"Payment"`
+	NI            niValues      `xml:"NIlettersAndValues"`
+}
+
+type starter struct {
+	StartDate string `xml:"StartDate"`
+}
+
+type figuresToDate struct {
+	TaxablePay money `xml:"TaxablePay"`
+	TotalTax   money `xml:"TotalTax"`
+}
+
+type payment struct {
+	PayFreq        string  `xml:"PayFreq"`
+	PmtDate        string  `xml:"PmtDate"`
+	WeekNo         int     `xml:"WeekNo,omitempty"`
+	MonthNo        int     `xml:"MonthNo,omitempty"`
+	PeriodsCovered int     `xml:"PeriodsCovered"`
+	HoursWorked    string  `xml:"HoursWorked"`
+	TaxCode        taxCode `xml:"TaxCode"`
+	TaxablePay     money   `xml:"TaxablePay"`
+	TaxDeducted    money   `xml:"TaxDeductedOrRefunded"`
+}
+
+type taxCode struct {
+	NonCumulative string `xml:"BasisNonCumulative,attr,omitempty"`
+	Regime        string `xml:"TaxRegime,attr,omitempty"`
+	Code          string `xml:",chardata"`
+}
+
+type niValues struct {
+	Letter                  string `xml:"NIletter"`
+	GrossEarningsForNICsPd  money  `xml:"GrossEarningsForNICsInPd"`
+	GrossEarningsForNICsYTD money  `xml:"GrossEarningsForNICsYTD"`
+	AtLELYTD                money  `xml:"AtLELYTD"`
+	LELtoPTYTD              money  `xml:"LELtoPTYTD"`
+	PTtoUELYTD              money  `xml:"PTtoUELYTD"`
+	TotalEmpNICInPd         money  `xml:"TotalEmpNICInPd"`
+	TotalEmpNICYTD          money  `xml:"TotalEmpNICYTD"`
+	EmpeeContribnsInPd      money  `xml:"EmpeeContribnsInPd"`
+	EmpeeContribnsYTD       money  `xml:"EmpeeContribnsYTD"`
+}
+
+func newFPSEmployee(run payroll.Run, payslip payroll.Payslip) fpsEmployee {
+	employee := payslip.Employee
+	e := fpsEmployee{
+		Details: employeeDetails{
+			NINO:      employee.NINO,
+			Forename:  employee.Forename,
+			Surname:   employee.Surname,
+			Address:   address{Lines: employee.Address.Lines, Postcode: employee.Address.Postcode},
+			BirthDate: date(employee.BirthDate),
+			Gender:    employee.Gender,
+		},
+		Employment: employment{
+			PayID:         employee.PayrollID,
+			FiguresToDate: figuresToDate{TaxablePay: money(payslip.TaxablePayYTD), TotalTax: money(payslip.TaxYTD)},
+			Payment: payment{
+				PayFreq:        string(run.Frequency),
+				PmtDate:        date(run.PaymentDate),
+				PeriodsCovered: 1,
+				HoursWorked:    string(payslip.HoursWorked),
+				TaxCode:        parseTaxCode(payslip.TaxCode),
+				TaxablePay:     money(payslip.TaxablePay),
+				TaxDeducted:    money(payslip.Tax),
+			},
+			NI: niValues{
+				Letter:                  payslip.NICategory,
+				GrossEarningsForNICsPd:  money(payslip.NIablePay),
+				GrossEarningsForNICsYTD: money(payslip.NIablePayYTD),
+				AtLELYTD:                money(payslip.AtLELYTD),
+				LELtoPTYTD:              money(payslip.LELToPTYTD),
+				PTtoUELYTD:              money(payslip.PTToUELYTD),
+				TotalEmpNICInPd:         money(payslip.EmployerNIC),
+				TotalEmpNICYTD:          money(payslip.EmployerNICYTD),
+				EmpeeContribnsInPd:      money(payslip.EmployeeNIC),
+				EmpeeContribnsYTD:       money(payslip.EmployeeNICYTD),
+			},
+		},
+	}
+	if run.Frequency.Weekly() {
+		e.Employment.Payment.WeekNo = run.Period
+	} else {
+		e.Employment.Payment.MonthNo = run.Period
+	}
+	if payslip.NewStarter {
+		e.Employment.Starter = &starter{StartDate: date(employee.StartDate)}
+	}
+	if !employee.LeavingDate.IsZero() {
+		e.Employment.LeavingDate = date(employee.LeavingDate)
+	}
+	return e
+}
+
+// parseTaxCode moves regime prefix and emergency suffix into attributes, where FPS wants them.
+func parseTaxCode(code string) taxCode {
+	var c taxCode
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, suffix := range []string{" W1", " M1", " X", "W1", "M1", "X"} {
+		if trimmed, ok := strings.CutSuffix(code, suffix); ok && trimmed != "" {
+			code = strings.TrimSpace(trimmed)
+			c.NonCumulative = "yes"
+			break
+		}
+	}
+	if len(code) > 1 && (code[0] == 'S' || code[0] == 'C') {
+		c.Regime = code[:1]
+		code = code[1:]
+	}
+	c.Code = code
+	return c
+}
+
+// money is an amount in pennies, written as pounds.
+type money int64
+
+func (m money) MarshalText() ([]byte, error) {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	return []byte(fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)), nil
+}
+
+func date(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// ValidationError lists everything in a payroll run that FPS schema wouldn't accept.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid FPS: " + strings.Join(e.Problems, "; ")
+}
+
+// Patterns are from FPS schema, simplified where it only matters to HMRC which exact values exist.
+var (
+	taxOfficeNumberPattern    = regexp.MustCompile(`^[0-9]{3}$`)
+	taxOfficeReferencePattern = regexp.MustCompile(`^[0-9A-Za-z]{1,10}$`)
+	accountsOfficeRefPattern  = regexp.MustCompile(`^[0-9]{3}P[A-Z][0-9]{7}[0-9X]$`)
+	ninoPattern               = regexp.MustCompile(`^[ABCEGHJ-PRSTW-Z][ABCEGHJ-NPRSTW-Z][0-9]{6}[A-D ]?$`)
+	taxCodePattern            = regexp.MustCompile(`^([1-9][0-9]{0,5}[LMNPTY]|K[1-9][0-9]{0,5}|BR|0T|NT|D[0-8])$`)
+	nameMaxLength             = 35
+	addressLineMaxLength      = 35
+	niCategories              = "ABCDEFHIJKLMNSVXZ"
+	hoursWorked               = []payroll.HoursWorked{payroll.HoursUpTo16, payroll.Hours16To24, payroll.Hours24To30, payroll.Hours30OrMore, payroll.HoursOtherwise}
+	payFrequencies            = []payroll.PayFrequency{payroll.Weekly, payroll.Fortnightly, payroll.FourWeekly, payroll.Monthly, payroll.Quarterly, payroll.HalfYearly, payroll.Annually}
+)
+
+func validate(run payroll.Run, taxYear string) error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, ok := fpsNamespaces[taxYear]; !ok {
+		problem("tax year %s is not supported", taxYear)
+	}
+	if !taxOfficeNumberPattern.MatchString(run.Employer.TaxOfficeNumber) {
+		problem("tax office number %q is not 3 digits", run.Employer.TaxOfficeNumber)
+	}
+	if !taxOfficeReferencePattern.MatchString(run.Employer.TaxOfficeReference) {
+		problem("tax office reference %q is invalid", run.Employer.TaxOfficeReference)
+	}
+	if !accountsOfficeRefPattern.MatchString(run.Employer.AccountsOfficeReference) {
+		problem("accounts office reference %q is invalid", run.Employer.AccountsOfficeReference)
+	}
+	if !slices.Contains(payFrequencies, run.Frequency) {
+		problem("pay frequency %q is invalid", run.Frequency)
+	}
+	maxPeriod := 12
+	if run.Frequency.Weekly() {
+		maxPeriod = 54
+	}
+	if run.Period < 1 || run.Period > maxPeriod {
+		problem("period %d is out of 1-%d", run.Period, maxPeriod)
+	}
+	if len(run.Payslips) == 0 {
+		problem("there are no payslips")
+	}
+
+	payrollIDs := map[string]bool{}
+	for i, payslip := range run.Payslips {
+		employee := payslip.Employee
+		// Payroll ID doesn't identify anyone outside of the employer, so it's fine in errors. Names and NINOs are not.
+		prefix := fmt.Sprintf("payslip %d (payroll ID %s): ", i+1, employee.PayrollID)
+		if employee.PayrollID == "" || payrollIDs[employee.PayrollID] {
+			problem("%spayroll ID is missing or not unique", prefix)
+		}
+		payrollIDs[employee.PayrollID] = true
+		if employee.NINO != "" && !ninoPattern.MatchString(employee.NINO) {
+			problem("%sNINO is invalid", prefix)
+		}
+		if employee.Surname == "" || len(employee.Surname) > nameMaxLength || len(employee.Forename) > nameMaxLength {
+			problem("%ssurname is required, and names can't be longer than %d characters", prefix, nameMaxLength)
+		}
+		// Without NINO, HMRC matches employee by address.
+		if employee.NINO == "" && (len(employee.Address.Lines) < 2 || employee.Address.Postcode == "") {
+			problem("%semployee without NINO needs two address lines and postcode", prefix)
+		}
+		for _, line := range employee.Address.Lines {
+			if len(line) > addressLineMaxLength {
+				problem("%saddress line is longer than %d characters", prefix, addressLineMaxLength)
+			}
+		}
+		if employee.BirthDate.IsZero() {
+			problem("%sbirth date is required", prefix)
+		}
+		if employee.Gender != "M" && employee.Gender != "F" {
+			problem("%sgender has to be M or F", prefix)
+		}
+		if payslip.NewStarter && employee.StartDate.IsZero() {
+			problem("%snew starter needs start date", prefix)
+		}
+		if !slices.Contains(hoursWorked, payslip.HoursWorked) {
+			problem("%shours worked %q is invalid", prefix, payslip.HoursWorked)
+		}
+		if !taxCodePattern.MatchString(parseTaxCode(payslip.TaxCode).Code) {
+			problem("%stax code %q is invalid", prefix, payslip.TaxCode)
+		}
+		if len(payslip.NICategory) != 1 || !strings.Contains(niCategories, payslip.NICategory) {
+			problem("%sNI category %q is invalid", prefix, payslip.NICategory)
+		}
+		for _, amount := range []struct {
+			name  string
+			value int64
+		}{
+			{"taxable pay", payslip.TaxablePay},
+			{"NIable pay", payslip.NIablePay},
+			{"NIable pay YTD", payslip.NIablePayYTD},
+			{"employee NICs", payslip.EmployeeNIC},
+			{"employee NICs YTD", payslip.EmployeeNICYTD},
+			{"employer NICs", payslip.EmployerNIC},
+			{"employer NICs YTD", payslip.EmployerNICYTD},
+		} {
+			if amount.value < 0 {
+				problem("%s%s can't be negative", prefix, amount.name)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package hmrc
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"temporal-poc/payroll"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Run `go test ./hmrc -update` after changing documents on purpose, and review the diff of testdata.
+var update = flag.Bool("update", false, "update golden files")
+
+type FPSTestSuite struct {
+	suite.Suite
+}
+
+func TestFPS(t *testing.T) {
+	suite.Run(t, new(FPSTestSuite))
+}
+
+var options = Options{
+	SenderID:       "sender-1",
+	Password:       "password-1",
+	Mode:           ModeLive,
+	VendorID:       "1234",
+	Product:        "temporal-poc",
+	ProductVersion: "1.0",
+}
+
+func run() payroll.Run {
+	return payroll.Run{
+		PayrollID: "payroll-1",
+		Employer: payroll.Employer{
+			TaxOfficeNumber:         "123",
+			TaxOfficeReference:      "AB456",
+			AccountsOfficeReference: "123PA00045678",
+		},
+		PaymentDate: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+		Frequency:   payroll.Monthly,
+		Period:      2,
+		Payslips: []payroll.Payslip{
+			{
+				Employee: payroll.Employee{
+					PayrollID: "employee-1",
+					NINO:      "AB123456C",
+					Forename:  "Joe",
+					Surname:   "Smith",
+					BirthDate: time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC),
+					Gender:    "M",
+					Address:   payroll.Address{Lines: []string{"1 High Street", "London"}, Postcode: "SW1A 1AA"},
+					StartDate: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC),
+				},
+				HoursWorked:    payroll.Hours30OrMore,
+				TaxCode:        "1257L",
+				NICategory:     "A",
+				TaxablePay:     2_500_00,
+				TaxablePayYTD:  5_000_00,
+				Tax:            290_40,
+				TaxYTD:         580_80,
+				NIablePay:      2_500_00,
+				NIablePayYTD:   5_000_00,
+				AtLELYTD:       1_066_00,
+				LELToPTYTD:     192_00,
+				PTToUELYTD:     3_742_00,
+				EmployeeNIC:    119_60,
+				EmployeeNICYTD: 239_20,
+				EmployerNIC:    234_07,
+				EmployerNICYTD: 468_14,
+			},
+			{
+				Employee: payroll.Employee{
+					PayrollID:   "employee-2",
+					Forename:    "Jane",
+					Surname:     "Doe",
+					BirthDate:   time.Date(2001, 7, 3, 0, 0, 0, 0, time.UTC),
+					Gender:      "F",
+					Address:     payroll.Address{Lines: []string{"2 Main Street", "Edinburgh"}, Postcode: "EH1 1AA"},
+					StartDate:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+					LeavingDate: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+				},
+				NewStarter:    true,
+				HoursWorked:   payroll.HoursUpTo16,
+				TaxCode:       "S1257L M1",
+				NICategory:    "M",
+				TaxablePay:    800_00,
+				TaxablePayYTD: 800_00,
+				Tax:           -12_50,
+				NIablePay:     800_00,
+				NIablePayYTD:  800_00,
+				AtLELYTD:      533_00,
+				LELToPTYTD:    96_00,
+				PTToUELYTD:    171_00,
+				EmployerNIC:   17_37,
+			},
+		},
+	}
+}
+
+// golden compares document with testdata/name.
+func (s *FPSTestSuite) golden(name string, document []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		s.Require().NoError(os.WriteFile(path, document, 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	s.Require().NoError(err)
+	s.Equal(string(expected), string(document))
+}
+
+func (s *FPSTestSuite) Test_BuildsFPS() {
+	document, err := FPS(options, run())
+
+	s.Require().NoError(err)
+	s.golden("fps.xml", document)
+}
+
+func (s *FPSTestSuite) Test_BuildsFPS_InTestInLiveMode() {
+	opts := options
+	opts.Mode = ModeTestInLive
+
+	document, err := FPS(opts, run())
+
+	s.Require().NoError(err)
+	s.golden("fps-test-in-live.xml", document)
+}
+
+func (s *FPSTestSuite) Test_BuildsFPS_InTestMode() {
+	opts := options
+	opts.Mode = ModeTest
+
+	document, err := FPS(opts, run())
+
+	s.Require().NoError(err)
+	s.Contains(string(document), "<Class>HMRC-PAYE-RTI-FPS</Class>")
+	s.Contains(string(document), "<GatewayTest>1</GatewayTest>")
+}
+
+func (s *FPSTestSuite) Test_ReportsEverythingSchemaWouldReject() {
+	r := run()
+	r.Employer.AccountsOfficeReference = "123PA0004567"
+	r.Period = 13
+	r.Payslips[0].Employee.NINO = "QQ12345"
+	r.Payslips[0].TaxCode = "1257Q"
+	r.Payslips[1].NICategory = "Q"
+	r.Payslips[1].Employee.Address.Postcode = ""
+
+	_, err := FPS(options, r)
+
+	var validationErr *ValidationError
+	s.Require().ErrorAs(err, &validationErr)
+	s.Equal([]string{
+		`accounts office reference "123PA0004567" is invalid`,
+		"period 13 is out of 1-12",
+		"payslip 1 (payroll ID employee-1): NINO is invalid",
+		`payslip 1 (payroll ID employee-1): tax code "1257Q" is invalid`,
+		"payslip 2 (payroll ID employee-2): employee without NINO needs two address lines and postcode",
+		`payslip 2 (payroll ID employee-2): NI category "Q" is invalid`,
+	}, validationErr.Problems)
+}
+
+func (s *FPSTestSuite) Test_RejectsTaxYearWithoutSchema() {
+	r := run()
+	r.PaymentDate = time.Date(2024, 4, 5, 0, 0, 0, 0, time.UTC)
+
+	_, err := FPS(options, r)
+
+	s.ErrorContains(err, "tax year 23-24 is not supported")
+}
+
+func (s *FPSTestSuite) Test_ParsesTaxCodes() {
+	for code, expected := range map[string]taxCode{
+		"1257L":    {Code: "1257L"},
+		"K475":     {Code: "K475"},
+		"S1257L":   {Code: "1257L", Regime: "S"},
+		"C0T":      {Code: "0T", Regime: "C"},
+		"1257L W1": {Code: "1257L", NonCumulative: "yes"},
+		"sbr m1":   {Code: "BR", Regime: "S", NonCumulative: "yes"},
+		"1257L X":  {Code: "1257L", NonCumulative: "yes"},
+		" NT ":     {Code: "NT"},
+	} {
+		s.Equal(expected, parseTaxCode(code), code)
+	}
+}
+
+func (s *FPSTestSuite) Test_BuildsPoll() {
+	document, err := PollFPS(options, "ABCDEF0123456789ABCDEF0123456789")
+
+	s.Require().NoError(err)
+	s.golden("fps-poll.xml", document)
+}
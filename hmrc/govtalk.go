@@ -0,0 +1,138 @@
+// Package hmrc builds RTI submissions for HMRC, wrapped in GovTalk envelopes the Transaction Engine expects.
+package hmrc
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// Mode decides whether HMRC processes a submission for real.
+type Mode string
+
+const (
+	ModeLive Mode = "live"
+	// ModeTestInLive goes to live service with -TIL message class. HMRC validates it like a live one, but never
+	// processes it. It's how new employers and software prove they are ready.
+	ModeTestInLive Mode = "test-in-live"
+	// ModeTest sets GatewayTest, for HMRC test services. Nothing leaves the sandbox.
+	ModeTest Mode = "test"
+)
+
+func (m Mode) Valid() bool {
+	return m == ModeLive || m == ModeTestInLive || m == ModeTest
+}
+
+// Options identify who submits, and with which software.
+type Options struct {
+	SenderID string
+	Password string
+	Mode     Mode
+	// VendorID is issued by HMRC when software is recognised. Product and ProductVersion name the software.
+	VendorID       string
+	Product        string
+	ProductVersion string
+}
+
+const govTalkNamespace = "http://www.govtalk.gov.uk/CM/envelope"
+
+type govTalkMessage struct {
+	XMLName         xml.Name       `xml:"GovTalkMessage"`
+	Namespace       string         `xml:"xmlns,attr"`
+	EnvelopeVersion string         `xml:"EnvelopeVersion"`
+	Header          header         `xml:"Header"`
+	GovTalkDetails  govTalkDetails `xml:"GovTalkDetails"`
+	Body            body           `xml:"Body"`
+}
+
+type header struct {
+	MessageDetails messageDetails `xml:"MessageDetails"`
+	// SenderDetails are empty in polls. Correlation ID is enough to get a response.
+	SenderDetails senderDetails `xml:"SenderDetails"`
+}
+
+type messageDetails struct {
+	Class     string `xml:"Class"`
+	Qualifier string `xml:"Qualifier"`
+	Function  string `xml:"Function"`
+	// CorrelationID is empty in requests. HMRC assigns one, and it's then used to poll for the response.
+	CorrelationID  string `xml:"CorrelationID"`
+	Transformation string `xml:"Transformation"`
+	GatewayTest    int    `xml:"GatewayTest"`
+}
+
+type senderDetails struct {
+	IDAuthentication *idAuthentication `xml:"IDAuthentication"`
+}
+
+type idAuthentication struct {
+	SenderID       string         `xml:"SenderID"`
+	Authentication authentication `xml:"Authentication"`
+}
+
+type authentication struct {
+	Method string `xml:"Method"`
+	Role   string `xml:"Role"`
+	Value  string `xml:"Value"`
+}
+
+type govTalkDetails struct {
+	Keys    []key   `xml:"Keys>Key"`
+	Channel channel `xml:"ChannelRouting>Channel"`
+}
+
+type key struct {
+	Type  string `xml:"Type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type channel struct {
+	URI     string `xml:"URI"`
+	Product string `xml:"Product"`
+	Version string `xml:"Version"`
+}
+
+type body struct {
+	Content interface{}
+}
+
+// envelope wraps content of a message of class. Keys identify the employer, so HMRC can route the message before
+// reading the body.
+func envelope(opts Options, class string, keys []key, content interface{}) govTalkMessage {
+	gatewayTest := 0
+	switch opts.Mode {
+	case ModeTestInLive:
+		class += "-TIL"
+	case ModeTest:
+		gatewayTest = 1
+	}
+	return govTalkMessage{
+		Namespace:       govTalkNamespace,
+		EnvelopeVersion: "2.0",
+		Header: header{
+			MessageDetails: messageDetails{
+				Class:          class,
+				Qualifier:      "request",
+				Function:       "submit",
+				Transformation: "XML",
+				GatewayTest:    gatewayTest,
+			},
+			SenderDetails: senderDetails{IDAuthentication: &idAuthentication{
+				SenderID:       opts.SenderID,
+				Authentication: authentication{Method: "clear", Role: "principal", Value: opts.Password},
+			}},
+		},
+		GovTalkDetails: govTalkDetails{
+			Keys:    keys,
+			Channel: channel{URI: opts.VendorID, Product: opts.Product, Version: opts.ProductVersion},
+		},
+		Body: body{Content: content},
+	}
+}
+
+func marshal(message govTalkMessage) ([]byte, error) {
+	data, err := xml.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling %s: %w", message.Header.MessageDetails.Class, err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,122 @@
+package hmrc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrIRmarkMismatch is returned when IRmark doesn't match the document it's in, or the one HMRC says it received.
+var ErrIRmarkMismatch = errors.New("IRmark mismatch")
+
+// IRmark is SHA-1 digest of canonical GovTalk Body, without IRmark itself. HMRC recomputes it, and rejects
+// submissions where it differs. Documents carry it in base64, while receipts and people use base32.
+type IRmark struct {
+	Digest []byte
+}
+
+func (m IRmark) Base64() string {
+	return base64.StdEncoding.EncodeToString(m.Digest)
+}
+
+func (m IRmark) Base32() string {
+	return base32.StdEncoding.EncodeToString(m.Digest)
+}
+
+func (m IRmark) String() string {
+	return m.Base32()
+}
+
+type irMark struct {
+	Type  string `xml:"Type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ComputeIRmark of a GovTalk document. It doesn't matter if the document already has IRmark, or what it is.
+func ComputeIRmark(document []byte) (IRmark, error) {
+	canonical, err := canonicalBody(document)
+	if err != nil {
+		return IRmark{}, err
+	}
+	sum := sha1.Sum(canonical)
+	return IRmark{Digest: sum[:]}, nil
+}
+
+// Verify checks that IRmark in document is the one computed over it, and returns it.
+func Verify(document []byte) (IRmark, error) {
+	embedded, err := embeddedIRmark(document)
+	if err != nil {
+		return IRmark{}, err
+	}
+	computed, err := ComputeIRmark(document)
+	if err != nil {
+		return IRmark{}, err
+	}
+	if embedded != computed.Base64() {
+		return IRmark{}, fmt.Errorf("%w: document has %s, but its body is %s", ErrIRmarkMismatch, embedded, computed.Base64())
+	}
+	return computed, nil
+}
+
+func embeddedIRmark(document []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(document))
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("document has no IRmark")
+		}
+		if err != nil {
+			return "", fmt.Errorf("parsing document: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "IRmark" {
+			var mark irMark
+			if err := d.DecodeElement(&mark, &start); err != nil {
+				return "", fmt.Errorf("parsing IRmark: %w", err)
+			}
+			return strings.TrimSpace(mark.Value), nil
+		}
+	}
+}
+
+// CheckReceipt checks that HMRC response acknowledges the IRmark we submitted. Its receipt message names it in
+// base32, e.g. "HMRC has received the HMRC-PAYE-RTI-FPS document ref: 123/AB456 from sender ... with the IRmark
+// 5ZQ2...". HMRC also signs the receipt, but we don't check the signature without its certificate.
+func CheckReceipt(response []byte, submitted IRmark) error {
+	d := xml.NewDecoder(bytes.NewReader(response))
+	inReceipt := false
+	for {
+		token, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			return errors.New("response has no IRmark receipt")
+		}
+		if err != nil {
+			return fmt.Errorf("parsing response: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "IRmarkReceipt":
+				inReceipt = true
+			case inReceipt && t.Name.Local == "Message":
+				var message string
+				if err := d.DecodeElement(&message, &t); err != nil {
+					return fmt.Errorf("parsing receipt: %w", err)
+				}
+				if !strings.Contains(message, submitted.Base32()) {
+					return fmt.Errorf("%w: submitted %s, but receipt says %q", ErrIRmarkMismatch, submitted, message)
+				}
+				return nil
+			}
+		case xml.EndElement:
+			if t.Name.Local == "IRmarkReceipt" {
+				inReceipt = false
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package hmrc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IRmarkTestSuite struct {
+	suite.Suite
+}
+
+func TestIRmark(t *testing.T) {
+	suite.Run(t, new(IRmarkTestSuite))
+}
+
+func (s *IRmarkTestSuite) Test_CanonicalisesBodyWithoutIRmark() {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope" xmlns:x="urn:x">
+  <Header/>
+  <Body>
+    <!-- comment -->
+    <IRenvelope xmlns="urn:ir" b="2" x:a="3" a="1&#9;&quot;">
+      <IRheader>
+        <IRmark Type="generic">anything</IRmark>
+        <Empty/>
+        <Text><![CDATA[a < b & c > d]]> &amp; e&#13;</Text>
+        <None xmlns=""><y:Inner xmlns:y="urn:y" xmlns:x="urn:x"/></None>
+      </IRheader>
+    </IRenvelope>
+  </Body>
+</GovTalkMessage>
+`
+
+	canonical, err := canonicalBody([]byte(document))
+
+	// It's what `xmllint --c14n` makes of the Body, with inherited namespaces declared on it and without comments.
+	s.Require().NoError(err)
+	s.Equal(`<Body xmlns="http://www.govtalk.gov.uk/CM/envelope" xmlns:x="urn:x">
+    
+    <IRenvelope xmlns="urn:ir" a="1&#x9;&quot;" b="2" x:a="3">
+      <IRheader>
+        
+        <Empty></Empty>
+        <Text>a &lt; b &amp; c &gt; d &amp; e&#xD;</Text>
+        <None xmlns=""><y:Inner xmlns:y="urn:y"></y:Inner></None>
+      </IRheader>
+    </IRenvelope>
+  </Body>`, string(canonical))
+}
+
+func (s *IRmarkTestSuite) Test_VerifiesFPS() {
+	document, err := os.ReadFile(filepath.Join("testdata", "fps.xml"))
+	s.Require().NoError(err)
+
+	mark, err := Verify(document)
+
+	s.Require().NoError(err)
+	s.Equal("zOTnBayug7bOvkNwu/J+dBrn6yQ=", mark.Base64())
+	s.Equal("ZTSOOBNMV2B3NTV6INYLX4T6OQNOP2ZE", mark.Base32())
+}
+
+func (s *IRmarkTestSuite) Test_DetectsChangedBody() {
+	document, err := FPS(options, run())
+	s.Require().NoError(err)
+	tampered := strings.Replace(string(document), "<TaxablePay>2500.00</TaxablePay>", "<TaxablePay>25000.00</TaxablePay>", 1)
+
+	_, err = Verify([]byte(tampered))
+
+	s.ErrorIs(err, ErrIRmarkMismatch)
+}
+
+func (s *IRmarkTestSuite) Test_IgnoresChangedEnvelope() {
+	document, err := FPS(options, run())
+	s.Require().NoError(err)
+	// Only Body is covered, so HMRC can fill in correlation ID.
+	changed := strings.Replace(string(document), "<CorrelationID></CorrelationID>", "<CorrelationID>ABC</CorrelationID>", 1)
+
+	_, err = Verify([]byte(changed))
+
+	s.NoError(err)
+}
+
+func (s *IRmarkTestSuite) Test_ChecksReceipt() {
+	mark := IRmark{Digest: []byte("01234567890123456789")}
+
+	err := CheckReceipt(receipt(mark.Base32()), mark)
+
+	s.NoError(err)
+}
+
+func (s *IRmarkTestSuite) Test_RejectsReceiptOfOtherIRmark() {
+	mark := IRmark{Digest: []byte("01234567890123456789")}
+	other := IRmark{Digest: []byte("98765432109876543210")}
+
+	err := CheckReceipt(receipt(other.Base32()), mark)
+
+	s.ErrorIs(err, ErrIRmarkMismatch)
+}
+
+func (s *IRmarkTestSuite) Test_RejectsResponseWithoutReceipt() {
+	err := CheckReceipt([]byte(`<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope"><Body/></GovTalkMessage>`), IRmark{})
+
+	s.ErrorContains(err, "response has no IRmark receipt")
+}
+
+func receipt(base32 string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope">
+  <Body>
+    <SuccessResponse xmlns="http://www.inlandrevenue.gov.uk/SuccessResponse">
+      <IRmarkReceipt>
+        <dsig:Signature xmlns:dsig="http://www.w3.org/2000/09/xmldsig#"></dsig:Signature>
+        <Message code="0">HMRC has received the HMRC-PAYE-RTI-FPS document ref: 123/AB456 from sender sender-1 with the IRmark ` + base32 + ` on 2025-05-30.</Message>
+      </IRmarkReceipt>
+    </SuccessResponse>
+  </Body>
+</GovTalkMessage>
+`)
+}
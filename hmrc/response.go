@@ -0,0 +1,147 @@
+package hmrc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Qualifier says what kind of GovTalk message HMRC responded with.
+type Qualifier string
+
+const (
+	// QualifierAcknowledgement means HMRC has the document, but not the outcome yet. Poll for it.
+	QualifierAcknowledgement Qualifier = "acknowledgement"
+	QualifierResponse        Qualifier = "response"
+	QualifierError           Qualifier = "error"
+)
+
+// Response to a submission or a poll.
+type Response struct {
+	Qualifier     Qualifier
+	CorrelationID string
+	// Endpoint and PollInterval come with acknowledgement: where to poll, and how long to wait before that.
+	Endpoint     string
+	PollInterval time.Duration
+	Errors       GovTalkErrors
+	// Document is the whole response, for CheckReceipt and anything else in its Body.
+	Document []byte
+}
+
+// ErrorType of GovTalk error. Only recoverable ones are worth sending the same document again.
+type ErrorType string
+
+const (
+	ErrorTypeFatal       ErrorType = "fatal"
+	ErrorTypeRecoverable ErrorType = "recoverable"
+	ErrorTypeBusiness    ErrorType = "business"
+	ErrorTypeWarning     ErrorType = "warning"
+)
+
+type GovTalkError struct {
+	// RaisedBy is Gateway for problems with the envelope, or Department when HMRC itself rejected the document.
+	RaisedBy string    `xml:"RaisedBy"`
+	Number   string    `xml:"Number"`
+	Type     ErrorType `xml:"Type"`
+	Text     string    `xml:"Text"`
+	Location string    `xml:"Location"`
+}
+
+// Employee says which employee of FPS the error is about, counting from 1 as Location does, and which of its fields,
+// e.g. EmployeeDetails/NINO. Errors about the submission as a whole are about employee 0, and the field is all of
+// Location.
+func (e GovTalkError) Employee() (int, string) {
+	location := strings.TrimSpace(e.Location)
+	employee := 0
+	var field []string
+	for _, segment := range strings.Split(strings.Trim(location, "/"), "/") {
+		name, index, _ := strings.Cut(strings.TrimSuffix(segment, "]"), "[")
+		if name == "Employee" && employee == 0 {
+			employee = 1
+			if n, err := strconv.Atoi(index); err == nil {
+				employee = n
+			}
+			field = nil
+			continue
+		}
+		if index != "" && index != "1" {
+			name += "[" + index + "]"
+		}
+		field = append(field, name)
+	}
+	if employee == 0 {
+		return 0, location
+	}
+	return employee, strings.Join(field, "/")
+}
+
+type GovTalkErrors []GovTalkError
+
+func (e GovTalkErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, fmt.Sprintf("%s error %s: %s", err.Type, err.Number, err.Text))
+	}
+	return "GovTalk errors: " + strings.Join(messages, "; ")
+}
+
+// Recoverable is true when there are errors, and all of them are recoverable.
+func (e GovTalkErrors) Recoverable() bool {
+	for _, err := range e {
+		if err.Type != ErrorTypeRecoverable {
+			return false
+		}
+	}
+	return len(e) > 0
+}
+
+type govTalkResponse struct {
+	Qualifier        Qualifier `xml:"Header>MessageDetails>Qualifier"`
+	CorrelationID    string    `xml:"Header>MessageDetails>CorrelationID"`
+	ResponseEndPoint struct {
+		PollInterval int    `xml:"PollInterval,attr"`
+		URL          string `xml:",chardata"`
+	} `xml:"Header>MessageDetails>ResponseEndPoint"`
+	Errors []GovTalkError `xml:"GovTalkDetails>GovTalkErrors>Error"`
+	// Business errors are in the body too, with the same or more of them.
+	BodyErrors []GovTalkError `xml:"Body>ErrorResponse>Error"`
+}
+
+// ParseResponse reads GovTalk message HMRC responded with.
+func ParseResponse(document []byte) (Response, error) {
+	var message govTalkResponse
+	if err := xml.Unmarshal(document, &message); err != nil {
+		return Response{}, fmt.Errorf("parsing GovTalk response: %w", err)
+	}
+	switch message.Qualifier {
+	case QualifierAcknowledgement, QualifierResponse, QualifierError:
+	default:
+		return Response{}, fmt.Errorf("GovTalk response has unexpected qualifier %q", message.Qualifier)
+	}
+	errs := GovTalkErrors(message.Errors)
+	for _, err := range message.BodyErrors {
+		if !slices.Contains(errs, err) {
+			errs = append(errs, err)
+		}
+	}
+	return Response{
+		Qualifier:     message.Qualifier,
+		CorrelationID: strings.TrimSpace(message.CorrelationID),
+		Endpoint:      strings.TrimSpace(message.ResponseEndPoint.URL),
+		PollInterval:  time.Duration(message.ResponseEndPoint.PollInterval) * time.Second,
+		Errors:        errs,
+		Document:      document,
+	}, nil
+}
+
+// PollFPS asks for response to FPS submitted under correlation ID.
+func PollFPS(opts Options, correlationID string) ([]byte, error) {
+	message := envelope(opts, fpsClass, nil, nil)
+	message.Header.MessageDetails.Qualifier = "poll"
+	message.Header.MessageDetails.CorrelationID = correlationID
+	message.Header.SenderDetails = senderDetails{}
+	return marshal(message)
+}
@@ -0,0 +1,152 @@
+package hmrc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ResponseTestSuite struct {
+	suite.Suite
+}
+
+func TestResponse(t *testing.T) {
+	suite.Run(t, new(ResponseTestSuite))
+}
+
+func (s *ResponseTestSuite) Test_ParsesAcknowledgement() {
+	document := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope">
+  <EnvelopeVersion>2.0</EnvelopeVersion>
+  <Header>
+    <MessageDetails>
+      <Class>HMRC-PAYE-RTI-FPS</Class>
+      <Qualifier>acknowledgement</Qualifier>
+      <Function>submit</Function>
+      <CorrelationID>ABCDEF0123456789ABCDEF0123456789</CorrelationID>
+      <ResponseEndPoint PollInterval="10">https://hmrc.example/poll</ResponseEndPoint>
+      <GatewayTimestamp>2025-05-30T10:00:00.000</GatewayTimestamp>
+    </MessageDetails>
+    <SenderDetails/>
+  </Header>
+  <GovTalkDetails>
+    <Keys/>
+  </GovTalkDetails>
+  <Body/>
+</GovTalkMessage>
+`)
+
+	response, err := ParseResponse(document)
+
+	s.Require().NoError(err)
+	s.Equal(Response{
+		Qualifier:     QualifierAcknowledgement,
+		CorrelationID: "ABCDEF0123456789ABCDEF0123456789",
+		Endpoint:      "https://hmrc.example/poll",
+		PollInterval:  10 * time.Second,
+		Document:      document,
+	}, response)
+}
+
+func (s *ResponseTestSuite) Test_ParsesErrors() {
+	document := []byte(`<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope">
+  <Header>
+    <MessageDetails>
+      <Qualifier>error</Qualifier>
+      <CorrelationID>ABCDEF0123456789ABCDEF0123456789</CorrelationID>
+    </MessageDetails>
+  </Header>
+  <GovTalkDetails>
+    <GovTalkErrors>
+      <Error>
+        <RaisedBy>Gateway</RaisedBy>
+        <Number>1046</Number>
+        <Type>fatal</Type>
+        <Text>Authentication Failure</Text>
+        <Location></Location>
+      </Error>
+    </GovTalkErrors>
+  </GovTalkDetails>
+  <Body/>
+</GovTalkMessage>`)
+
+	response, err := ParseResponse(document)
+
+	s.Require().NoError(err)
+	s.Equal(QualifierError, response.Qualifier)
+	s.Equal(GovTalkErrors{{RaisedBy: "Gateway", Number: "1046", Type: ErrorTypeFatal, Text: "Authentication Failure"}}, response.Errors)
+	s.False(response.Errors.Recoverable())
+	s.EqualError(response.Errors, "GovTalk errors: fatal error 1046: Authentication Failure")
+}
+
+func (s *ResponseTestSuite) Test_RejectsUnexpectedQualifier() {
+	_, err := ParseResponse([]byte(`<GovTalkMessage><Header><MessageDetails><Qualifier>request</Qualifier></MessageDetails></Header></GovTalkMessage>`))
+
+	s.ErrorContains(err, `unexpected qualifier "request"`)
+}
+
+func (s *ResponseTestSuite) Test_ParsesBusinessErrorsInBody() {
+	document := []byte(`<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope">
+  <Header>
+    <MessageDetails>
+      <Qualifier>error</Qualifier>
+      <CorrelationID>ABCDEF0123456789ABCDEF0123456789</CorrelationID>
+    </MessageDetails>
+  </Header>
+  <GovTalkDetails>
+    <GovTalkErrors>
+      <Error>
+        <RaisedBy>Department</RaisedBy>
+        <Number>7786</Number>
+        <Type>business</Type>
+        <Text>NINO is not valid</Text>
+        <Location>/IRenvelope[1]/FullPaymentSubmission[1]/Employee[2]/EmployeeDetails[1]/NINO[1]</Location>
+      </Error>
+    </GovTalkErrors>
+  </GovTalkDetails>
+  <Body>
+    <ErrorResponse xmlns="http://www.govtalk.gov.uk/CM/errorresponse" SchemaVersion="2.0">
+      <Error>
+        <RaisedBy>Department</RaisedBy>
+        <Number>7786</Number>
+        <Type>business</Type>
+        <Text>NINO is not valid</Text>
+        <Location>/IRenvelope[1]/FullPaymentSubmission[1]/Employee[2]/EmployeeDetails[1]/NINO[1]</Location>
+      </Error>
+      <Error>
+        <RaisedBy>Department</RaisedBy>
+        <Number>7801</Number>
+        <Type>business</Type>
+        <Text>Related tax year is not the current one</Text>
+        <Location>/IRenvelope[1]/FullPaymentSubmission[1]/RelatedTaxYear[1]</Location>
+      </Error>
+    </ErrorResponse>
+  </Body>
+</GovTalkMessage>`)
+
+	response, err := ParseResponse(document)
+
+	s.Require().NoError(err)
+	s.Require().Len(response.Errors, 2)
+	s.Equal("7786", response.Errors[0].Number)
+	s.Equal("7801", response.Errors[1].Number)
+}
+
+func (s *ResponseTestSuite) Test_LocatesEmployeeOfError() {
+	for location, expected := range map[string]struct {
+		employee int
+		field    string
+	}{
+		"/IRenvelope[1]/FullPaymentSubmission[1]/Employee[2]/EmployeeDetails[1]/NINO[1]":         {2, "EmployeeDetails/NINO"},
+		"/IRenvelope[1]/FullPaymentSubmission[1]/Employee[1]/EmployeeDetails[1]/Address/Line[2]": {1, "EmployeeDetails/Address/Line[2]"},
+		"/IRenvelope/FullPaymentSubmission/Employee/Employment/Payment/TaxCode":                  {1, "Employment/Payment/TaxCode"},
+		"/IRenvelope[1]/FullPaymentSubmission[1]/Employee[12]":                                   {12, ""},
+		"/IRenvelope[1]/FullPaymentSubmission[1]/RelatedTaxYear[1]":                              {0, "/IRenvelope[1]/FullPaymentSubmission[1]/RelatedTaxYear[1]"},
+		"": {0, ""},
+	} {
+		employee, field := GovTalkError{Location: location}.Employee()
+		s.Equal(expected.employee, employee, location)
+		s.Equal(expected.field, field, location)
+	}
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"context"
+	"reflect"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// businessFields are picked from struct arguments of workflows and activities.
+var businessFields = []string{"CompanyID", "PayrollID", "PayslipID", "EmployeeID", "PaymentID"}
+
+// Interceptor adds business IDs to loggers returned by workflow.GetLogger and activity.GetLogger. SDK already adds
+// workflow ID, run ID, activity type and attempt. With company or payroll ID on top of that, logs can be searched the
+// same way support gets asked about them.
+type Interceptor struct {
+	interceptor.WorkerInterceptorBase
+	argNames map[string]string
+}
+
+// NewInterceptor needs to know how to name arguments that are not structs, e.g. payrollID passed as plain string.
+// argNames maps workflow or activity type to the name of its first argument.
+func NewInterceptor(argNames map[string]string) *Interceptor {
+	return &Interceptor{argNames: argNames}
+}
+
+func (i *Interceptor) InterceptActivity(_ context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	a := &activityInbound{root: i}
+	a.Next = next
+	return a
+}
+
+func (i *Interceptor) InterceptWorkflow(_ workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	w := &workflowInbound{root: i}
+	w.Next = next
+	return w
+}
+
+// fields returns key-value pairs of business IDs found in arguments.
+func (i *Interceptor) fields(typeName string, args []interface{}) []interface{} {
+	var fields []interface{}
+	for idx, arg := range args {
+		value := reflect.Indirect(reflect.ValueOf(arg))
+		switch value.Kind() {
+		case reflect.String:
+			if name, ok := i.argNames[typeName]; ok && idx == 0 && value.String() != "" {
+				fields = append(fields, name, value.String())
+			}
+		case reflect.Struct:
+			for _, name := range businessFields {
+				field := value.FieldByName(name)
+				if field.Kind() == reflect.String && field.String() != "" {
+					fields = append(fields, name, field.String())
+				}
+			}
+		}
+	}
+	return fields
+}
+
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	root   *Interceptor
+	fields []interface{}
+}
+
+func (a *activityInbound) Init(outbound interceptor.ActivityOutboundInterceptor) error {
+	o := &activityOutbound{inbound: a}
+	o.Next = outbound
+	return a.Next.Init(o)
+}
+
+func (a *activityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	a.fields = a.root.fields(activity.GetInfo(ctx).ActivityType.Name, in.Args)
+	return a.Next.ExecuteActivity(ctx, in)
+}
+
+type activityOutbound struct {
+	interceptor.ActivityOutboundInterceptorBase
+	inbound *activityInbound
+}
+
+func (o *activityOutbound) GetLogger(ctx context.Context) log.Logger {
+	return with(o.Next.GetLogger(ctx), o.inbound.fields)
+}
+
+type workflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	root   *Interceptor
+	fields []interface{}
+}
+
+func (w *workflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	o := &workflowOutbound{inbound: w}
+	o.Next = outbound
+	return w.Next.Init(o)
+}
+
+func (w *workflowInbound) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	w.fields = w.root.fields(workflow.GetInfo(ctx).WorkflowType.Name, in.Args)
+	return w.Next.ExecuteWorkflow(ctx, in)
+}
+
+type workflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	inbound *workflowInbound
+}
+
+func (o *workflowOutbound) GetLogger(ctx workflow.Context) log.Logger {
+	return with(o.Next.GetLogger(ctx), o.inbound.fields)
+}
+
+func with(logger log.Logger, fields []interface{}) log.Logger {
+	if len(fields) == 0 {
+		return logger
+	}
+	return log.With(logger, fields...)
+}
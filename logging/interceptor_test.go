@@ -0,0 +1,201 @@
+package logging
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+type InterceptorTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	logs    *recordingLogger
+	options worker.Options
+}
+
+func TestInterceptor(t *testing.T) {
+	suite.Run(t, new(InterceptorTestSuite))
+}
+
+func (s *InterceptorTestSuite) SetupTest() {
+	s.logs = &recordingLogger{}
+	s.SetLogger(s.logs)
+	s.options = worker.Options{Interceptors: []interceptor.WorkerInterceptor{
+		NewInterceptor(map[string]string{"SubmitFPS": "PayrollID", "ProcessPayroll": "PayrollID"}),
+	}}
+}
+
+type PushInput struct {
+	CompanyID string
+	PayslipID string
+	// Not a business field, stays out of logs.
+	Salary string
+	// Empty ones are left out.
+	EmployeeID string
+}
+
+func PushPayDetails(ctx context.Context, _ PushInput) error {
+	activity.GetLogger(ctx).Info("Pushing")
+	return nil
+}
+
+func SubmitFPS(ctx context.Context, _ string) error {
+	activity.GetLogger(ctx).Info("Submitting")
+	return nil
+}
+
+// SendDocuments takes a plain string too, but its name is not known.
+func SendDocuments(ctx context.Context, _ string) error {
+	activity.GetLogger(ctx).Info("Sending")
+	return nil
+}
+
+func ProcessPayroll(ctx workflow.Context, _ string, _ string) error {
+	workflow.GetLogger(ctx).Info("Processing")
+	return nil
+}
+
+func PushCompanyPayDetails(ctx workflow.Context, _ *PushInput) error {
+	workflow.GetLogger(ctx).Info("Pushing company")
+	return nil
+}
+
+func (s *InterceptorTestSuite) Test_AddsBusinessIDsToActivityLogger() {
+	tests := []struct {
+		name     string
+		activity interface{}
+		arg      interface{}
+		message  string
+		fields   map[string]interface{}
+	}{
+		{
+			name:     "struct argument",
+			activity: PushPayDetails,
+			arg:      PushInput{CompanyID: "company-1", PayslipID: "payslip-1", Salary: "secret"},
+			message:  "Pushing",
+			fields:   map[string]interface{}{"CompanyID": "company-1", "PayslipID": "payslip-1"},
+		},
+		{
+			name:     "named string argument",
+			activity: SubmitFPS,
+			arg:      "payroll-1",
+			message:  "Submitting",
+			fields:   map[string]interface{}{"PayrollID": "payroll-1"},
+		},
+		{
+			name:     "unnamed string argument",
+			activity: SendDocuments,
+			arg:      "payroll-1",
+			message:  "Sending",
+			fields:   map[string]interface{}{},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.logs.reset()
+			env := s.NewTestActivityEnvironment()
+			env.SetWorkerOptions(s.options)
+			env.RegisterActivity(test.activity)
+
+			_, err := env.ExecuteActivity(test.activity, test.arg)
+
+			s.Require().NoError(err)
+			s.Equal(test.fields, s.logs.businessFields(test.message))
+		})
+	}
+}
+
+func (s *InterceptorTestSuite) Test_AddsBusinessIDsToWorkflowLogger() {
+	tests := []struct {
+		name     string
+		workflow interface{}
+		args     []interface{}
+		message  string
+		fields   map[string]interface{}
+	}{
+		{
+			name:     "pointer to struct argument",
+			workflow: PushCompanyPayDetails,
+			args:     []interface{}{&PushInput{CompanyID: "company-1"}},
+			message:  "Pushing company",
+			fields:   map[string]interface{}{"CompanyID": "company-1"},
+		},
+		{
+			// Only the first argument is named.
+			name:     "string arguments",
+			workflow: ProcessPayroll,
+			args:     []interface{}{"payroll-1", "note"},
+			message:  "Processing",
+			fields:   map[string]interface{}{"PayrollID": "payroll-1"},
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.logs.reset()
+			env := s.NewTestWorkflowEnvironment()
+			env.SetWorkerOptions(s.options)
+			env.RegisterWorkflow(test.workflow)
+
+			env.ExecuteWorkflow(test.workflow, test.args...)
+
+			s.Require().NoError(env.GetWorkflowError())
+			s.Equal(test.fields, s.logs.businessFields(test.message))
+		})
+	}
+}
+
+// recordingLogger keeps every line it's asked to log, with fields added by log.With.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []recordedLine
+}
+
+type recordedLine struct {
+	message string
+	keyvals []interface{}
+}
+
+func (l *recordingLogger) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = nil
+}
+
+func (l *recordingLogger) log(message string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, recordedLine{message: message, keyvals: keyvals})
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.log(msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.log(msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.log(msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.log(msg, keyvals) }
+
+// businessFields of the line with message. SDK fields, like workflow ID, are left out.
+func (l *recordingLogger) businessFields(message string) map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if line.message != message {
+			continue
+		}
+		fields := map[string]interface{}{}
+		for i := 0; i+1 < len(line.keyvals); i += 2 {
+			if key, ok := line.keyvals[i].(string); ok && slices.Contains(businessFields, key) {
+				fields[key] = line.keyvals[i+1]
+			}
+		}
+		return fields
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_WritesJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "info", "JSON")
+	require.NoError(t, err)
+
+	logger.Debug("Hidden")
+	logger.Info("Payroll started", "PayrollID", "payroll-1")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	require.Equal(t, "Payroll started", line["msg"])
+	require.Equal(t, "payroll-1", line["PayrollID"])
+}
+
+func TestNew_WritesText(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "debug", FormatText)
+	require.NoError(t, err)
+
+	logger.Debug("Payroll started", "PayrollID", "payroll-1")
+
+	require.Contains(t, out.String(), `level=DEBUG msg="Payroll started" PayrollID=payroll-1`)
+}
+
+func TestNew_RejectsUnknownLevelAndFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "loud", FormatJSON)
+	require.EqualError(t, err, `invalid log level "loud"`)
+
+	_, err = New(&bytes.Buffer{}, "warn", "xml")
+	require.EqualError(t, err, `invalid log format "xml"`)
+}
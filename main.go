@@ -3,129 +3,138 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"strings"
 
-	"temporal-poc/workflows"
+	"temporal-poc/config"
 
-	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/client"
-	"go.temporal.io/sdk/temporal"
-	"go.temporal.io/sdk/worker"
 )
 
-const taskQueue = "default"
-
-func main() {
-	fmt.Println("Starting worker...")
-	ctx := context.Background()
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
 
-	clientOptions := client.Options{
-		Namespace: "default",
-	}
-	temporalClient, err := client.Dial(clientOptions)
-	if err != nil {
-		log.Fatalln("Unable to create a Temporal Client", err)
-	}
-	defer temporalClient.Close()
+var commands = []command{
+	{"worker", "run worker process", runWorker},
+	{"api", "run HTTP API for starting and tracking workflows", runAPI},
+	{"codec-server", "run codec server, so Temporal UI can show encrypted payloads", runCodecServer},
+	{"payroll process", "start processing payroll", processPayroll},
+	{"payroll status", "show state of payroll processing", payrollStatus},
+	{"payroll attention", "list payrolls waiting for FPS rejected by HMRC to be corrected", payrollsNeedingAttention},
+	{"payroll correct", "submit FPS again, after payroll HMRC rejected was corrected", correctPayroll},
+	{"paydetails push", "push pay details of a payslip to Bob", pushPayDetails},
+	{"paydetails delivery", "show what happened to pay details of a payslip", payDetailsDelivery},
+	{"paydetails attention", "list pay details pushes waiting for an operator", payDetailsNeedingAttention},
+	{"paydetails retry", "push pay details again, optionally of another payslip", retryPayDetails},
+	{"paydetails abandon", "give up on pushing pay details", abandonPayDetails},
+	{"bob resync", "sync every employee from Bob, not only recent changes", resyncFromBob},
+	{"schedules list", "list schedules", listSchedules},
+	{"schedules sync", "create, update and delete schedules to match code", syncSchedules},
+	{"schedules pause", "pause schedule", pauseSchedule},
+	{"schedules unpause", "unpause schedule", unpauseSchedule},
+	{"schedules trigger", "run scheduled action right now", triggerSchedule},
+	{"claimcheck gc", "delete blobs no longer referenced by any workflow history", collectBlobs},
+	{"histories download", "download workflow histories for replay tests", downloadHistories},
+}
 
-	err = registerSchedules(ctx, temporalClient.ScheduleClient())
-	if err != nil && !errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
-		log.Fatalln("Unable to register schedules", err)
+func main() {
+	cmd, args, ok := findCommand(os.Args[1:])
+	if !ok {
+		printUsage()
+		os.Exit(2)
 	}
 
-	w := worker.New(temporalClient, taskQueue, worker.Options{})
-	registerWorkflows(w)
-
-	go pushPayDayDetails(ctx, temporalClient)
-	go pushPayDayDetails(ctx, temporalClient)
-	go processPayroll(ctx, temporalClient)
-
-	err = w.Run(worker.InterruptCh())
+	err := cmd.run(context.Background(), args)
 	if err != nil {
-		log.Fatalln("Unable to start the Worker Process", err)
+		slog.Error("Command failed", "Command", cmd.name, "Error", err)
+		os.Exit(1)
 	}
 }
 
-func registerWorkflows(w worker.Worker) {
-	// Very simple example. Probably not the best case though. But I wanted to show cron scheduling.
-	w.RegisterWorkflow(workflows.SyncDataFromBob)
-	w.RegisterActivity(workflows.PullData)
-	w.RegisterActivity(workflows.StoreData)
-
-	// Pushing pay details in a *similar* way we did doc-sender. A lot less plumbing!
-	w.RegisterWorkflow(workflows.PushPayDetails)
-	w.RegisterActivity(workflows.PushPayDetailsToBob)
-	w.RegisterActivity(workflows.MarkPayDetailsAsBeingSent)
-	w.RegisterActivity(workflows.MarkPayDetailsAsFailed)
-	w.RegisterActivity(workflows.MarkPayDetailsAsSent)
-
-	// Processing payroll is a lot more complex workflow. It even spins its own process payments workflow.
-	w.RegisterWorkflow(workflows.ProcessPayroll)
-	w.RegisterActivity(workflows.CanPayrollBeProcessed)
-	w.RegisterActivity(workflows.ReportFPS)
-	w.RegisterActivity(workflows.CheckFPSReport)
-	w.RegisterActivity(workflows.MarkFPSAsSuccessful)
-	w.RegisterActivity(workflows.SendDocuments)
-
-	w.RegisterWorkflow(workflows.ProcessPayments)
-	w.RegisterActivity(workflows.FindPayments)
-	w.RegisterActivity(workflows.SchedulePayment)
-	w.RegisterActivity(workflows.IsPaymentPaid)
-	w.RegisterActivity(workflows.ReconcileInAccountingIntegration)
+// findCommand matches the longest command name, so "payroll process 123" finds "payroll process".
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
 }
 
-func registerSchedules(ctx context.Context, c client.ScheduleClient) error {
-	_, err := c.Create(ctx, client.ScheduleOptions{
-		ID: "sync-data-from-bob-every-minute",
-		Spec: client.ScheduleSpec{
-			CronExpressions: []string{"* * * * *"},
-		},
-		Action: &client.ScheduleWorkflowAction{
-			ID:        "sync-data-from-bob-every-minute",
-			Workflow:  workflows.SyncDataFromBob,
-			TaskQueue: taskQueue,
-		},
-		Overlap: enums.SCHEDULE_OVERLAP_POLICY_SKIP,
-	})
-	if err != nil && !alreadyScheduled(err) {
-		return err
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: temporal-poc <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", cmd.name, cmd.usage)
 	}
+	fmt.Fprintln(os.Stderr, "\nRun `temporal-poc <command> -h` to see flags of a command.")
+}
 
-	// More schedules...
-
-	return nil
+// env is what every command needs: config, connected client and positional arguments.
+type env struct {
+	cfg    config.Config
+	client client.Client
+	args   []string
 }
 
-func alreadyScheduled(err error) bool {
-	return errors.Is(err, temporal.ErrScheduleAlreadyRunning)
+// setup parses flags and positional arguments of a command, and connects to Temporal.
+func setup(fs *flag.FlagSet, args []string, argNames ...string) (env, error) {
+	cfg, args, err := parse(fs, args, argNames...)
+	if err != nil {
+		return env{}, err
+	}
+	temporalClient, err := connect(cfg, client.Options{})
+	if err != nil {
+		return env{}, err
+	}
+	return env{cfg: cfg, client: temporalClient, args: args}, nil
 }
 
-func pushPayDayDetails(ctx context.Context, c client.Client,) {
-	input := workflows.PushPayDetailsInput{
-		CompanyID: "company-id",
-		PayslipID: "payslip-id",
+// connect dials Temporal. Anything set in extra overrides what config says.
+func connect(cfg config.Config, extra client.Options) (client.Client, error) {
+	clientOptions, err := cfg.ClientOptions()
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure a Temporal Client: %w", err)
 	}
-	workflowOptions := client.StartWorkflowOptions{
-		ID:                    fmt.Sprintf("push-pay-details-%s-%s", input.CompanyID, input.PayslipID),
-		TaskQueue:             taskQueue,
-		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_TERMINATE_IF_RUNNING,
+	if extra.MetricsHandler != nil {
+		clientOptions.MetricsHandler = extra.MetricsHandler
 	}
-	_, err := c.ExecuteWorkflow(ctx, workflowOptions, workflows.PushPayDetails, input)
+	temporalClient, err := client.Dial(clientOptions)
 	if err != nil {
-		log.Fatalln("Unable to push pay details", err)
+		return nil, errors.Join(errors.New("unable to create a Temporal Client"), err)
 	}
+	return temporalClient, nil
 }
 
-func processPayroll(ctx context.Context, c client.Client,) {
-	payrollID := "payroll-id"
-	workflowOptions := client.StartWorkflowOptions{
-		ID:                    fmt.Sprintf("process-payroll-%s", payrollID),
-		TaskQueue:             taskQueue,
-		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+// parse is setup for commands that don't talk to Temporal.
+func parse(fs *flag.FlagSet, args []string, argNames ...string) (config.Config, []string, error) {
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: temporal-poc %s [flags] %s\n", fs.Name(), strings.Join(argNames, " "))
+		fs.PrintDefaults()
 	}
-	_, err := c.ExecuteWorkflow(ctx, workflowOptions, workflows.ProcessPayroll, payrollID)
+	cfg, err := config.Load(fs, args, os.Getenv)
 	if err != nil {
-		log.Fatalln("Unable to push pay details", err)
+		return config.Config{}, nil, err
+	}
+	// Config is valid, so logger can't fail.
+	logger, _ := cfg.Logger()
+	slog.SetDefault(logger)
+
+	if fs.NArg() != len(argNames) {
+		fs.Usage()
+		return config.Config{}, nil, fmt.Errorf("expected %d argument(s), got %d", len(argNames), fs.NArg())
+	}
+	for i, arg := range fs.Args() {
+		if strings.TrimSpace(arg) == "" {
+			return config.Config{}, nil, fmt.Errorf("%s can't be empty", argNames[i])
+		}
 	}
+	return cfg, fs.Args(), nil
 }
@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// Buckets of timer histograms, in seconds. From a quick local activity to an HMRC call. Timers far from that range
+// get their own, see Registry.SetBuckets.
+var Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindGauge     kind = "gauge"
+	kindHistogram kind = "histogram"
+)
+
+// Registry collects metrics emitted by SDK and workflows, and serves them in Prometheus text format.
+// Pass Handler to client.Options.MetricsHandler, and serve Registry on /metrics.
+type Registry struct {
+	mu      sync.Mutex
+	series  map[string]*series
+	buckets map[string][]float64
+}
+
+type series struct {
+	name   string
+	kind   kind
+	labels string // Already formatted, e.g. `{namespace="default",task_queue="payroll"}`.
+
+	value   float64
+	bounds  []float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{series: map[string]*series{}, buckets: map[string][]float64{}}
+}
+
+// SetBuckets replaces Buckets of timer name, as it's passed to MetricsHandler.Timer. It applies to series created
+// after, so set it before worker starts.
+func (r *Registry) SetBuckets(name string, buckets []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buckets[name] = slices.Sorted(slices.Values(buckets))
+}
+
+func (r *Registry) Handler() client.MetricsHandler {
+	return handler{registry: r}
+}
+
+func (r *Registry) get(name string, k kind, tags map[string]string) *series {
+	metric := sanitize(name)
+	if k == kindHistogram && !strings.HasSuffix(metric, "_seconds") {
+		metric += "_seconds"
+	}
+	labels := formatLabels(tags)
+	key := metric + labels
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series[key]
+	if !ok {
+		s = &series{name: metric, kind: k, labels: labels}
+		if k == kindHistogram {
+			s.bounds = Buckets
+			if buckets, ok := r.buckets[name]; ok {
+				s.bounds = buckets
+			}
+			s.buckets = make([]uint64, len(s.bounds))
+		}
+		r.series[key] = s
+	}
+	return s
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Write outputs all series, grouped by metric name.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Sorted by name first. Sorting by key would put series of other metrics, whose name only starts the same, in
+	// between, and metric would get its TYPE twice.
+	all := make([]*series, 0, len(r.series))
+	for _, s := range r.series {
+		all = append(all, s)
+	}
+	slices.SortFunc(all, func(a, b *series) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(a.labels, b.labels))
+	})
+
+	lastName := ""
+	for _, s := range all {
+		if s.name != lastName {
+			fmt.Fprintf(w, "# TYPE %s %s\n", s.name, s.kind)
+			lastName = s.name
+		}
+		switch s.kind {
+		case kindCounter, kindGauge:
+			fmt.Fprintf(w, "%s%s %s\n", s.name, s.labels, formatFloat(s.value))
+		case kindHistogram:
+			var cumulative uint64
+			for i, bound := range s.bounds {
+				cumulative += s.buckets[i]
+				fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, withLabel(s.labels, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, withLabel(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", s.name, s.labels, formatFloat(s.sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", s.name, s.labels, s.count)
+		}
+	}
+}
+
+type handler struct {
+	registry *Registry
+	tags     map[string]string
+}
+
+func (h handler) WithTags(tags map[string]string) client.MetricsHandler {
+	merged := make(map[string]string, len(h.tags)+len(tags))
+	for k, v := range h.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return handler{registry: h.registry, tags: merged}
+}
+
+func (h handler) Counter(name string) client.MetricsCounter {
+	s := h.registry.get(name, kindCounter, h.tags)
+	return counterFunc(func(d int64) {
+		h.registry.mu.Lock()
+		defer h.registry.mu.Unlock()
+		s.value += float64(d)
+	})
+}
+
+func (h handler) Gauge(name string) client.MetricsGauge {
+	s := h.registry.get(name, kindGauge, h.tags)
+	return gaugeFunc(func(v float64) {
+		h.registry.mu.Lock()
+		defer h.registry.mu.Unlock()
+		s.value = v
+	})
+}
+
+func (h handler) Timer(name string) client.MetricsTimer {
+	s := h.registry.get(name, kindHistogram, h.tags)
+	return timerFunc(func(d time.Duration) {
+		seconds := d.Seconds()
+		h.registry.mu.Lock()
+		defer h.registry.mu.Unlock()
+		if i, _ := slices.BinarySearch(s.bounds, seconds); i < len(s.bounds) {
+			s.buckets[i]++
+		}
+		s.sum += seconds
+		s.count++
+	})
+}
+
+type counterFunc func(int64)
+
+func (f counterFunc) Inc(d int64) { f(d) }
+
+type gaugeFunc func(float64)
+
+func (f gaugeFunc) Update(v float64) { f(v) }
+
+type timerFunc func(time.Duration)
+
+func (f timerFunc) Record(d time.Duration) { f(d) }
+
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = sanitize(name) + `="` + escape(tags[name]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+// sanitize turns anything into a valid Prometheus metric or label name.
+func sanitize(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9')
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// Run `go test ./metrics -update` after changing the output on purpose, and review the diff of testdata.
+var update = flag.Bool("update", false, "update golden files")
+
+type RegistryTestSuite struct {
+	suite.Suite
+
+	registry *Registry
+}
+
+func TestRegistry(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
+
+func (s *RegistryTestSuite) SetupTest() {
+	s.registry = NewRegistry()
+}
+
+func (s *RegistryTestSuite) written() string {
+	var out bytes.Buffer
+	s.registry.Write(&out)
+	return out.String()
+}
+
+// golden compares output with testdata/name.
+func (s *RegistryTestSuite) golden(name string, output string) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		s.Require().NoError(os.WriteFile(path, []byte(output), 0o644))
+	}
+	expected, err := os.ReadFile(path)
+	s.Require().NoError(err)
+	s.Equal(string(expected), output)
+}
+
+func (s *RegistryTestSuite) Test_WritesPrometheusTextFormat() {
+	s.registry.SetBuckets("fps latency", []float64{3600, 60})
+	handler := s.registry.Handler().WithTags(map[string]string{"namespace": "default"})
+	// Names that start the same, but sort in between series of each other when labels are taken into account.
+	handler.WithTags(map[string]string{"task_queue": "payroll"}).Counter("requests").Inc(2)
+	handler.Counter("requests_failed").Inc(1)
+	handler.WithTags(map[string]string{"task_queue": "bob-sync"}).Counter("requests").Inc(1)
+	handler.Gauge("pollers").Update(4)
+	handler.Timer("latency").Record(300 * time.Millisecond)
+	handler.Timer("latency").Record(2 * time.Minute)
+	handler.Timer("fps latency").Record(10 * time.Minute)
+	handler.WithTags(map[string]string{"reason": "line\n\"quoted\""}).Counter("odd-name").Inc(1)
+
+	s.golden("metrics.txt", s.written())
+}
+
+func (s *RegistryTestSuite) Test_WritesTypeOncePerMetric() {
+	handler := s.registry.Handler()
+	handler.Counter("requests").Inc(1)
+	handler.WithTags(map[string]string{"task_queue": "a"}).Counter("requests").Inc(1)
+	handler.Counter("requests_total").Inc(1)
+
+	s.Equal(`# TYPE requests counter
+requests 1
+requests{task_queue="a"} 1
+# TYPE requests_total counter
+requests_total 1
+`, s.written())
+}
@@ -0,0 +1,101 @@
+// Package payroll has what a processed payroll run consists of. Amounts are in pennies, like everywhere else.
+package payroll
+
+import "time"
+
+// PayFrequency uses HMRC codes, e.g. M1 is monthly, W2 fortnightly.
+type PayFrequency string
+
+const (
+	Weekly      PayFrequency = "W1"
+	Fortnightly PayFrequency = "W2"
+	FourWeekly  PayFrequency = "W4"
+	Monthly     PayFrequency = "M1"
+	Quarterly   PayFrequency = "M3"
+	HalfYearly  PayFrequency = "M6"
+	Annually    PayFrequency = "MA"
+)
+
+// Weekly frequencies count periods in tax weeks, the others in tax months.
+func (f PayFrequency) Weekly() bool {
+	return f == Weekly || f == Fortnightly || f == FourWeekly
+}
+
+// Run is a single pay day of a company.
+type Run struct {
+	PayrollID   string
+	Employer    Employer
+	PaymentDate time.Time
+	Frequency   PayFrequency
+	// Period is the tax week or month the payment falls into.
+	Period   int
+	Payslips []Payslip
+}
+
+// Employer is identified by its PAYE reference, which is tax office number and reference, e.g. 123/AB456.
+type Employer struct {
+	TaxOfficeNumber         string
+	TaxOfficeReference      string
+	AccountsOfficeReference string
+}
+
+type Employee struct {
+	// PayrollID is unique within employer, and stays the same for as long as the employment does.
+	PayrollID string
+	// NINO can be empty, if employee doesn't know it yet.
+	NINO      string
+	Forename  string
+	Surname   string
+	BirthDate time.Time
+	// Gender as HMRC records it, M or F.
+	Gender    string
+	Address   Address
+	StartDate time.Time
+	// LeavingDate is zero, unless employee leaves with this payment.
+	LeavingDate time.Time
+}
+
+type Address struct {
+	Lines    []string
+	Postcode string
+}
+
+// HoursWorked are HMRC bands of normal weekly hours.
+type HoursWorked string
+
+const (
+	HoursUpTo16    HoursWorked = "A"
+	Hours16To24    HoursWorked = "B"
+	Hours24To30    HoursWorked = "C"
+	Hours30OrMore  HoursWorked = "D"
+	HoursOtherwise HoursWorked = "E"
+)
+
+type Payslip struct {
+	Employee Employee
+	// NewStarter is set until employee was reported to HMRC for the first time.
+	NewStarter  bool
+	HoursWorked HoursWorked
+	// TaxCode as on the payslip. Scottish (S) and Welsh (C) prefixes, and W1/M1/X suffixes of emergency codes, are
+	// allowed.
+	TaxCode    string
+	NICategory string
+
+	TaxablePay    int64
+	TaxablePayYTD int64
+	Tax           int64
+	TaxYTD        int64
+
+	// NIablePay is gross pay NICs are calculated from.
+	NIablePay    int64
+	NIablePayYTD int64
+	// Earnings in NIC bands, year to date: up to lower earnings limit, from it to primary threshold, and from that to
+	// upper earnings limit.
+	AtLELYTD       int64
+	LELToPTYTD     int64
+	PTToUELYTD     int64
+	EmployeeNIC    int64
+	EmployeeNICYTD int64
+	EmployerNIC    int64
+	EmployerNICYTD int64
+}
@@ -0,0 +1,315 @@
+package schedules
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Schedule is desired state of a single schedule. It is always started as a workflow with the same ID as schedule.
+type Schedule struct {
+	ID        string
+	Cron      []string
+	TimeZone  string
+	Jitter    time.Duration
+	Overlap   enums.ScheduleOverlapPolicy
+	Paused    bool
+	Workflow  interface{}
+	Args      []interface{}
+	TaskQueue string
+}
+
+const (
+	// managedMemo marks schedules owned by reconciler. Anything else on the server (e.g. created by hand) is left alone.
+	managedMemo = "managed-by"
+	managedBy   = "temporal-poc"
+	// fingerprintMemo is stored on the scheduled action, because unlike schedule memo, it can be updated.
+	// Server rewrites cron expressions into calendars, so we can't just compare specs with what Describe returns.
+	fingerprintMemo = "schedule-fingerprint"
+)
+
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	// Replace adopts schedule with the same ID that was not created by reconciler. Its history is lost.
+	Replace   Action = "replace"
+	Unchanged Action = "unchanged"
+)
+
+type Change struct {
+	Action   Action
+	ID       string
+	Schedule Schedule
+}
+
+type Plan []Change
+
+// Print writes plan in human-readable form, one schedule per line.
+func (p Plan) Print(w io.Writer) {
+	for _, change := range p {
+		fmt.Fprintf(w, "%-10s %s\n", change.Action, change.ID)
+	}
+}
+
+func (p Plan) HasChanges() bool {
+	return slices.ContainsFunc(p, func(c Change) bool { return c.Action != Unchanged })
+}
+
+type Reconciler struct {
+	client  client.ScheduleClient
+	desired []Schedule
+}
+
+func NewReconciler(c client.ScheduleClient, desired []Schedule) (*Reconciler, error) {
+	seen := map[string]bool{}
+	for _, schedule := range desired {
+		if schedule.ID == "" {
+			return nil, errors.New("schedule ID is required")
+		}
+		if seen[schedule.ID] {
+			return nil, fmt.Errorf("schedule %q is defined twice", schedule.ID)
+		}
+		seen[schedule.ID] = true
+	}
+	return &Reconciler{client: c, desired: desired}, nil
+}
+
+// Plan compares desired schedules with the ones on the server. Nothing is changed.
+func (r *Reconciler) Plan(ctx context.Context) (Plan, error) {
+	existing, unmanaged, err := r.existing(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	for _, schedule := range r.desired {
+		fingerprint, err := schedule.fingerprint()
+		if err != nil {
+			return nil, err
+		}
+		current, ok := existing[schedule.ID]
+		delete(existing, schedule.ID)
+		switch {
+		case unmanaged[schedule.ID]:
+			plan = append(plan, Change{Action: Replace, ID: schedule.ID, Schedule: schedule})
+		case !ok:
+			plan = append(plan, Change{Action: Create, ID: schedule.ID, Schedule: schedule})
+		case current != fingerprint:
+			plan = append(plan, Change{Action: Update, ID: schedule.ID, Schedule: schedule})
+		default:
+			plan = append(plan, Change{Action: Unchanged, ID: schedule.ID, Schedule: schedule})
+		}
+	}
+	for id := range existing {
+		plan = append(plan, Change{Action: Delete, ID: id})
+	}
+	slices.SortFunc(plan, func(a, b Change) int { return strings.Compare(a.ID, b.ID) })
+
+	return plan, nil
+}
+
+// Apply executes a plan. Paused state is part of the definition, but it is applied only when definition changes.
+// That way operator can pause a schedule, and it won't be unpaused by next deployment.
+func (r *Reconciler) Apply(ctx context.Context, plan Plan) error {
+	for _, change := range plan {
+		var err error
+		switch change.Action {
+		case Create:
+			err = r.create(ctx, change.Schedule)
+		case Update:
+			err = r.update(ctx, change.Schedule)
+		case Delete:
+			err = r.client.GetHandle(ctx, change.ID).Delete(ctx)
+		case Replace:
+			err = r.client.GetHandle(ctx, change.ID).Delete(ctx)
+			if err == nil || isNotFound(err) {
+				err = r.create(ctx, change.Schedule)
+			}
+		}
+		if err != nil && !converged(err) {
+			return fmt.Errorf("%s schedule %q: %w", change.Action, change.ID, err)
+		}
+	}
+	return nil
+}
+
+// converged is true when another reconciler, running at the same time, already made the change.
+func converged(err error) bool {
+	return errors.Is(err, temporal.ErrScheduleAlreadyRunning) || isNotFound(err)
+}
+
+func isNotFound(err error) bool {
+	var notFound *serviceerror.NotFound
+	return errors.As(err, &notFound)
+}
+
+// Reconcile is Plan followed by Apply.
+func (r *Reconciler) Reconcile(ctx context.Context) (Plan, error) {
+	plan, err := r.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return plan, r.Apply(ctx, plan)
+}
+
+func (r *Reconciler) create(ctx context.Context, schedule Schedule) error {
+	action, err := schedule.action()
+	if err != nil {
+		return err
+	}
+	_, err = r.client.Create(ctx, client.ScheduleOptions{
+		ID:      schedule.ID,
+		Spec:    schedule.spec(),
+		Action:  action,
+		Overlap: schedule.Overlap,
+		Paused:  schedule.Paused,
+		Memo:    map[string]interface{}{managedMemo: managedBy},
+	})
+	return err
+}
+
+func (r *Reconciler) update(ctx context.Context, schedule Schedule) error {
+	action, err := schedule.action()
+	if err != nil {
+		return err
+	}
+	spec := schedule.spec()
+	return r.client.GetHandle(ctx, schedule.ID).Update(ctx, client.ScheduleUpdateOptions{
+		DoUpdate: func(input client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+			updated := input.Description.Schedule
+			updated.Action = action
+			updated.Spec = &spec
+			if updated.Policy == nil {
+				updated.Policy = &client.SchedulePolicies{}
+			}
+			updated.Policy.Overlap = schedule.Overlap
+			if updated.State == nil {
+				updated.State = &client.ScheduleState{}
+			}
+			updated.State.Paused = schedule.Paused
+			return &client.ScheduleUpdate{Schedule: &updated}, nil
+		},
+	})
+}
+
+// existing returns fingerprint of every schedule owned by reconciler, and IDs of all the other schedules.
+func (r *Reconciler) existing(ctx context.Context) (map[string]string, map[string]bool, error) {
+	schedules, err := r.client.List(ctx, client.ScheduleListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fingerprints := map[string]string{}
+	unmanaged := map[string]bool{}
+	for schedules.HasNext() {
+		entry, err := schedules.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isManaged(entry.Memo) {
+			unmanaged[entry.ID] = true
+			continue
+		}
+		description, err := r.client.GetHandle(ctx, entry.ID).Describe(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("describing schedule %q: %w", entry.ID, err)
+		}
+		fingerprints[entry.ID] = actionFingerprint(description.Schedule.Action)
+	}
+	return fingerprints, unmanaged, nil
+}
+
+func isManaged(memo *commonpb.Memo) bool {
+	var owner string
+	payload, ok := memo.GetFields()[managedMemo]
+	if !ok || converter.GetDefaultDataConverter().FromPayload(payload, &owner) != nil {
+		return false
+	}
+	return owner == managedBy
+}
+
+func actionFingerprint(action client.ScheduleAction) string {
+	workflowAction, ok := action.(*client.ScheduleWorkflowAction)
+	if !ok {
+		return ""
+	}
+	// Memo of described schedule is not decoded for us.
+	payload, ok := workflowAction.Memo[fingerprintMemo].(*commonpb.Payload)
+	if !ok {
+		return ""
+	}
+	var fingerprint string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &fingerprint); err != nil {
+		return ""
+	}
+	return fingerprint
+}
+
+func (s Schedule) spec() client.ScheduleSpec {
+	return client.ScheduleSpec{
+		CronExpressions: s.Cron,
+		TimeZoneName:    s.TimeZone,
+		Jitter:          s.Jitter,
+	}
+}
+
+func (s Schedule) action() (*client.ScheduleWorkflowAction, error) {
+	fingerprint, err := s.fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	return &client.ScheduleWorkflowAction{
+		ID:        s.ID,
+		Workflow:  s.Workflow,
+		Args:      s.Args,
+		TaskQueue: s.TaskQueue,
+		Memo:      map[string]interface{}{fingerprintMemo: fingerprint},
+	}, nil
+}
+
+// fingerprint changes whenever anything we control about the schedule changes.
+func (s Schedule) fingerprint() (string, error) {
+	raw, err := json.Marshal(struct {
+		Cron      []string
+		TimeZone  string
+		Jitter    time.Duration
+		Overlap   enums.ScheduleOverlapPolicy
+		Paused    bool
+		Workflow  string
+		Args      []interface{}
+		TaskQueue string
+	}{s.Cron, s.TimeZone, s.Jitter, s.Overlap, s.Paused, workflowName(s.Workflow), s.Args, s.TaskQueue})
+	if err != nil {
+		return "", fmt.Errorf("fingerprinting schedule %q: %w", s.ID, err)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// workflowName mimics how SDK names workflows registered as functions.
+func workflowName(workflow interface{}) string {
+	if name, ok := workflow.(string); ok {
+		return name
+	}
+	fullName := runtime.FuncForPC(reflect.ValueOf(workflow).Pointer()).Name()
+	return strings.TrimSuffix(fullName[strings.LastIndex(fullName, ".")+1:], "-fm")
+}
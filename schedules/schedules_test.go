@@ -0,0 +1,350 @@
+package schedules
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+type ReconcilerTestSuite struct {
+	suite.Suite
+
+	ctx    context.Context
+	client *fakeScheduleClient
+}
+
+func TestReconciler(t *testing.T) {
+	suite.Run(t, new(ReconcilerTestSuite))
+}
+
+func (s *ReconcilerTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.client = newFakeScheduleClient()
+}
+
+func SyncFromBob() error { return nil }
+
+var nightlySync = Schedule{
+	ID:        "sync-from-bob",
+	Cron:      []string{"0 2 * * *"},
+	TimeZone:  "Europe/London",
+	Overlap:   enums.SCHEDULE_OVERLAP_POLICY_SKIP,
+	Workflow:  SyncFromBob,
+	TaskQueue: "bob-sync",
+}
+
+func (s *ReconcilerTestSuite) reconciler(desired ...Schedule) *Reconciler {
+	reconciler, err := NewReconciler(s.client, desired)
+	s.Require().NoError(err)
+	return reconciler
+}
+
+// deployed creates schedule on the server, as reconciler would.
+func (s *ReconcilerTestSuite) deployed(schedule Schedule) {
+	_, err := s.reconciler(schedule).Reconcile(s.ctx)
+	s.Require().NoError(err)
+}
+
+func (s *ReconcilerTestSuite) Test_Plan() {
+	paused := nightlySync
+	paused.Paused = true
+	moved := nightlySync
+	moved.Cron = []string{"0 3 * * *"}
+	otherQueue := nightlySync
+	otherQueue.TaskQueue = "documents"
+	retired := nightlySync
+	retired.ID = "retired"
+
+	tests := []struct {
+		name    string
+		server  func()
+		desired []Schedule
+		plan    string
+	}{
+		{
+			name:    "schedule is not on the server",
+			server:  func() {},
+			desired: []Schedule{nightlySync},
+			plan:    "create     sync-from-bob\n",
+		},
+		{
+			name:    "schedule didn't change",
+			server:  func() { s.deployed(nightlySync) },
+			desired: []Schedule{nightlySync},
+			plan:    "unchanged  sync-from-bob\n",
+		},
+		{
+			name:    "cron changed",
+			server:  func() { s.deployed(nightlySync) },
+			desired: []Schedule{moved},
+			plan:    "update     sync-from-bob\n",
+		},
+		{
+			name:    "task queue changed",
+			server:  func() { s.deployed(nightlySync) },
+			desired: []Schedule{otherQueue},
+			plan:    "update     sync-from-bob\n",
+		},
+		{
+			name:    "paused in definition",
+			server:  func() { s.deployed(nightlySync) },
+			desired: []Schedule{paused},
+			plan:    "update     sync-from-bob\n",
+		},
+		{
+			name:    "schedule with the same ID was created by hand",
+			server:  func() { s.client.createdByHand("sync-from-bob") },
+			desired: []Schedule{nightlySync},
+			plan:    "replace    sync-from-bob\n",
+		},
+		{
+			name:    "schedule was removed from definitions",
+			server:  func() { s.deployed(retired) },
+			desired: []Schedule{nightlySync},
+			plan:    "delete     retired\ncreate     sync-from-bob\n",
+		},
+		{
+			name:    "schedule created by hand is left alone",
+			server:  func() { s.client.createdByHand("ad-hoc") },
+			desired: nil,
+			plan:    "",
+		},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.client = newFakeScheduleClient()
+			test.server()
+
+			plan, err := s.reconciler(test.desired...).Plan(s.ctx)
+
+			s.Require().NoError(err)
+			var printed strings.Builder
+			plan.Print(&printed)
+			s.Equal(test.plan, printed.String())
+		})
+	}
+}
+
+func (s *ReconcilerTestSuite) Test_Apply_CreatesManagedSchedule() {
+	plan, err := s.reconciler(nightlySync).Reconcile(s.ctx)
+
+	s.Require().NoError(err)
+	s.True(plan.HasChanges())
+	created := s.client.schedules["sync-from-bob"]
+	s.True(created.managed)
+	s.Equal([]string{"0 2 * * *"}, created.schedule.Spec.CronExpressions)
+	s.Equal(enums.SCHEDULE_OVERLAP_POLICY_SKIP, created.schedule.Policy.Overlap)
+	s.Equal("bob-sync", created.schedule.Action.(*client.ScheduleWorkflowAction).TaskQueue)
+}
+
+func (s *ReconcilerTestSuite) Test_Apply_KeepsSchedulePausedByOperator_UntilDefinitionChanges() {
+	s.deployed(nightlySync)
+	s.client.schedules["sync-from-bob"].schedule.State.Paused = true
+
+	s.deployed(nightlySync)
+	s.True(s.client.schedules["sync-from-bob"].schedule.State.Paused)
+
+	moved := nightlySync
+	moved.Cron = []string{"0 3 * * *"}
+	s.deployed(moved)
+	s.False(s.client.schedules["sync-from-bob"].schedule.State.Paused)
+	s.Equal([]string{"0 3 * * *"}, s.client.schedules["sync-from-bob"].schedule.Spec.CronExpressions)
+}
+
+func (s *ReconcilerTestSuite) Test_Apply_ReplacesScheduleCreatedByHand() {
+	s.client.createdByHand("sync-from-bob")
+
+	s.deployed(nightlySync)
+
+	s.True(s.client.schedules["sync-from-bob"].managed)
+	s.Equal([]string{"sync-from-bob"}, s.client.deleted)
+}
+
+func (s *ReconcilerTestSuite) Test_Apply_ConvergesWithReconcilerRunningAtTheSameTime() {
+	moved := nightlySync
+	moved.Cron = []string{"0 3 * * *"}
+	tests := []struct {
+		name    string
+		server  func()
+		desired []Schedule
+		// raced is the action another reconciler already did, after the plan was made.
+		raced Action
+		err   error
+	}{
+		{name: "created", server: func() {}, desired: []Schedule{nightlySync}, raced: Create, err: temporal.ErrScheduleAlreadyRunning},
+		{name: "updated", server: func() { s.deployed(nightlySync) }, desired: []Schedule{moved}, raced: Update, err: serviceerror.NewNotFound("schedule not found")},
+		{name: "deleted", server: func() { s.deployed(nightlySync) }, desired: nil, raced: Delete, err: serviceerror.NewNotFound("schedule not found")},
+		{name: "replaced", server: func() { s.client.createdByHand("sync-from-bob") }, desired: []Schedule{nightlySync}, raced: Create, err: temporal.ErrScheduleAlreadyRunning},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			s.client = newFakeScheduleClient()
+			test.server()
+			s.client.errs = map[Action]error{test.raced: test.err}
+
+			_, err := s.reconciler(test.desired...).Reconcile(s.ctx)
+
+			s.NoError(err)
+		})
+	}
+}
+
+func (s *ReconcilerTestSuite) Test_Apply_Fails_WhenServerDoes() {
+	s.client.errs = map[Action]error{Create: serviceerror.NewUnavailable("server is down")}
+
+	_, err := s.reconciler(nightlySync).Reconcile(s.ctx)
+
+	s.ErrorContains(err, `create schedule "sync-from-bob": server is down`)
+}
+
+func (s *ReconcilerTestSuite) Test_RejectsInvalidDefinitions() {
+	tests := []struct {
+		name    string
+		desired []Schedule
+		err     string
+	}{
+		{name: "missing ID", desired: []Schedule{{Workflow: SyncFromBob}}, err: "schedule ID is required"},
+		{name: "duplicate ID", desired: []Schedule{nightlySync, nightlySync}, err: `schedule "sync-from-bob" is defined twice`},
+	}
+	for _, test := range tests {
+		s.Run(test.name, func() {
+			_, err := NewReconciler(s.client, test.desired)
+
+			s.EqualError(err, test.err)
+		})
+	}
+}
+
+// fakeScheduleClient keeps schedules the way server describes them. Anything else it's asked panics.
+type fakeScheduleClient struct {
+	schedules map[string]*fakeSchedule
+	deleted   []string
+	// errs fail actions, before they change anything.
+	errs map[Action]error
+}
+
+func newFakeScheduleClient() *fakeScheduleClient {
+	return &fakeScheduleClient{schedules: map[string]*fakeSchedule{}}
+}
+
+type fakeSchedule struct {
+	managed  bool
+	schedule client.Schedule
+}
+
+func (c *fakeScheduleClient) createdByHand(id string) {
+	c.schedules[id] = &fakeSchedule{schedule: client.Schedule{
+		Action: &client.ScheduleWorkflowAction{ID: id, Workflow: "SyncFromBob"},
+		State:  &client.ScheduleState{},
+	}}
+}
+
+func (c *fakeScheduleClient) Create(_ context.Context, options client.ScheduleOptions) (client.ScheduleHandle, error) {
+	if err := c.errs[Create]; err != nil {
+		return nil, err
+	}
+	action := *options.Action.(*client.ScheduleWorkflowAction)
+	// Server returns memo encoded.
+	action.Memo = encodeMemo(action.Memo)
+	spec := options.Spec
+	c.schedules[options.ID] = &fakeSchedule{
+		managed: options.Memo[managedMemo] == managedBy,
+		schedule: client.Schedule{
+			Action: &action,
+			Spec:   &spec,
+			Policy: &client.SchedulePolicies{Overlap: options.Overlap},
+			State:  &client.ScheduleState{Paused: options.Paused},
+		},
+	}
+	return c.GetHandle(context.Background(), options.ID), nil
+}
+
+func (c *fakeScheduleClient) List(context.Context, client.ScheduleListOptions) (client.ScheduleListIterator, error) {
+	var entries []*client.ScheduleListEntry
+	for id, schedule := range c.schedules {
+		entry := &client.ScheduleListEntry{ID: id}
+		if schedule.managed {
+			payload, _ := converter.GetDefaultDataConverter().ToPayload(managedBy)
+			entry.Memo = &commonpb.Memo{Fields: map[string]*commonpb.Payload{managedMemo: payload}}
+		}
+		entries = append(entries, entry)
+	}
+	return &fakeScheduleList{entries: entries}, nil
+}
+
+func (c *fakeScheduleClient) GetHandle(_ context.Context, id string) client.ScheduleHandle {
+	return &fakeScheduleHandle{client: c, id: id}
+}
+
+type fakeScheduleHandle struct {
+	client.ScheduleHandle
+	client *fakeScheduleClient
+	id     string
+}
+
+func (h *fakeScheduleHandle) GetID() string {
+	return h.id
+}
+
+func (h *fakeScheduleHandle) Delete(context.Context) error {
+	if err := h.client.errs[Delete]; err != nil {
+		return err
+	}
+	delete(h.client.schedules, h.id)
+	h.client.deleted = append(h.client.deleted, h.id)
+	return nil
+}
+
+func (h *fakeScheduleHandle) Describe(context.Context) (*client.ScheduleDescription, error) {
+	return &client.ScheduleDescription{Schedule: h.client.schedules[h.id].schedule}, nil
+}
+
+func (h *fakeScheduleHandle) Update(_ context.Context, options client.ScheduleUpdateOptions) error {
+	if err := h.client.errs[Update]; err != nil {
+		return err
+	}
+	current := h.client.schedules[h.id]
+	update, err := options.DoUpdate(client.ScheduleUpdateInput{Description: client.ScheduleDescription{Schedule: current.schedule}})
+	if err != nil {
+		return err
+	}
+	action := *update.Schedule.Action.(*client.ScheduleWorkflowAction)
+	action.Memo = encodeMemo(action.Memo)
+	update.Schedule.Action = &action
+	current.schedule = *update.Schedule
+	return nil
+}
+
+func encodeMemo(memo map[string]interface{}) map[string]interface{} {
+	encoded := map[string]interface{}{}
+	for key, value := range memo {
+		payload, err := converter.GetDefaultDataConverter().ToPayload(value)
+		if err != nil {
+			panic(err)
+		}
+		encoded[key] = payload
+	}
+	return encoded
+}
+
+type fakeScheduleList struct {
+	entries []*client.ScheduleListEntry
+}
+
+func (l *fakeScheduleList) HasNext() bool {
+	return len(l.entries) > 0
+}
+
+func (l *fakeScheduleList) Next() (*client.ScheduleListEntry, error) {
+	entry := l.entries[0]
+	l.entries = l.entries[1:]
+	return entry, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"temporal-poc/api"
+	"temporal-poc/codec"
+	"temporal-poc/delivery"
+
+	"go.temporal.io/sdk/worker"
+)
+
+func runAPI(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	// Temporal UI already takes 8080.
+	listen := fs.String("listen", ":8081", "address HTTP API listens on")
+	e, err := setup(fs, args)
+	if err != nil {
+		return err
+	}
+	defer e.client.Close()
+
+	opts := api.ServerOptions{BobWebhookSecret: e.cfg.Bob.WebhookSecret}
+	if e.cfg.Bob.WebhookSecret == "" {
+		slog.Info("Bob webhook secret is not set, webhook is disabled")
+	}
+	// Deliveries in memory of the worker can't be read from here.
+	if e.cfg.Deliveries.Dir == "" {
+		slog.Info("Deliveries directory is not set, delivery status is disabled")
+	} else {
+		if opts.Deliveries, err = delivery.NewFileStore(e.cfg.Deliveries.Dir); err != nil {
+			return err
+		}
+	}
+	return serve(ctx, *listen, api.NewServer(e.client, opts))
+}
+
+func runCodecServer(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("codec-server", flag.ExitOnError)
+	listen := fs.String("listen", ":8082", "address codec server listens on")
+	cfg, _, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	codecs, err := cfg.Codecs()
+	if err != nil {
+		return err
+	}
+	if len(codecs) == 0 {
+		return errors.New("neither encryption nor claim check is configured, there is nothing to decode")
+	}
+	if len(cfg.CodecServer.Tokens) == 0 {
+		slog.Warn("CODEC_SERVER_TOKENS is not set. Anyone who can reach codec server can decrypt payloads!")
+	}
+
+	handler := codec.NewServer(codec.ServerOptions{
+		AllowedOrigins: cfg.CodecServer.AllowedOrigins,
+		Tokens:         cfg.CodecServer.Tokens,
+	}, codecs...)
+	return serve(ctx, *listen, handler)
+}
+
+// serve runs HTTP server until the process is interrupted.
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "Address", addr)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-worker.InterruptCh():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
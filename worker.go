@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+
+	"temporal-poc/logging"
+	"temporal-poc/metrics"
+	"temporal-poc/schedules"
+	"temporal-poc/workflows"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/worker"
+)
+
+func runWorker(ctx context.Context, args []string) error {
+	cfg, _, err := parse(flag.NewFlagSet("worker", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	registry := metrics.NewRegistry()
+	registry.SetBuckets(workflows.MetricFPSSubmissionLatency, workflows.FPSSubmissionLatencyBuckets)
+	temporalClient, err := connect(cfg, client.Options{MetricsHandler: registry.Handler()})
+	if err != nil {
+		return err
+	}
+	defer temporalClient.Close()
+	e := env{cfg: cfg, client: temporalClient}
+
+	slog.Info("Starting worker", "Queues", e.cfg.Worker.Queues)
+	// Scheduled workflows run on bob-sync queue, so its workers own schedules. The rest would only race them.
+	if slices.Contains(e.cfg.Worker.Queues, workflows.TaskQueueBobSync) {
+		plan, err := reconcileSchedules(ctx, e.client.ScheduleClient())
+		if err != nil {
+			return fmt.Errorf("unable to reconcile schedules: %w", err)
+		}
+		for _, change := range plan {
+			slog.Info("Schedule reconciled", "ScheduleID", change.ID, "Action", change.Action)
+		}
+	}
+	if err := ensureSearchAttributes(ctx, e.client, cfg.Temporal.Namespace); err != nil {
+		return fmt.Errorf("unable to register search attributes: %w", err)
+	}
+
+	bobClient, err := cfg.BobClient()
+	if err != nil {
+		return err
+	}
+	if cfg.Bob.URL == "" {
+		slog.Warn("Bob is not configured, using fake one")
+	}
+	deliveries, err := cfg.DeliveryStore()
+	if err != nil {
+		return err
+	}
+	employeeStore, err := cfg.EmployeeStore()
+	if err != nil {
+		return err
+	}
+	activities := &workflows.Activities{
+		Bob:        bobClient,
+		PayDetails: workflows.FakePayDetailsRepository{},
+		Employees:  employeeStore,
+		Events:     workflows.SignalEmployeeChanges{Client: e.client},
+		Deliveries: deliveries,
+		Payrolls:   workflows.FakePayrollRuns{},
+		HMRC:       cfg.FakeHMRC(),
+		GovTalk:    cfg.GovTalkOptions(),
+	}
+
+	interceptors := []interceptor.WorkerInterceptor{logging.NewInterceptor(workflows.LogArgNames)}
+	if cfg.Chaos.Enabled {
+		if cfg.Chaos.Seed == 0 {
+			cfg.Chaos.Seed = rand.Uint64()
+		}
+		// Same seed reproduces the same failures, as long as workflow IDs are the same too.
+		slog.Warn("Injecting faults into activities", "Seed", cfg.Chaos.Seed)
+		interceptors = append(interceptors, cfg.ChaosInterceptor())
+	}
+
+	// Single process can host any subset of queues. Each gets its own worker, so it can be tuned separately.
+	for _, queue := range e.cfg.Worker.Queues {
+		options := e.cfg.WorkerOptions(queue)
+		options.Interceptors = append(options.Interceptors, interceptors...)
+		w := worker.New(e.client, queue, options)
+		registerWorkflows(w, queue, activities)
+		if err := w.Start(); err != nil {
+			return fmt.Errorf("unable to start worker for %q task queue: %w", queue, err)
+		}
+		defer w.Stop()
+	}
+
+	if err := resyncEmployeesKeptInMemory(ctx, e); err != nil {
+		return fmt.Errorf("unable to start full resync from Bob: %w", err)
+	}
+
+	if cfg.Metrics.Listen == "" {
+		<-worker.InterruptCh()
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", registry)
+	return serve(ctx, cfg.Metrics.Listen, mux)
+}
+
+// resyncEmployeesKeptInMemory starts a full resync, if this worker syncs employees and keeps them only in memory.
+// Incremental sync would carry on from its watermark, and never bring back employees that didn't change since.
+func resyncEmployeesKeptInMemory(ctx context.Context, e env) error {
+	if e.cfg.Employees.Dir != "" || !slices.Contains(e.cfg.Worker.Queues, workflows.TaskQueueBobSync) {
+		return nil
+	}
+	input := workflows.SyncDataFromBobInput{FullResync: true, Rebuild: true}
+	run, err := e.client.ExecuteWorkflow(ctx, workflows.FullResyncFromBobOptions(), workflows.SyncDataFromBob, input)
+	if err != nil {
+		return err
+	}
+	slog.Warn("Employees are kept in memory, syncing all of them again", "WorkflowID", run.GetID(), "RunID", run.GetRunID())
+	return nil
+}
+
+func registerWorkflows(w worker.Worker, queue string, activities *workflows.Activities) {
+	switch queue {
+	// Very simple example. Probably not the best case though. But I wanted to show cron scheduling.
+	case workflows.TaskQueueBobSync:
+		w.RegisterWorkflow(workflows.SyncDataFromBob)
+		w.RegisterWorkflow(workflows.SyncCompanyFromBob)
+		w.RegisterWorkflow(workflows.EmployeeChanges)
+		// Registers all methods. Those not meant for this queue are simply never scheduled on it.
+		w.RegisterActivity(activities)
+
+	// Processing payroll is a lot more complex workflow. It even spins its own process payments workflow.
+	case workflows.TaskQueuePayroll:
+		w.RegisterWorkflow(workflows.ProcessPayroll)
+		w.RegisterWorkflow(workflows.AwaitFPSResponse)
+		w.RegisterActivity(workflows.CanPayrollBeProcessed)
+		w.RegisterActivity(activities)
+		w.RegisterActivity(workflows.CheckFPSReport)
+		w.RegisterActivity(workflows.MarkFPSAsSuccessful)
+
+	case workflows.TaskQueuePayments:
+		w.RegisterWorkflow(workflows.ProcessPayments)
+		w.RegisterActivity(workflows.FindPayments)
+		w.RegisterActivity(workflows.SchedulePayment)
+		w.RegisterActivity(workflows.IsPaymentPaid)
+		w.RegisterActivity(workflows.ReconcileInAccountingIntegration)
+
+	// Pushing pay details in a *similar* way we did doc-sender. A lot less plumbing!
+	case workflows.TaskQueueDocuments:
+		w.RegisterWorkflow(workflows.PushPayDetails)
+		w.RegisterWorkflow(workflows.PushCompanyPayDetails)
+		w.RegisterActivity(activities)
+		w.RegisterActivity(workflows.SendDocuments)
+	}
+}
+
+// desiredSchedules are reconciled with the server whenever worker starts. Schedules removed from here are deleted.
+func desiredSchedules() []schedules.Schedule {
+	return []schedules.Schedule{
+		{
+			ID:        workflows.SyncDataFromBobScheduleID,
+			Cron:      []string{"* * * * *"},
+			Overlap:   enums.SCHEDULE_OVERLAP_POLICY_SKIP,
+			Workflow:  workflows.SyncDataFromBob,
+			TaskQueue: workflows.TaskQueueBobSync,
+		},
+		// More schedules...
+	}
+}
+
+func reconcileSchedules(ctx context.Context, c client.ScheduleClient) (schedules.Plan, error) {
+	reconciler, err := schedules.NewReconciler(c, desiredSchedules())
+	if err != nil {
+		return nil, err
+	}
+	return reconciler.Reconcile(ctx)
+}
+
+// ensureSearchAttributes adds search attributes workflows set, unless namespace already has them. Upserting unknown
+// one fails the workflow task.
+func ensureSearchAttributes(ctx context.Context, c client.Client, namespace string) error {
+	resp, err := c.OperatorService().ListSearchAttributes(ctx, &operatorservice.ListSearchAttributesRequest{Namespace: namespace})
+	if err != nil {
+		return err
+	}
+	missing := map[string]enums.IndexedValueType{}
+	for _, key := range workflows.SearchAttributes {
+		if _, ok := resp.GetCustomAttributes()[key.GetName()]; !ok {
+			missing[key.GetName()] = key.GetValueType()
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	_, err = c.OperatorService().AddSearchAttributes(ctx, &operatorservice.AddSearchAttributesRequest{
+		Namespace:        namespace,
+		SearchAttributes: missing,
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Search attributes added", "Count", len(missing))
+	return nil
+}
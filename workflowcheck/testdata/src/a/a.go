@@ -0,0 +1,82 @@
+package a
+
+import (
+	"b"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+func Workflow(ctx workflow.Context) error { // want Workflow:`nonDeterministic\(time.Now`
+	_ = time.Now()          // want `time.Now is not deterministic`
+	time.Sleep(time.Second) // want `time.Sleep is not deterministic`
+	_ = rand.Intn(10)       // want `rand.Intn is not deterministic`
+	fmt.Println("started")  // want `fmt.Println is not deterministic`
+	_ = workflow.Now(ctx).Add(time.Hour)
+	_ = fmt.Sprintf("%d", b.Add(1, 2))
+
+	go func() {}()       // want `native goroutine`
+	ch := make(chan int) // want `native channel`
+	ch <- 1              // want `native channel send`
+	<-ch                 // want `native channel receive`
+	select {}            // want `native select`
+	workflow.Go(ctx, func(ctx workflow.Context) {})
+
+	m := map[string]int{}
+	for k := range m { // want `map iteration order is random`
+		_ = k
+	}
+	keys := []string{}
+	for k := range m { //workflowcheck:ignore collecting keys to sort them
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	_ = b.ReadConfig() // want `ReadConfig is not deterministic: os.ReadFile`
+	logLater()         // want `logLater is not deterministic: calls log, fmt.Println`
+	format()
+	trusted()
+	helper(ctx)
+	return nil
+}
+
+func helper(ctx workflow.Context) { // want helper:`nonDeterministic\(time.Now`
+	_ = time.Now() // want `time.Now is not deterministic`
+}
+
+func logLater() { // want logLater:`nonDeterministic\(calls log`
+	log()
+}
+
+func log() { // want log:`nonDeterministic\(fmt.Println`
+	fmt.Println("done")
+}
+
+func format() string {
+	return fmt.Sprint("done")
+}
+
+// trusted does things workflow shouldn't, but it knows better.
+//
+//workflowcheck:ignore
+func trusted() {
+	fmt.Println("trust me")
+}
+
+// Registered doesn't take workflow.Context, but it is registered as workflow.
+func Registered() { // want Registered:`nonDeterministic\(time.Now`
+	_ = time.Now() // want `time.Now is not deterministic`
+}
+
+// Activity is not a workflow, it can do anything.
+func Activity() { // want Activity:`nonDeterministic\(time.Now`
+	_ = time.Now()
+	fmt.Println("activity")
+}
+
+func register(w workflow.Worker) {
+	w.RegisterWorkflow(Registered)
+}
@@ -0,0 +1,13 @@
+package b
+
+import "os"
+
+// ReadConfig is fine on its own, it's just not fine in workflows.
+func ReadConfig() string {
+	data, _ := os.ReadFile("config.yaml")
+	return string(data)
+}
+
+func Add(a, b int) int {
+	return a + b
+}
@@ -0,0 +1,14 @@
+// Package workflow is a stub of the real one, with just enough for tests.
+package workflow
+
+import "time"
+
+type Context interface{}
+
+func Now(ctx Context) time.Time { return time.Time{} }
+
+func Go(ctx Context, f func(ctx Context)) {}
+
+type Worker struct{}
+
+func (Worker) RegisterWorkflow(w interface{}) {}
@@ -0,0 +1,369 @@
+// Package workflowcheck finds code that would break determinism of Temporal workflows.
+//
+// Workflow code is replayed from history whenever a worker needs to rebuild its state, and every replay has to make
+// the same decisions as the original run. Reading the clock, random numbers, I/O, native goroutines and channels, or
+// iterating over a map all make that impossible.
+//
+// Workflow code is:
+//   - functions registered with RegisterWorkflow in the same package,
+//   - functions taking workflow.Context as the first argument, which covers workflows registered elsewhere, as well as
+//     helpers that can only be called from workflows.
+//
+// Helpers without workflow.Context are not reported on their own, as activities might use them too. Calls to them
+// from workflow code are reported when helper, or anything it calls, is not deterministic. This works across packages.
+//
+// A line can be suppressed with a `//workflowcheck:ignore` comment, on the line itself or the one above. Put it in the
+// doc comment of a function to treat the whole function as deterministic.
+package workflowcheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const ignoreDirective = "workflowcheck:ignore"
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "workflowcheck",
+	Doc:       "reports non-deterministic code in Temporal workflows",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(nonDeterministic)},
+}
+
+// nonDeterministic is exported for functions that are not deterministic, so callers from other packages know.
+type nonDeterministic struct {
+	Reason string
+}
+
+func (*nonDeterministic) AFact() {}
+
+func (f *nonDeterministic) String() string {
+	return "nonDeterministic(" + f.Reason + ")"
+}
+
+// forbidden lists functions by package. Empty list means the whole package.
+var forbidden = map[string]struct {
+	funcs []string
+	hint  string
+}{
+	"time":          {[]string{"Now", "Since", "Until", "Sleep", "After", "AfterFunc", "Tick", "NewTimer", "NewTicker"}, "use workflow.Now, workflow.Sleep or workflow.NewTimer"},
+	"math/rand":     {nil, "use workflow.SideEffect"},
+	"math/rand/v2":  {nil, "use workflow.SideEffect"},
+	"crypto/rand":   {nil, "use workflow.SideEffect"},
+	"fmt":           {[]string{"Print", "Printf", "Println", "Scan", "Scanf", "Scanln"}, "use workflow.GetLogger"},
+	"log":           {nil, "use workflow.GetLogger"},
+	"log/slog":      {[]string{"Debug", "DebugContext", "Info", "InfoContext", "Warn", "WarnContext", "Error", "ErrorContext", "Log", "LogAttrs"}, "use workflow.GetLogger"},
+	"os":            {nil, "move it to an activity"},
+	"os/exec":       {nil, "move it to an activity"},
+	"io/ioutil":     {nil, "move it to an activity"},
+	"net":           {nil, "move it to an activity"},
+	"net/http":      {nil, "move it to an activity"},
+	"database/sql":  {nil, "move it to an activity"},
+	"sync":          {nil, "use workflow.Mutex, workflow.Go or workflow.Channel"},
+	"runtime":       {nil, "it's different on every worker"},
+	"crypto/x509":   {[]string{"SystemCertPool"}, "move it to an activity"},
+	"path/filepath": {[]string{"Abs", "EvalSymlinks", "Glob", "Walk", "WalkDir"}, "move it to an activity"},
+}
+
+// Workflow SDK is deterministic by design, and std library is covered by forbidden.
+var trustedPrefixes = []string{"go.temporal.io/"}
+
+type issue struct {
+	pos token.Pos
+	msg string
+}
+
+type call struct {
+	pos    token.Pos
+	callee *types.Func
+}
+
+type function struct {
+	decl   *ast.FuncDecl
+	issues []issue
+	calls  []call
+}
+
+type checker struct {
+	pass      *analysis.Pass
+	functions map[*types.Func]*function
+	// reasons memoizes nonDeterministic for functions of this package. Empty reason means deterministic.
+	reasons map[*types.Func]string
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if isTrusted(pass) {
+		return nil, nil
+	}
+	c := &checker{
+		pass:      pass,
+		functions: map[*types.Func]*function{},
+		reasons:   map[*types.Func]string{},
+	}
+
+	roots := map[*types.Func]bool{}
+	for _, file := range pass.Files {
+		ignored := ignoredLines(pass.Fset, file)
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || decl.Body == nil {
+				continue
+			}
+			fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+			if !ok || hasDirective(decl.Doc) {
+				continue
+			}
+			c.functions[fn] = c.inspect(decl, ignored)
+			if takesWorkflowContext(fn) {
+				roots[fn] = true
+			}
+		}
+		for fn := range registeredWorkflows(pass, file) {
+			roots[fn] = true
+		}
+	}
+
+	for fn := range c.functions {
+		if reason := c.reason(fn); reason != "" {
+			pass.ExportObjectFact(fn, &nonDeterministic{Reason: reason})
+		}
+	}
+
+	for fn := range roots {
+		f, ok := c.functions[fn]
+		if !ok {
+			continue
+		}
+		for _, issue := range f.issues {
+			pass.Reportf(issue.pos, "%s", issue.msg)
+		}
+		for _, call := range f.calls {
+			// Other workflow functions are reported on their own.
+			if roots[call.callee] || takesWorkflowContext(call.callee) {
+				continue
+			}
+			if reason := c.reason(call.callee); reason != "" {
+				pass.Reportf(call.pos, "%s is not deterministic: %s", call.callee.Name(), reason)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// inspect finds everything non-deterministic done directly in a function, and calls to other functions.
+func (c *checker) inspect(decl *ast.FuncDecl, ignored map[int]bool) *function {
+	f := &function{decl: decl}
+	info := c.pass.TypesInfo
+	report := func(node ast.Node, format string, args ...interface{}) {
+		line := c.pass.Fset.Position(node.Pos()).Line
+		if ignored[line] || ignored[line-1] {
+			return
+		}
+		f.issues = append(f.issues, issue{pos: node.Pos(), msg: fmt.Sprintf(format, args...)})
+	}
+
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.GoStmt:
+			report(node, "native goroutine, use workflow.Go")
+		case *ast.SelectStmt:
+			report(node, "native select, use workflow.NewSelector")
+		case *ast.SendStmt:
+			report(node, "native channel send, use workflow.Channel")
+		case *ast.UnaryExpr:
+			if node.Op == token.ARROW {
+				report(node, "native channel receive, use workflow.Channel")
+			}
+		case *ast.RangeStmt:
+			switch info.TypeOf(node.X).Underlying().(type) {
+			case *types.Map:
+				report(node, "map iteration order is random, sort the keys first")
+			case *types.Chan:
+				report(node, "native channel receive, use workflow.Channel")
+			}
+		case *ast.CallExpr:
+			if isMakeChan(info, node) {
+				report(node, "native channel, use workflow.NewChannel")
+				return true
+			}
+			fn, ok := typeutil.Callee(info, node).(*types.Func)
+			if !ok || fn.Pkg() == nil {
+				return true
+			}
+			fn = fn.Origin()
+			if hint, ok := isForbidden(fn); ok {
+				report(node, "%s.%s is not deterministic, %s", fn.Pkg().Name(), fn.Name(), hint)
+				return true
+			}
+			line := c.pass.Fset.Position(node.Pos()).Line
+			if !ignored[line] && !ignored[line-1] {
+				f.calls = append(f.calls, call{pos: node.Pos(), callee: fn})
+			}
+		}
+		return true
+	})
+	return f
+}
+
+// reason tells why function is not deterministic, or returns empty string if it is.
+func (c *checker) reason(fn *types.Func) string {
+	if fn.Pkg() != c.pass.Pkg {
+		var fact nonDeterministic
+		if c.pass.ImportObjectFact(fn, &fact) {
+			return fact.Reason
+		}
+		return ""
+	}
+	if reason, ok := c.reasons[fn]; ok {
+		return reason
+	}
+	f, ok := c.functions[fn]
+	if !ok {
+		return ""
+	}
+	// Recursion is deterministic, unless something else in the cycle is not.
+	c.reasons[fn] = ""
+
+	var reason string
+	if len(f.issues) > 0 {
+		reason = fmt.Sprintf("%s (%s)", f.issues[0].msg, c.position(f.issues[0].pos))
+	} else {
+		for _, call := range f.calls {
+			if calleeReason := c.reason(call.callee); calleeReason != "" {
+				reason = fmt.Sprintf("calls %s, %s", call.callee.Name(), calleeReason)
+				break
+			}
+		}
+	}
+	c.reasons[fn] = reason
+	return reason
+}
+
+func (c *checker) position(pos token.Pos) string {
+	position := c.pass.Fset.Position(pos)
+	return fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line)
+}
+
+// registeredWorkflows finds functions of this package passed to RegisterWorkflow, be it worker, replayer or test
+// environment.
+func registeredWorkflows(pass *analysis.Pass, file *ast.File) map[*types.Func]bool {
+	registered := map[*types.Func]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "RegisterWorkflow" && sel.Sel.Name != "RegisterWorkflowWithOptions") {
+			return true
+		}
+		var ident *ast.Ident
+		switch arg := call.Args[0].(type) {
+		case *ast.Ident:
+			ident = arg
+		case *ast.SelectorExpr:
+			ident = arg.Sel
+		}
+		if ident == nil {
+			return true
+		}
+		if fn, ok := pass.TypesInfo.Uses[ident].(*types.Func); ok && fn.Pkg() == pass.Pkg {
+			registered[fn] = true
+		}
+		return true
+	})
+	return registered
+}
+
+// takesWorkflowContext is true for workflows and their helpers. workflow.Context is an alias of an internal type.
+func takesWorkflowContext(fn *types.Func) bool {
+	params := fn.Type().(*types.Signature).Params()
+	if params.Len() == 0 {
+		return false
+	}
+	named, ok := types.Unalias(params.At(0).Type()).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Name() != "Context" {
+		return false
+	}
+	path := named.Obj().Pkg().Path()
+	return path == "go.temporal.io/sdk/workflow" || path == "go.temporal.io/sdk/internal"
+}
+
+func isForbidden(fn *types.Func) (string, bool) {
+	rule, ok := forbidden[fn.Pkg().Path()]
+	if !ok {
+		return "", false
+	}
+	if len(rule.funcs) == 0 {
+		return rule.hint, true
+	}
+	// Methods, like time.Time.Add, are fine.
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return "", false
+	}
+	for _, name := range rule.funcs {
+		if fn.Name() == name {
+			return rule.hint, true
+		}
+	}
+	return "", false
+}
+
+func isMakeChan(info *types.Info, call *ast.CallExpr) bool {
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok || ident.Name != "make" || len(call.Args) == 0 {
+		return false
+	}
+	if _, ok := info.Uses[ident].(*types.Builtin); !ok {
+		return false
+	}
+	_, ok = info.TypeOf(call.Args[0]).Underlying().(*types.Chan)
+	return ok
+}
+
+// isTrusted skips std library and workflow SDK. They would be flagged all over the place for things like sync.Pool,
+// while being perfectly fine to call. Known offenders from std library are listed in forbidden.
+func isTrusted(pass *analysis.Pass) bool {
+	for _, prefix := range trustedPrefixes {
+		if strings.HasPrefix(pass.Pkg.Path(), prefix) {
+			return true
+		}
+	}
+	if len(pass.Files) == 0 {
+		return true
+	}
+	file := pass.Fset.File(pass.Files[0].Pos()).Name()
+	return strings.HasPrefix(file, filepath.Join(build.Default.GOROOT, "src")+string(filepath.Separator))
+}
+
+func ignoredLines(fset *token.FileSet, file *ast.File) map[int]bool {
+	lines := map[int]bool{}
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if strings.Contains(comment.Text, ignoreDirective) {
+				lines[fset.Position(comment.Pos()).Line] = true
+			}
+		}
+	}
+	return lines
+}
+
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.Contains(comment.Text, ignoreDirective) {
+			return true
+		}
+	}
+	return false
+}
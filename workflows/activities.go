@@ -0,0 +1,86 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/delivery"
+	"temporal-poc/employees"
+	"temporal-poc/hmrc"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Activities talk to the outside world. Everything they need is passed in, so tests can swap any of it for fakes.
+// Register them with worker.RegisterActivity(&Activities{...}), and refer to them from workflows through a nil
+// *Activities, e.g. workflow.ExecuteActivity(ctx, a.PullData).
+type Activities struct {
+	Bob        BobClient
+	PayDetails PayDetailsRepository
+	Employees  EmployeeRepository
+	Events     EmployeeEventPublisher
+	Deliveries DeliveryRepository
+	Payrolls   PayrollRunRepository
+	HMRC       HMRCGateway
+	// GovTalk identifies us in documents submitted to HMRC.
+	GovTalk hmrc.Options
+	// Clock defaults to time.Now.
+	Clock func() time.Time
+}
+
+// BobClient is implemented by bob.Client, and bob.Fake when Bob is not configured.
+type BobClient interface {
+	ListEmployees(ctx context.Context, req bob.ListEmployeesRequest) (bob.EmployeesPage, error)
+	PushPayDetails(ctx context.Context, details bob.PayDetails) error
+	PushPayDetailsBatch(ctx context.Context, companyID string, batch []bob.PayDetails) ([]bob.PayslipResult, error)
+}
+
+// EmployeeRepository is implemented by employees.MemoryStore and employees.FileStore.
+type EmployeeRepository interface {
+	Changes(ctx context.Context, employees []bob.Employee, syncedAt time.Time) (employees.Changes, error)
+	Save(ctx context.Context, versions []employees.Version) error
+}
+
+// DeliveryRepository is implemented by delivery.MemoryStore and delivery.FileStore.
+type DeliveryRepository interface {
+	Record(ctx context.Context, ref delivery.Ref, transition delivery.Transition) error
+}
+
+// EmployeeEventPublisher lets other workflows react to changes of employees. Events are published before employees
+// are saved, so a failed attempt can publish them twice, but never loses them. Implemented by SignalEmployeeChanges.
+type EmployeeEventPublisher interface {
+	Publish(ctx context.Context, events []employees.Event) error
+}
+
+func (a *Activities) now() time.Time {
+	if a.Clock == nil {
+		return time.Now()
+	}
+	return a.Clock()
+}
+
+// ErrorTypeBobRejected is set on errors Bob won't accept no matter how many times activity retries.
+const ErrorTypeBobRejected = "BobRejected"
+
+// ErrorTypeBobRateLimited is set when Bob asked to wait longer than bob client waits on its own, so activity doesn't
+// time out waiting. Details hold how long Bob asked for. Activity retry policy takes it from there, as SDK v1.26 drops
+// NextRetryDelay of application errors.
+const ErrorTypeBobRateLimited = "BobRateLimited"
+
+// bobError stops retries of requests Bob rejected. Anything else (rate limits, outages, timeouts) is retried.
+func bobError(err error) error {
+	var apiErr *bob.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if !apiErr.Retryable() {
+		return temporal.NewNonRetryableApplicationError(apiErr.Error(), ErrorTypeBobRejected, err)
+	}
+	if apiErr.StatusCode == http.StatusTooManyRequests {
+		return temporal.NewApplicationError(apiErr.Error(), ErrorTypeBobRateLimited, apiErr.RetryAfter)
+	}
+	return err
+}
@@ -0,0 +1,586 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/delivery"
+	"temporal-poc/employees"
+	"temporal-poc/hmrc"
+	"temporal-poc/payroll"
+
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type ActivitiesTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env        *testsuite.TestActivityEnvironment
+	bob        *fakeBob
+	employees  *employees.MemoryStore
+	events     *recordedEvents
+	deliveries *delivery.MemoryStore
+	hmrc       *recordingHMRC
+	activities *Activities
+}
+
+func TestActivities(t *testing.T) {
+	suite.Run(t, new(ActivitiesTestSuite))
+}
+
+var syncTime = time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+
+func (s *ActivitiesTestSuite) SetupTest() {
+	s.env = s.NewTestActivityEnvironment()
+	s.bob = &fakeBob{Fake: bob.NewFake()}
+	s.employees = employees.NewMemoryStore()
+	s.events = &recordedEvents{}
+	s.deliveries = delivery.NewMemoryStore()
+	s.hmrc = &recordingHMRC{FakeHMRC: NewFakeHMRC()}
+	s.activities = &Activities{
+		Bob:        s.bob,
+		PayDetails: FakePayDetailsRepository{},
+		Employees:  s.employees,
+		Events:     s.events,
+		Deliveries: s.deliveries,
+		Payrolls:   FakePayrollRuns{},
+		HMRC:       s.hmrc,
+		GovTalk:    hmrc.Options{SenderID: "sender-1", Mode: hmrc.ModeTestInLive},
+		Clock:      func() time.Time { return syncTime },
+	}
+	s.env.RegisterActivity(s.activities)
+}
+
+func (s *ActivitiesTestSuite) Test_PullData_ReadsAllPages() {
+	result, err := s.env.ExecuteActivity(s.activities.PullData)
+	s.Require().NoError(err)
+
+	var data DataFromBob
+	s.Require().NoError(result.Get(&data))
+	s.Equal(s.bob.Employees, data.Employees)
+}
+
+func (s *ActivitiesTestSuite) Test_SyncEmployeesPage_StoresEmployeesChangedSinceWatermark() {
+	s.bob.Employees[1].UpdatedAt = syncTime
+	s.bob.Employees[2].UpdatedAt = syncTime.Add(time.Hour)
+
+	page := s.syncEmployeesPage(SyncEmployeesPageRequest{UpdatedSince: syncTime, Limit: 10})
+
+	s.Equal(SyncEmployeesPageResult{Summary: employees.Summary{Created: 2}, Watermark: syncTime.Add(time.Hour)}, page)
+	_, err := s.employees.AsOf(context.Background(), "employee-1", syncTime)
+	s.ErrorIs(err, employees.ErrNotFound)
+	stored, err := s.employees.AsOf(context.Background(), "employee-3", syncTime.Add(time.Hour))
+	s.Require().NoError(err)
+	s.Equal(s.bob.Employees[2], stored)
+	s.Equal([]employees.Event{
+		{Type: employees.EventNewStarter, EmployeeID: "employee-2", EffectiveAt: syncTime},
+		{Type: employees.EventNewStarter, EmployeeID: "employee-3", EffectiveAt: syncTime.Add(time.Hour)},
+	}, s.events.events)
+}
+
+func (s *ActivitiesTestSuite) Test_SyncEmployeesPage_StoresOnlyEmployeesOfCompany() {
+	page := s.syncEmployeesPage(SyncEmployeesPageRequest{CompanyID: "2", Limit: 10})
+
+	s.Equal(employees.Summary{Created: 1}, page.Summary)
+	_, err := s.employees.AsOf(context.Background(), "employee-1", syncTime)
+	s.ErrorIs(err, employees.ErrNotFound)
+	_, err = s.employees.AsOf(context.Background(), "employee-3", syncTime)
+	s.NoError(err)
+}
+
+func (s *ActivitiesTestSuite) Test_SyncEmployeesPage_PublishesChanges() {
+	s.syncEmployeesPage(SyncEmployeesPageRequest{Limit: 10})
+	s.events.events = nil
+	changedAt := syncTime.Add(time.Hour)
+	s.bob.Employees[0].Salary += 1000_00
+	s.bob.Employees[0].UpdatedAt = changedAt
+	s.bob.Employees[1].TerminationDate = syncTime
+	s.bob.Employees[1].UpdatedAt = changedAt
+
+	page := s.syncEmployeesPage(SyncEmployeesPageRequest{Limit: 10})
+
+	s.Equal(employees.Summary{Updated: 2, Unchanged: 1}, page.Summary)
+	s.Equal([]employees.Event{
+		{Type: employees.EventSalaryChanged, EmployeeID: "employee-1", EffectiveAt: changedAt},
+		{Type: employees.EventLeaver, EmployeeID: "employee-2", EffectiveAt: syncTime},
+	}, s.events.events)
+}
+
+func (s *ActivitiesTestSuite) Test_SyncEmployeesPage_DoesNotPublishNewStarters_WhenRebuilding() {
+	s.bob.Employees[1].TerminationDate = syncTime
+
+	page := s.syncEmployeesPage(SyncEmployeesPageRequest{Limit: 10, Rebuild: true})
+
+	s.Equal(employees.Summary{Created: 3}, page.Summary)
+	s.Equal([]employees.Event{
+		{Type: employees.EventLeaver, EmployeeID: "employee-2", EffectiveAt: syncTime},
+	}, s.events.events)
+}
+
+func (s *ActivitiesTestSuite) Test_SyncEmployeesPage_DoesNotSave_WhenEventsCantBePublished() {
+	s.events.err = errors.New("broker is down")
+
+	_, err := s.env.ExecuteActivity(s.activities.SyncEmployeesPage, SyncEmployeesPageRequest{Limit: 10})
+
+	s.ErrorContains(err, "broker is down")
+	_, err = s.employees.AsOf(context.Background(), "employee-1", syncTime)
+	s.ErrorIs(err, employees.ErrNotFound)
+}
+
+func (s *ActivitiesTestSuite) Test_StoreData_RecordsSyncTime() {
+	data := DataFromBob{Employees: []bob.Employee{{ID: "employee-1"}}}
+
+	_, err := s.env.ExecuteActivity(s.activities.StoreData, data)
+
+	s.Require().NoError(err)
+	// Without UpdatedAt, employee is effective from the sync.
+	_, err = s.employees.AsOf(context.Background(), "employee-1", syncTime.Add(-time.Second))
+	s.ErrorIs(err, employees.ErrNotFound)
+	_, err = s.employees.AsOf(context.Background(), "employee-1", syncTime)
+	s.NoError(err)
+}
+
+func (s *ActivitiesTestSuite) Test_PushPayDetailsToBob_SendsFetchedDetails() {
+	_, err := s.env.ExecuteActivity(s.activities.PushPayDetailsToBob, payDetailsRef)
+
+	s.Require().NoError(err)
+	s.Require().Len(s.bob.pushed, 1)
+	s.Equal("payslip-1", s.bob.pushed[0].PayslipID)
+	s.Equal("Smith", s.bob.pushed[0].LastName)
+}
+
+func (s *ActivitiesTestSuite) Test_PushPayDetailsToBob_DoesNotRetry_WhenBobRejectsThem() {
+	s.bob.err = &bob.Error{StatusCode: http.StatusUnprocessableEntity, Message: "salary is negative"}
+
+	_, err := s.env.ExecuteActivity(s.activities.PushPayDetailsToBob, payDetailsRef)
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeBobRejected, appErr.Type())
+	s.True(appErr.NonRetryable())
+}
+
+func (s *ActivitiesTestSuite) Test_PushPayDetailsToBob_Retries_WhenBobIsRateLimitingForLong() {
+	s.bob.err = &bob.Error{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+
+	_, err := s.env.ExecuteActivity(s.activities.PushPayDetailsToBob, payDetailsRef)
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeBobRateLimited, appErr.Type())
+	s.False(appErr.NonRetryable())
+	var retryAfter time.Duration
+	s.Require().NoError(appErr.Details(&retryAfter))
+	s.Equal(time.Minute, retryAfter)
+}
+
+func (s *ActivitiesTestSuite) Test_PushPayDetailsToBob_Retries_WhenBobIsDown() {
+	s.bob.err = &bob.Error{StatusCode: http.StatusBadGateway}
+
+	_, err := s.env.ExecuteActivity(s.activities.PushPayDetailsToBob, payDetailsRef)
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.False(appErr.NonRetryable())
+	d := s.delivery(payDetailsRef)
+	s.Equal(delivery.StateAttemptFailed, d.State)
+	s.Equal(1, d.Attempts)
+	s.Contains(d.LastError, "502")
+}
+
+func (s *ActivitiesTestSuite) Test_MarkPayDetails_RecordsDelivery() {
+	_, err := s.env.ExecuteActivity(s.activities.MarkPayDetailsAsBeingSent, payDetailsRef)
+	s.Require().NoError(err)
+	_, err = s.env.ExecuteActivity(s.activities.PushPayDetailsToBob, payDetailsRef)
+	s.Require().NoError(err)
+	_, err = s.env.ExecuteActivity(s.activities.MarkPayDetailsAsSent, payDetailsRef)
+	s.Require().NoError(err)
+
+	d := s.delivery(payDetailsRef)
+	s.Equal(delivery.StateSent, d.State)
+	s.Equal(1, d.Attempts)
+	s.Equal(syncTime, d.UpdatedAt)
+	s.Len(d.Transitions, 2)
+}
+
+func (s *ActivitiesTestSuite) Test_MarkPayDetailsAsSuperseded_RecordsDelivery() {
+	_, err := s.env.ExecuteActivity(s.activities.MarkPayDetailsAsBeingSent, payDetailsRef)
+	s.Require().NoError(err)
+	_, err = s.env.ExecuteActivity(s.activities.MarkPayDetailsAsSuperseded, payDetailsRef)
+	s.Require().NoError(err)
+
+	d := s.delivery(payDetailsRef)
+	s.Equal(delivery.StateSuperseded, d.State)
+	s.Equal(0, d.Attempts)
+}
+
+func (s *ActivitiesTestSuite) Test_RecordPayDetailsOutcomes_RecordsDeliveryOfEachPayslip() {
+	_, err := s.env.ExecuteActivity(s.activities.RecordPayDetailsOutcomes, PayDetailsOutcomes{
+		CompanyID: "company-1",
+		Outcomes:  []PayDetailsOutcome{{PayslipID: "payslip-1", Sent: true}, {PayslipID: "payslip-2", Error: "unknown employee"}},
+	})
+	s.Require().NoError(err)
+
+	s.Equal(delivery.StateSent, s.delivery(PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-1"}).State)
+	failed := s.delivery(PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-2"})
+	s.Equal(delivery.StateFailed, failed.State)
+	s.Equal("unknown employee", failed.LastError)
+}
+
+func (s *ActivitiesTestSuite) Test_RecordPayDetailsOutcomes_RecordsFailedAttempt_OfHandedOverPayslip() {
+	_, err := s.env.ExecuteActivity(s.activities.RecordPayDetailsOutcomes, PayDetailsOutcomes{
+		CompanyID:  "company-1",
+		Outcomes:   []PayDetailsOutcome{{PayslipID: "payslip-2", Error: "bob is down"}},
+		HandedOver: true,
+	})
+	s.Require().NoError(err)
+
+	d := s.delivery(PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-2"})
+	s.Equal(delivery.StateAttemptFailed, d.State)
+	s.Equal("bob is down", d.LastError)
+}
+
+func (s *ActivitiesTestSuite) delivery(ref PayDetailsRef) delivery.Delivery {
+	d, err := s.deliveries.Get(context.Background(), delivery.Ref(ref))
+	s.Require().NoError(err)
+	return d
+}
+
+func (s *ActivitiesTestSuite) Test_SubmitFPS_SubmitsFPSOfPayrollRun() {
+	result, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+	s.Require().NoError(err)
+
+	var submission FPSSubmission
+	s.Require().NoError(result.Get(&submission))
+	s.Require().Len(s.hmrc.documents, 1)
+	document := string(s.hmrc.documents[0])
+	s.Contains(document, "<Class>HMRC-PAYE-RTI-FPS-TIL</Class>")
+	s.Contains(document, "<PayId>employee-2</PayId>")
+	mark, err := hmrc.Verify(s.hmrc.documents[0])
+	s.Require().NoError(err)
+	s.Len(submission.CorrelationID, 32)
+	s.Equal(fakeHMRCEndpoint, submission.Endpoint)
+	s.Equal(2*time.Second, submission.PollInterval)
+	s.Equal(mark, submission.IRmark)
+	s.Equal([]string{"employee-1", "employee-2"}, submission.PayrollIDs)
+}
+
+func (s *ActivitiesTestSuite) Test_SubmitFPS_DoesNotRetry_WhenPayrollRunIsInvalid() {
+	s.activities.Payrolls = invalidPayrollRuns{}
+
+	_, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeInvalidFPS, appErr.Type())
+	s.True(appErr.NonRetryable())
+	s.Empty(s.hmrc.documents)
+}
+
+func (s *ActivitiesTestSuite) Test_SubmitFPS_DoesNotRetry_WhenGatewayRejectsIt() {
+	s.hmrc.response = fakeHMRCResponse("error", "", "", fakeHMRCError("Gateway", "fatal", "Authentication Failure"), "")
+
+	_, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeGovTalk, appErr.Type())
+	s.True(appErr.NonRetryable())
+	s.ErrorContains(err, "Authentication Failure")
+}
+
+func (s *ActivitiesTestSuite) Test_SubmitFPS_Retries_WhenErrorIsRecoverable() {
+	s.hmrc.response = fakeHMRCResponse("error", "", "", fakeHMRCError("Gateway", "recoverable", "System busy"), "")
+
+	_, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.False(appErr.NonRetryable())
+	s.ErrorContains(err, "System busy")
+}
+
+func (s *ActivitiesTestSuite) Test_SubmitFPS_ReturnsErrors_WhenHMRCRejectsFPSOnSubmission() {
+	s.hmrc.response = fakeHMRCResponse("error", "", "", `<GovTalkErrors>
+      <Error>
+        <RaisedBy>Department</RaisedBy>
+        <Number>7786</Number>
+        <Type>business</Type>
+        <Text>NINO is not valid</Text>
+        <Location>/IRenvelope[1]/FullPaymentSubmission[1]/Employee[1]/EmployeeDetails[1]/NINO[1]</Location>
+      </Error>
+    </GovTalkErrors>`, "")
+
+	result, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+	s.Require().NoError(err)
+
+	var submission FPSSubmission
+	s.Require().NoError(result.Get(&submission))
+	s.Empty(submission.CorrelationID)
+	s.Contains(submission.Details, "NINO is not valid")
+	s.Equal([]FPSError{{Code: "7786", Message: "NINO is not valid", PayrollID: "employee-1", Field: "EmployeeDetails/NINO"}}, submission.Errors)
+}
+
+func (s *ActivitiesTestSuite) Test_SubmitFPS_DoesNotRetry_WhenHMRCDoesNotAcknowledgeIt() {
+	s.hmrc.response = fakeHMRCResponse("response", "", "", "", "")
+
+	_, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeGovTalk, appErr.Type())
+	s.True(appErr.NonRetryable())
+}
+
+func (s *ActivitiesTestSuite) Test_ReportFPS_DoesNotRetry_WhenHMRCRejectsFPSOnSubmission() {
+	s.hmrc.response = fakeHMRCResponse("error", "", "", fakeHMRCError("Department", "business", "NINO is not valid"), "")
+
+	_, err := s.env.ExecuteActivity(s.activities.ReportFPS, "payroll-1")
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeFPSRejected, appErr.Type())
+	s.True(appErr.NonRetryable())
+}
+
+func (s *ActivitiesTestSuite) Test_ReportFPS_ReturnsCorrelationID() {
+	result, err := s.env.ExecuteActivity(s.activities.ReportFPS, "payroll-1")
+	s.Require().NoError(err)
+
+	var reference FPSReportReference
+	s.Require().NoError(result.Get(&reference))
+	s.Len(reference, 32)
+}
+
+// submitFPS submits FPS of payroll-1 to fake HMRC, like SubmitFPS does.
+func (s *ActivitiesTestSuite) submitFPS() FPSSubmission {
+	result, err := s.env.ExecuteActivity(s.activities.SubmitFPS, "payroll-1")
+	s.Require().NoError(err)
+	var submission FPSSubmission
+	s.Require().NoError(result.Get(&submission))
+	return submission
+}
+
+func (s *ActivitiesTestSuite) Test_PollFPS_IsPending_UntilHMRCHasResponse() {
+	s.hmrc.ProcessingTime = time.Hour
+	s.hmrc.PollInterval = 30 * time.Second
+	submission := s.submitFPS()
+	submission.Endpoint = "https://hmrc.example/poll"
+
+	result, err := s.env.ExecuteActivity(s.activities.PollFPS, submission)
+	s.Require().NoError(err)
+
+	var status FPSReportStatus
+	s.Require().NoError(result.Get(&status))
+	s.Equal(FPSReportStatus{StillPending: true, Endpoint: fakeHMRCEndpoint, PollInterval: 30 * time.Second}, status)
+	s.Equal([]string{"https://hmrc.example/poll"}, s.hmrc.endpoints)
+	s.Contains(string(s.hmrc.documents[1]), "<CorrelationID>"+submission.CorrelationID+"</CorrelationID>")
+}
+
+func (s *ActivitiesTestSuite) Test_PollFPS_GetsResponse_AfterFakeHMRCWithDirRestarted() {
+	dir := s.T().TempDir()
+	s.hmrc.Dir = dir
+	submission := s.submitFPS()
+
+	restarted := NewFakeHMRC()
+	restarted.Dir = dir
+	restarted.ProcessingTime = 0
+	s.activities.HMRC = restarted
+	result, err := s.env.ExecuteActivity(s.activities.PollFPS, submission)
+	s.Require().NoError(err)
+
+	var status FPSReportStatus
+	s.Require().NoError(result.Get(&status))
+	s.True(status.WasSuccessFull)
+}
+
+func (s *ActivitiesTestSuite) Test_PollFPS_ChecksReceipt() {
+	s.hmrc.ProcessingTime = 0
+	submission := s.submitFPS()
+
+	result, err := s.env.ExecuteActivity(s.activities.PollFPS, submission)
+	s.Require().NoError(err)
+
+	var status FPSReportStatus
+	s.Require().NoError(result.Get(&status))
+	s.Equal(FPSReportStatus{WasSuccessFull: true}, status)
+}
+
+func (s *ActivitiesTestSuite) Test_PollFPS_DoesNotRetry_WhenReceiptIsOfOtherIRmark() {
+	s.hmrc.ProcessingTime = 0
+	submission := s.submitFPS()
+	submission.IRmark = hmrc.IRmark{Digest: []byte("01234567890123456789")}
+
+	_, err := s.env.ExecuteActivity(s.activities.PollFPS, submission)
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.Equal(ErrorTypeIRmarkMismatch, appErr.Type())
+	s.True(appErr.NonRetryable())
+}
+
+func (s *ActivitiesTestSuite) Test_PollFPS_ReturnsErrorsHMRCFoundInFPS() {
+	submission := s.submitFPS()
+	s.hmrc.response = fakeHMRCResponse("error", submission.CorrelationID, "", `<GovTalkErrors>
+      <Error>
+        <RaisedBy>Department</RaisedBy>
+        <Number>7786</Number>
+        <Type>business</Type>
+        <Text>NINO is not valid</Text>
+        <Location>/IRenvelope[1]/FullPaymentSubmission[1]/Employee[2]/EmployeeDetails[1]/NINO[1]</Location>
+      </Error>
+      <Error>
+        <RaisedBy>Department</RaisedBy>
+        <Number>7801</Number>
+        <Type>business</Type>
+        <Text>Related tax year is wrong</Text>
+        <Location>/IRenvelope[1]/FullPaymentSubmission[1]/RelatedTaxYear[1]</Location>
+      </Error>
+    </GovTalkErrors>`, "")
+
+	result, err := s.env.ExecuteActivity(s.activities.PollFPS, submission)
+	s.Require().NoError(err)
+
+	var status FPSReportStatus
+	s.Require().NoError(result.Get(&status))
+	s.False(status.WasSuccessFull)
+	s.False(status.StillPending)
+	s.Contains(status.Details, "NINO is not valid")
+	s.Equal([]FPSError{
+		{Code: "7786", Message: "NINO is not valid", PayrollID: "employee-2", Field: "EmployeeDetails/NINO"},
+		{Code: "7801", Message: "Related tax year is wrong", Field: "/IRenvelope[1]/FullPaymentSubmission[1]/RelatedTaxYear[1]"},
+	}, status.Errors)
+}
+
+// recordingHMRC is FakeHMRC that records documents and endpoints. It responds with response instead, if set.
+type recordingHMRC struct {
+	*FakeHMRC
+	documents [][]byte
+	endpoints []string
+	response  []byte
+}
+
+func (h *recordingHMRC) Submit(ctx context.Context, document []byte) ([]byte, error) {
+	h.documents = append(h.documents, document)
+	if h.response != nil {
+		return h.response, nil
+	}
+	return h.FakeHMRC.Submit(ctx, document)
+}
+
+func (h *recordingHMRC) Poll(ctx context.Context, endpoint string, document []byte) ([]byte, error) {
+	h.documents = append(h.documents, document)
+	h.endpoints = append(h.endpoints, endpoint)
+	if h.response != nil {
+		return h.response, nil
+	}
+	return h.FakeHMRC.Poll(ctx, endpoint, document)
+}
+
+// invalidPayrollRuns have a payslip without tax code.
+type invalidPayrollRuns struct{}
+
+func (invalidPayrollRuns) Get(ctx context.Context, payrollID string) (payroll.Run, error) {
+	run, err := FakePayrollRuns{}.Get(ctx, payrollID)
+	run.Payslips[0].TaxCode = ""
+	return run, err
+}
+
+func (s *ActivitiesTestSuite) syncEmployeesPage(req SyncEmployeesPageRequest) SyncEmployeesPageResult {
+	result, err := s.env.ExecuteActivity(s.activities.SyncEmployeesPage, req)
+	s.Require().NoError(err)
+	var page SyncEmployeesPageResult
+	s.Require().NoError(result.Get(&page))
+	return page
+}
+
+type recordedEvents struct {
+	events []employees.Event
+	err    error
+}
+
+func (r *recordedEvents) Publish(_ context.Context, events []employees.Event) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (s *ActivitiesTestSuite) Test_PushPayDetailsBatchToBob_ReportsOutcomeOfEachPayslip() {
+	s.activities.PayDetails = missingPayslips{"payslip-2"}
+	s.bob.rejected = map[string]string{"payslip-3": "unknown employee"}
+
+	result, err := s.env.ExecuteActivity(s.activities.PushPayDetailsBatchToBob, PayDetailsBatch{
+		CompanyID:  "company-1",
+		PayslipIDs: []string{"payslip-1", "payslip-2", "payslip-3"},
+	})
+	s.Require().NoError(err)
+
+	var outcomes []PayDetailsOutcome
+	s.Require().NoError(result.Get(&outcomes))
+	s.Equal([]PayDetailsOutcome{
+		{PayslipID: "payslip-1", Sent: true},
+		{PayslipID: "payslip-2", Error: ErrPayDetailsNotFound.Error()},
+		{PayslipID: "payslip-3", Error: "unknown employee"},
+	}, outcomes)
+	s.Len(s.bob.pushed, 2)
+	d, err := s.deliveries.Get(context.Background(), delivery.Ref{CompanyID: "company-1", PayslipID: "payslip-3"})
+	s.Require().NoError(err)
+	s.Equal(delivery.StateSending, d.State)
+	_, err = s.deliveries.Get(context.Background(), delivery.Ref{CompanyID: "company-1", PayslipID: "payslip-2"})
+	s.ErrorIs(err, delivery.ErrNotFound)
+}
+
+func (s *ActivitiesTestSuite) Test_PushPayDetailsBatchToBob_Retries_WhenBobIsDown() {
+	s.bob.err = &bob.Error{StatusCode: http.StatusServiceUnavailable}
+
+	_, err := s.env.ExecuteActivity(s.activities.PushPayDetailsBatchToBob, PayDetailsBatch{CompanyID: "company-1", PayslipIDs: []string{"payslip-1"}})
+
+	var appErr *temporal.ApplicationError
+	s.Require().True(errors.As(err, &appErr))
+	s.False(appErr.NonRetryable())
+}
+
+// missingPayslips doesn't find listed payslips.
+type missingPayslips []string
+
+func (m missingPayslips) Get(ctx context.Context, ref PayDetailsRef) (PayDetails, error) {
+	if slices.Contains(m, ref.PayslipID) {
+		return PayDetails{}, ErrPayDetailsNotFound
+	}
+	return FakePayDetailsRepository{}.Get(ctx, ref)
+}
+
+// fakeBob fails pushes with err, rejects payslips in batches, and records what was pushed.
+type fakeBob struct {
+	*bob.Fake
+	err      error
+	rejected map[string]string
+	pushed   []bob.PayDetails
+}
+
+func (f *fakeBob) PushPayDetailsBatch(_ context.Context, _ string, batch []bob.PayDetails) ([]bob.PayslipResult, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.pushed = append(f.pushed, batch...)
+	results := make([]bob.PayslipResult, len(batch))
+	for i, details := range batch {
+		results[i] = bob.PayslipResult{PayslipID: details.PayslipID, Error: f.rejected[details.PayslipID]}
+	}
+	return results, nil
+}
+
+func (f *fakeBob) PushPayDetails(_ context.Context, details bob.PayDetails) error {
+	f.pushed = append(f.pushed, details)
+	return f.err
+}
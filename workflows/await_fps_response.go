@@ -0,0 +1,126 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"temporal-poc/hmrc"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// fpsResponseDeadline is how long HMRC has to respond. It usually takes minutes, a day means something is stuck
+	// and someone has to look.
+	fpsResponseDeadline = 24 * time.Hour
+	// defaultPollInterval is used when HMRC doesn't say how often to poll.
+	defaultPollInterval = 10 * time.Second
+)
+
+// ErrorTypeFPSResponseTimeout is set when HMRC didn't respond to FPS before deadline.
+const ErrorTypeFPSResponseTimeout = "FPSResponseTimeout"
+
+// ErrorTypeIRmarkMismatch is set when HMRC's receipt doesn't acknowledge the IRmark we submitted.
+const ErrorTypeIRmarkMismatch = "IRmarkMismatch"
+
+type AwaitFPSResponseInput struct {
+	PayrollID  string
+	Submission FPSSubmission
+	// Deadline is set by the first run, and carried over when continuing as new.
+	Deadline time.Time
+}
+
+// AwaitFPSResponse polls HMRC the way GovTalk wants: waits for poll interval HMRC asked for, polls the endpoint it
+// gave, and repeats until response is there. Waiting is a timer, so nothing runs in between.
+func AwaitFPSResponse(ctx workflow.Context, input AwaitFPSResponseInput) (FPSReportStatus, error) {
+	if input.Deadline.IsZero() {
+		input.Deadline = workflow.Now(ctx).Add(fpsResponseDeadline)
+	}
+	var a *Activities
+	for {
+		interval := input.Submission.PollInterval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+		if workflow.Now(ctx).Add(interval).After(input.Deadline) {
+			return FPSReportStatus{}, fpsResponseTimeout(input, nil)
+		}
+		if err := workflow.Sleep(ctx, interval); err != nil {
+			return FPSReportStatus{}, err
+		}
+
+		pollCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			TaskQueue:           TaskQueuePayroll,
+			StartToCloseTimeout: 10 * time.Second,
+			// Retries while HMRC is down count towards deadline too.
+			ScheduleToCloseTimeout: input.Deadline.Sub(workflow.Now(ctx)),
+			RetryPolicy:            &temporal.RetryPolicy{MaximumInterval: time.Minute},
+		})
+		var status FPSReportStatus
+		err := workflow.ExecuteActivity(pollCtx, a.PollFPS, input.Submission).Get(ctx, &status)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.NonRetryable() || temporal.IsCanceledError(err) {
+			return FPSReportStatus{}, err
+		}
+		// Anything else was retried, until there was no time left for another attempt.
+		if err != nil {
+			return FPSReportStatus{}, fpsResponseTimeout(input, err)
+		}
+		if !status.StillPending {
+			return status, nil
+		}
+
+		// Every acknowledgement says where and when to poll next.
+		if status.Endpoint != "" {
+			input.Submission.Endpoint = status.Endpoint
+		}
+		input.Submission.PollInterval = status.PollInterval
+		if workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			return FPSReportStatus{}, workflow.NewContinueAsNewError(ctx, AwaitFPSResponse, input)
+		}
+	}
+}
+
+func fpsResponseTimeout(input AwaitFPSResponseInput, cause error) error {
+	message := fmt.Sprintf("HMRC didn't respond to FPS %s by %s", input.Submission.CorrelationID, input.Deadline.Format(time.RFC3339))
+	return temporal.NewNonRetryableApplicationError(message, ErrorTypeFPSResponseTimeout, cause)
+}
+
+// PollFPS asks HMRC for response to submitted FPS. Acknowledgement means it's still pending.
+func (a *Activities) PollFPS(ctx context.Context, submission FPSSubmission) (FPSReportStatus, error) {
+	document, err := hmrc.PollFPS(a.GovTalk, submission.CorrelationID)
+	if err != nil {
+		return FPSReportStatus{}, err
+	}
+	response, err := a.submitToHMRC(ctx, submission.Endpoint, document)
+	if err != nil {
+		return FPSReportStatus{}, err
+	}
+	switch response.Qualifier {
+	case hmrc.QualifierAcknowledgement:
+		return FPSReportStatus{StillPending: true, Endpoint: response.Endpoint, PollInterval: response.PollInterval}, nil
+	case hmrc.QualifierError:
+		return FPSReportStatus{Details: response.Errors.Error(), Errors: fpsErrors(response.Errors, submission.PayrollIDs)}, nil
+	}
+	if err := hmrc.CheckReceipt(response.Document, submission.IRmark); err != nil {
+		return FPSReportStatus{}, temporal.NewNonRetryableApplicationError(err.Error(), ErrorTypeIRmarkMismatch, err)
+	}
+	return FPSReportStatus{WasSuccessFull: true}, nil
+}
+
+// fpsErrors names employees errors are about, by their payroll ID.
+func fpsErrors(errs hmrc.GovTalkErrors, payrollIDs []string) []FPSError {
+	fpsErrors := make([]FPSError, 0, len(errs))
+	for _, err := range errs {
+		fpsError := FPSError{Code: err.Number, Message: err.Text, Field: err.Location}
+		if employee, field := err.Employee(); employee > 0 && employee <= len(payrollIDs) {
+			fpsError.PayrollID = payrollIDs[employee-1]
+			fpsError.Field = field
+		}
+		fpsErrors = append(fpsErrors, fpsError)
+	}
+	return fpsErrors
+}
@@ -0,0 +1,137 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type AwaitFPSResponseTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+	// polls has time and endpoint of every PollFPS attempt.
+	polls []poll
+}
+
+type poll struct {
+	at       time.Time
+	endpoint string
+}
+
+func TestAwaitFPSResponse(t *testing.T) {
+	suite.Run(t, new(AwaitFPSResponseTestSuite))
+}
+
+func (s *AwaitFPSResponseTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterActivity(&Activities{})
+	s.polls = nil
+}
+
+func (s *AwaitFPSResponseTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+// onPoll mocks PollFPS, and records when it was called.
+func (s *AwaitFPSResponseTestSuite) onPoll() *testsuite.MockCallWrapper {
+	return s.env.OnActivity(a.PollFPS, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		s.polls = append(s.polls, poll{at: s.env.Now(), endpoint: args.Get(1).(FPSSubmission).Endpoint})
+	})
+}
+
+func (s *AwaitFPSResponseTestSuite) result() FPSReportStatus {
+	s.Require().True(s.env.IsWorkflowCompleted())
+	s.Require().NoError(s.env.GetWorkflowError())
+	var status FPSReportStatus
+	s.Require().NoError(s.env.GetWorkflowResult(&status))
+	return status
+}
+
+func (s *AwaitFPSResponseTestSuite) Test_PollsAsHMRCAsks_UntilItResponds() {
+	s.onPoll().Return(FPSReportStatus{StillPending: true, Endpoint: "https://hmrc.example/poll-2", PollInterval: 30 * time.Second}, nil).Once()
+	s.onPoll().Return(FPSReportStatus{StillPending: true, PollInterval: time.Minute}, nil).Once()
+	s.onPoll().Return(FPSReportStatus{WasSuccessFull: true}, nil).Once()
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(AwaitFPSResponse, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission})
+
+	s.Equal(FPSReportStatus{WasSuccessFull: true}, s.result())
+	s.Equal([]poll{
+		{at: start.Add(10 * time.Second), endpoint: "https://hmrc.example/poll"},
+		{at: start.Add(40 * time.Second), endpoint: "https://hmrc.example/poll-2"},
+		{at: start.Add(100 * time.Second), endpoint: "https://hmrc.example/poll-2"},
+	}, s.polls)
+}
+
+func (s *AwaitFPSResponseTestSuite) Test_UsesDefaultPollInterval_WhenHMRCDoesNotSay() {
+	s.onPoll().Return(FPSReportStatus{StillPending: true}, nil).Once()
+	s.onPoll().Return(FPSReportStatus{WasSuccessFull: true}, nil).Once()
+	submission := fpsSubmission
+	submission.PollInterval = 0
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(AwaitFPSResponse, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: submission})
+
+	s.result()
+	s.Require().Len(s.polls, 2)
+	s.Equal(start.Add(defaultPollInterval), s.polls[0].at)
+	s.Equal(start.Add(2*defaultPollInterval), s.polls[1].at)
+}
+
+func (s *AwaitFPSResponseTestSuite) Test_ReturnsErrorsHMRCFoundInFPS() {
+	s.onPoll().Return(FPSReportStatus{Details: "NINO is not valid"}, nil).Once()
+
+	s.env.ExecuteWorkflow(AwaitFPSResponse, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission})
+
+	s.Equal(FPSReportStatus{Details: "NINO is not valid"}, s.result())
+}
+
+func (s *AwaitFPSResponseTestSuite) Test_Fails_WhenHMRCDoesNotRespondBeforeDeadline() {
+	s.onPoll().Return(FPSReportStatus{StillPending: true, PollInterval: time.Hour}, nil)
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(AwaitFPSResponse, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission})
+
+	s.True(s.env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	s.Require().ErrorAs(s.env.GetWorkflowError(), &appErr)
+	s.Equal(ErrorTypeFPSResponseTimeout, appErr.Type())
+	// It doesn't wait past deadline, when it knows the next poll would be too late.
+	s.False(s.env.Now().After(start.Add(fpsResponseDeadline)))
+	s.Len(s.polls, 24)
+}
+
+func (s *AwaitFPSResponseTestSuite) Test_Fails_WhenHMRCIsDownUntilDeadline() {
+	s.onPoll().Return(FPSReportStatus{}, errors.New("HMRC is down"))
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(AwaitFPSResponse, AwaitFPSResponseInput{
+		PayrollID:  "payroll-1",
+		Submission: fpsSubmission,
+		Deadline:   start.Add(10 * time.Minute),
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	s.Require().ErrorAs(s.env.GetWorkflowError(), &appErr)
+	s.Equal(ErrorTypeFPSResponseTimeout, appErr.Type())
+	s.Greater(len(s.polls), 1)
+}
+
+func (s *AwaitFPSResponseTestSuite) Test_Fails_WhenHMRCRejectsPoll() {
+	s.onPoll().Return(FPSReportStatus{}, temporal.NewNonRetryableApplicationError("unknown correlation ID", ErrorTypeGovTalk, nil)).Once()
+
+	s.env.ExecuteWorkflow(AwaitFPSResponse, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission})
+
+	s.True(s.env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	s.Require().ErrorAs(s.env.GetWorkflowError(), &appErr)
+	s.Equal(ErrorTypeGovTalk, appErr.Type())
+}
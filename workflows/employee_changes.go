@@ -0,0 +1,134 @@
+package workflows
+
+import (
+	"context"
+	"time"
+
+	"temporal-poc/employees"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/workflow"
+	"golang.org/x/sync/errgroup"
+)
+
+// EmployeeEventSignal passes employees.Event to EmployeeChanges of the employee.
+const EmployeeEventSignal = "employee-event"
+
+// EmployeeEventsQuery returns events EmployeeChanges received, oldest first.
+const EmployeeEventsQuery = "employee-events"
+
+type EmployeeChangesInput struct {
+	EmployeeID string
+	// Events received by previous runs, carried over by continue-as-new.
+	Events []employees.Event
+}
+
+const (
+	// employeeChangesIdleTimeout ends the workflow when employee doesn't change for a while. Next change starts it
+	// again, events received before are still in history of the previous run.
+	employeeChangesIdleTimeout = 30 * 24 * time.Hour
+	// employeeEventsKept limits events carried over by continue-as-new.
+	employeeEventsKept = 100
+	// employeeEventsPerRun keeps history short.
+	employeeEventsPerRun = 500
+)
+
+// EmployeeChanges receives events of a single employee, as syncs from Bob find them. This is where payroll reacts to
+// new starters, leavers and salary changes. Until it does, events are kept for the employee-events query.
+// Publishing can be retried, so the same event can come more than once. Duplicates are dropped.
+func EmployeeChanges(ctx workflow.Context, input EmployeeChangesInput) error {
+	logger := workflow.GetLogger(ctx)
+	events := input.Events
+	if err := workflow.SetQueryHandler(ctx, EmployeeEventsQuery, func() ([]employees.Event, error) {
+		return events, nil
+	}); err != nil {
+		return err
+	}
+
+	signals := workflow.GetSignalChannel(ctx, EmployeeEventSignal)
+	for received := 0; received < employeeEventsPerRun; received++ {
+		var event employees.Event
+		if ok, _ := signals.ReceiveWithTimeout(ctx, employeeChangesIdleTimeout, &event); !ok {
+			// Event could have come right after the timer fired. Completing now would lose it.
+			if !signals.ReceiveAsync(&event) {
+				logger.Info("No changes for a while, stopping")
+				return nil
+			}
+		}
+		if seenEvent(events, event) {
+			continue
+		}
+		logger.Info("Employee changed", "Event", event.Type, "EffectiveAt", event.EffectiveAt)
+		events = append(events, event)
+		if workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			break
+		}
+	}
+
+	var event employees.Event
+	for signals.ReceiveAsync(&event) {
+		if !seenEvent(events, event) {
+			events = append(events, event)
+		}
+	}
+	input.Events = events[max(0, len(events)-employeeEventsKept):]
+	return workflow.NewContinueAsNewError(ctx, EmployeeChanges, input)
+}
+
+// seenEvent compares times with Equal, they don't have to be in the same location after passing through history.
+func seenEvent(events []employees.Event, event employees.Event) bool {
+	for _, seen := range events {
+		if seen.Type == event.Type && seen.EmployeeID == event.EmployeeID && seen.EffectiveAt.Equal(event.EffectiveAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// signalWithStarter is the part of client.Client SignalEmployeeChanges needs.
+type signalWithStarter interface {
+	SignalWithStartWorkflow(ctx context.Context, workflowID string, signalName string, signalArg interface{},
+		options client.StartWorkflowOptions, workflow interface{}, workflowArgs ...interface{}) (client.WorkflowRun, error)
+}
+
+// publishConcurrency limits signals sent at once. A page of employees has to be published well within the timeout of
+// the activity syncing it.
+const publishConcurrency = 10
+
+// SignalEmployeeChanges publishes events to EmployeeChanges of each employee, starting it when it's not running.
+type SignalEmployeeChanges struct {
+	Client signalWithStarter
+}
+
+// Publish signals employees in parallel, so order of events is kept only per employee. Activity publishing them
+// heartbeats after each one.
+func (p SignalEmployeeChanges) Publish(ctx context.Context, events []employees.Event) error {
+	byEmployee := map[string][]employees.Event{}
+	var employeeIDs []string
+	for _, event := range events {
+		if _, ok := byEmployee[event.EmployeeID]; !ok {
+			employeeIDs = append(employeeIDs, event.EmployeeID)
+		}
+		byEmployee[event.EmployeeID] = append(byEmployee[event.EmployeeID], event)
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(publishConcurrency)
+	for _, employeeID := range employeeIDs {
+		group.Go(func() error {
+			for _, event := range byEmployee[employeeID] {
+				_, err := p.Client.SignalWithStartWorkflow(ctx, EmployeeChangesWorkflowID(employeeID), EmployeeEventSignal,
+					event, EmployeeChangesOptions(employeeID), EmployeeChanges, EmployeeChangesInput{EmployeeID: employeeID})
+				if err != nil {
+					return err
+				}
+				if activity.IsActivity(ctx) {
+					activity.RecordHeartbeat(ctx)
+				}
+			}
+			return nil
+		})
+	}
+	return group.Wait()
+}
@@ -0,0 +1,164 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"temporal-poc/employees"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type EmployeeChangesTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestEmployeeChanges(t *testing.T) {
+	suite.Run(t, new(EmployeeChangesTestSuite))
+}
+
+func (s *EmployeeChangesTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+var (
+	newStarter = employees.Event{Type: employees.EventNewStarter, EmployeeID: "employee-1", EffectiveAt: changedAt}
+	payRise    = employees.Event{Type: employees.EventSalaryChanged, EmployeeID: "employee-1", EffectiveAt: changedAt.Add(time.Hour)}
+)
+
+func (s *EmployeeChangesTestSuite) signalAfter(delay time.Duration, event employees.Event) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(EmployeeEventSignal, event)
+	}, delay)
+}
+
+func (s *EmployeeChangesTestSuite) events() []employees.Event {
+	value, err := s.env.QueryWorkflow(EmployeeEventsQuery)
+	s.Require().NoError(err)
+	var events []employees.Event
+	s.Require().NoError(value.Get(&events))
+	return events
+}
+
+func (s *EmployeeChangesTestSuite) Test_KeepsEvents_WithoutDuplicates() {
+	s.signalAfter(time.Second, newStarter)
+	s.signalAfter(2*time.Second, newStarter)
+	s.signalAfter(3*time.Second, payRise)
+	var events []employees.Event
+	s.env.RegisterDelayedCallback(func() { events = s.events() }, time.Minute)
+
+	s.env.ExecuteWorkflow(EmployeeChanges, EmployeeChangesInput{EmployeeID: "employee-1"})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal([]employees.Event{newStarter, payRise}, events)
+}
+
+func (s *EmployeeChangesTestSuite) Test_Stops_WhenIdle() {
+	start := s.env.Now()
+
+	s.env.ExecuteWorkflow(EmployeeChanges, EmployeeChangesInput{EmployeeID: "employee-1"})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(start.Add(employeeChangesIdleTimeout), s.env.Now())
+}
+
+func (s *EmployeeChangesTestSuite) Test_IgnoresDuplicates_OfEventsFromPreviousRun() {
+	s.signalAfter(time.Second, newStarter)
+	var events []employees.Event
+	s.env.RegisterDelayedCallback(func() { events = s.events() }, time.Minute)
+
+	s.env.ExecuteWorkflow(EmployeeChanges, EmployeeChangesInput{EmployeeID: "employee-1", Events: []employees.Event{newStarter}})
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal([]employees.Event{newStarter}, events)
+}
+
+func (s *EmployeeChangesTestSuite) Test_ContinuesAsNew_AfterManyEvents() {
+	for i := range employeeEventsPerRun {
+		s.signalAfter(time.Duration(i+1)*time.Second, employees.Event{
+			Type:        employees.EventSalaryChanged,
+			EmployeeID:  "employee-1",
+			EffectiveAt: changedAt.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	s.env.ExecuteWorkflow(EmployeeChanges, EmployeeChangesInput{EmployeeID: "employee-1"})
+
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+}
+
+func TestSignalEmployeeChanges_SignalsWorkflowOfEachEmployee(t *testing.T) {
+	signaler := &recordingSignaler{}
+	leaver := employees.Event{Type: employees.EventLeaver, EmployeeID: "employee-2", EffectiveAt: changedAt}
+
+	err := SignalEmployeeChanges{Client: signaler}.Publish(context.Background(), []employees.Event{newStarter, leaver})
+
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"employee-changes-employee-1", "employee-changes-employee-2"}, signaler.workflowIDs)
+	require.ElementsMatch(t, []interface{}{newStarter, leaver}, signaler.events)
+	require.Equal(t, TaskQueueBobSync, signaler.options[0].TaskQueue)
+}
+
+func TestSignalEmployeeChanges_KeepsOrderOfEventsOfEmployee(t *testing.T) {
+	signaler := &recordingSignaler{}
+	var events []employees.Event
+	for i := range 3 * publishConcurrency {
+		events = append(events, employees.Event{Type: employees.EventSalaryChanged, EmployeeID: "employee-1", EffectiveAt: changedAt.Add(time.Duration(i) * time.Hour)})
+		events = append(events, employees.Event{Type: employees.EventSalaryChanged, EmployeeID: fmt.Sprintf("employee-%d", i+2), EffectiveAt: changedAt})
+	}
+
+	err := SignalEmployeeChanges{Client: signaler}.Publish(context.Background(), events)
+
+	require.NoError(t, err)
+	require.Len(t, signaler.events, len(events))
+	var employee1 []interface{}
+	for i, workflowID := range signaler.workflowIDs {
+		if workflowID == "employee-changes-employee-1" {
+			employee1 = append(employee1, signaler.events[i])
+		}
+	}
+	for i, event := range employee1 {
+		require.Equal(t, events[2*i], event)
+	}
+}
+
+func TestSignalEmployeeChanges_FailsWhenSignalFails(t *testing.T) {
+	signaler := &recordingSignaler{err: errors.New("temporal is down")}
+
+	err := SignalEmployeeChanges{Client: signaler}.Publish(context.Background(), []employees.Event{newStarter, payRise})
+
+	require.ErrorContains(t, err, "temporal is down")
+	// Events of the same employee are signalled one after another. Once one fails, the rest is left for retry.
+	require.Len(t, signaler.workflowIDs, 1)
+}
+
+type recordingSignaler struct {
+	mu          sync.Mutex
+	workflowIDs []string
+	events      []interface{}
+	options     []client.StartWorkflowOptions
+	err         error
+}
+
+func (r *recordingSignaler) SignalWithStartWorkflow(_ context.Context, workflowID string, _ string, signalArg interface{},
+	options client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workflowIDs = append(r.workflowIDs, workflowID)
+	r.events = append(r.events, signalArg)
+	r.options = append(r.options, options)
+	return nil, r.err
+}
@@ -0,0 +1,17 @@
+package workflows
+
+// LogArgNames names the first argument of workflows and activities that take a plain string, so it can be added to
+// their loggers. Struct arguments don't need to be listed, their CompanyID, PayrollID etc. are found automatically.
+var LogArgNames = map[string]string{
+	"ProcessPayroll":                   "PayrollID",
+	"CanPayrollBeProcessed":            "PayrollID",
+	"ReportFPS":                        "PayrollID",
+	"SubmitFPS":                        "PayrollID",
+	"CheckFPSReport":                   "FPSReference",
+	"MarkFPSAsSuccessful":              "PayrollID",
+	"SendDocuments":                    "PayrollID",
+	"ProcessPayments":                  "PayrollID",
+	"FindPayments":                     "PayrollID",
+	"IsPaymentPaid":                    "PaymentID",
+	"ReconcileInAccountingIntegration": "PaymentID",
+}
@@ -0,0 +1,19 @@
+package workflows
+
+// Business metrics. They are emitted through workflow.GetMetricsHandler, so nothing is counted twice during replay.
+const (
+	// MetricFPSSubmissionLatency is the time from submitting FPS until HMRC accepts it.
+	MetricFPSSubmissionLatency = "payroll_fps_submission_latency"
+	MetricPaymentsProcessed    = "payments_processed"
+	// MetricPaymentsProcessedAmount is in pennies, same as Payment.Amount.
+	MetricPaymentsProcessedAmount = "payments_processed_amount"
+	// MetricPayDetailsPushFailures isn't tagged with company, there would be a series for each of them. Delivery
+	// store and logs tell which company it was.
+	MetricPayDetailsPushFailures = "pay_details_push_failures"
+	// MetricPayDetailsPushEscalations counts failed pushes nobody resolved in time.
+	MetricPayDetailsPushEscalations = "pay_details_push_escalations"
+)
+
+// FPSSubmissionLatencyBuckets are in seconds. HMRC responds in minutes to hours, and AwaitFPSResponse gives up after
+// a day.
+var FPSSubmissionLatencyBuckets = []float64{60, 300, 900, 1800, 3600, 2 * 3600, 4 * 3600, 8 * 3600, 24 * 3600}
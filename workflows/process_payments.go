@@ -9,6 +9,7 @@ import (
 
 func ProcessPayments(ctx workflow.Context, payrollID string) error {
 	activityOptions := workflow.ActivityOptions{
+		TaskQueue:           TaskQueuePayments,
 		StartToCloseTimeout: 10 * time.Second,
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
@@ -20,6 +21,7 @@ func ProcessPayments(ctx workflow.Context, payrollID string) error {
 		return err
 	}
 
+	metrics := workflow.GetMetricsHandler(ctx)
 	processedPayments := 0
 	for _, payment := range payments {
 		workflow.GoNamed(ctx, payment.PaymentID, func(ctx workflow.Context) {
@@ -46,6 +48,8 @@ func ProcessPayments(ctx workflow.Context, payrollID string) error {
 			}
 
 			processedPayments++
+			metrics.Counter(MetricPaymentsProcessed).Inc(1)
+			metrics.Counter(MetricPaymentsProcessedAmount).Inc(int64(payment.Amount))
 		})
 	}
 
@@ -71,16 +75,13 @@ func FindPayments(ctx context.Context, payrollID string) (Payments, error) {
 }
 
 func SchedulePayment(ctx context.Context, payment Payment) error {
-	time.Sleep(time.Second)
-	return failXOutOf10Times(3)
+	return nil
 }
 
 func IsPaymentPaid(ctx context.Context, paymentID string) (bool, error) {
-	time.Sleep(time.Second)
-	return true, failXOutOf10Times(3)
+	return true, nil
 }
 
 func ReconcileInAccountingIntegration(ctx context.Context, paymentID string) error {
-	time.Sleep(time.Second)
 	return nil
 }
@@ -0,0 +1,76 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+)
+
+type ProcessPaymentsTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestProcessPayments(t *testing.T) {
+	suite.Run(t, new(ProcessPaymentsTestSuite))
+}
+
+func (s *ProcessPaymentsTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+func (s *ProcessPaymentsTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+var payments = Payments{
+	{PaymentID: "1", Amount: 100},
+	{PaymentID: "2", Amount: 200},
+}
+
+func (s *ProcessPaymentsTestSuite) Test_ProcessesEveryPayment() {
+	s.env.OnActivity(FindPayments, mock.Anything, "payroll-1").Return(payments, nil).Once()
+	for _, payment := range payments {
+		s.env.OnActivity(SchedulePayment, mock.Anything, payment).Return(nil).Once()
+		s.env.OnActivity(IsPaymentPaid, mock.Anything, payment.PaymentID).Return(true, nil).Once()
+		s.env.OnActivity(ReconcileInAccountingIntegration, mock.Anything, payment.PaymentID).Return(nil).Once()
+	}
+
+	s.env.ExecuteWorkflow(ProcessPayments, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *ProcessPaymentsTestSuite) Test_WaitsUntilPaymentIsPaid() {
+	payment := payments[0]
+	s.env.OnActivity(FindPayments, mock.Anything, "payroll-1").Return(Payments{payment}, nil).Once()
+	s.env.OnActivity(SchedulePayment, mock.Anything, payment).Return(nil).Once()
+	s.env.OnActivity(IsPaymentPaid, mock.Anything, payment.PaymentID).Return(false, nil).Times(3)
+	s.env.OnActivity(IsPaymentPaid, mock.Anything, payment.PaymentID).Return(true, nil).Once()
+	s.env.OnActivity(ReconcileInAccountingIntegration, mock.Anything, payment.PaymentID).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(ProcessPayments, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *ProcessPaymentsTestSuite) Test_RetriesSchedulingPayment() {
+	payment := payments[0]
+	s.env.OnActivity(FindPayments, mock.Anything, "payroll-1").Return(Payments{payment}, nil).Once()
+	s.env.OnActivity(SchedulePayment, mock.Anything, payment).Return(errors.New("not enough funds")).Times(3)
+	s.env.OnActivity(SchedulePayment, mock.Anything, payment).Return(nil).Once()
+	s.env.OnActivity(IsPaymentPaid, mock.Anything, payment.PaymentID).Return(true, nil).Once()
+	s.env.OnActivity(ReconcileInAccountingIntegration, mock.Anything, payment.PaymentID).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(ProcessPayments, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
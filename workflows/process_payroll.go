@@ -2,47 +2,231 @@ package workflows
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"temporal-poc/hmrc"
+	"temporal-poc/payroll"
+
+	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+// fpsPollingChange submits FPS and then polls HMRC in AwaitFPSResponse, as often as HMRC asks. Before, payroll itself
+// checked FPS status in a tight loop.
+const fpsPollingChange = "fps-polling"
+
+// fpsCorrectionChange waits for corrected payroll when HMRC rejects FPS, and submits it again. Before, payroll failed.
+const fpsCorrectionChange = "fps-correction"
+
+// FPSCorrectedSignal tells payroll awaiting FPS correction that payroll run was corrected, see FPSCorrection. Find
+// such payrolls with `FPSErrorCodes IS NOT NULL` query.
+const FPSCorrectedSignal = "fps-corrected"
+
+// FPSCorrection is an argument of FPSCorrectedSignal. Corrected data is in payroll, FPS is built from it again.
+type FPSCorrection struct {
+	Note string
+}
+
+// PayrollStatusQuery returns PayrollStatus of a running (or recently closed) ProcessPayroll workflow.
+const PayrollStatusQuery = "payroll-status"
+
+type PayrollStage string
+
+const (
+	PayrollStageChecking     PayrollStage = "checking"
+	PayrollStageSkipped      PayrollStage = "skipped"
+	PayrollStageReportingFPS PayrollStage = "reporting-fps"
+	PayrollStageAwaitingHMRC PayrollStage = "awaiting-hmrc"
+	// PayrollStageAwaitingFPSCorrection waits for FPSCorrectedSignal, after HMRC rejected FPS.
+	PayrollStageAwaitingFPSCorrection PayrollStage = "awaiting-fps-correction"
+	PayrollStageSendingDocuments      PayrollStage = "sending-documents"
+	PayrollStageAwaitingPayments      PayrollStage = "awaiting-payments"
+	PayrollStageCompleted             PayrollStage = "completed"
+)
+
+type PayrollStatus struct {
+	Stage        PayrollStage
+	FPSReference FPSReportReference
+	// FPSErrors HMRC found in FPS, while it's awaiting correction.
+	FPSErrors []FPSError
+}
+
+// FPSError is a business error HMRC found in FPS.
+type FPSError struct {
+	Code    string
+	Message string
+	// PayrollID of employee the error is about. It's empty when error is about the whole submission.
+	PayrollID string
+	// Field is relative to employee, e.g. EmployeeDetails/NINO, or the whole location in FPS otherwise.
+	Field string
+}
+
 func ProcessPayroll(ctx workflow.Context, payrollID string) error {
+	status := PayrollStatus{Stage: PayrollStageChecking}
+	err := workflow.SetQueryHandler(ctx, PayrollStatusQuery, func() (PayrollStatus, error) {
+		return status, nil
+	})
+	if err != nil {
+		return err
+	}
+
 	activityOptions := workflow.ActivityOptions{
+		TaskQueue:           TaskQueuePayroll,
 		StartToCloseTimeout: 10 * time.Second,
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
 
 	var canBeProcessed bool
-	err := workflow.ExecuteActivity(ctx, CanPayrollBeProcessed, payrollID).Get(ctx, &canBeProcessed)
+	err = workflow.ExecuteActivity(ctx, CanPayrollBeProcessed, payrollID).Get(ctx, &canBeProcessed)
 	if err != nil {
 		return err
 	}
 	if !canBeProcessed {
+		workflow.GetLogger(ctx).Info("Payroll can't be processed, skipping")
+		status.Stage = PayrollStageSkipped
 		return nil
 	}
 
-	// While we process FPS, we start processing payments.
-	processPayments := workflow.ExecuteChildWorkflow(ctx, ProcessPayments, payrollID)
+	// While we process FPS, we start processing payments. They have their own workers.
+	paymentsCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		TaskQueue: TaskQueuePayments,
+	})
+	processPayments := workflow.ExecuteChildWorkflow(paymentsCtx, ProcessPayments, payrollID)
+
+	if workflow.GetVersion(ctx, fpsPollingChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		err = legacyReportFPS(ctx, payrollID, &status)
+	} else {
+		err = reportFPS(ctx, payrollID, &status)
+	}
+	if err != nil {
+		return err
+	}
+
+	// We are pretending that after successful FPS submission, we send payslips to employees.
+	// Again, it probably would be its own workflow. For now, it's only routed to document workers.
+	status.Stage = PayrollStageSendingDocuments
+	documentsCtx := workflow.WithTaskQueue(ctx, TaskQueueDocuments)
+	err = workflow.ExecuteActivity(documentsCtx, SendDocuments, payrollID).Get(documentsCtx, nil)
+	if err != nil {
+		return err
+	}
+
+	status.Stage = PayrollStageAwaitingPayments
+	err = workflow.Await(ctx, func() bool {
+		if !processPayments.IsReady() {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	status.Stage = PayrollStageCompleted
+	return nil
+}
+
+// reportFPS submits FPS, and waits in AwaitFPSResponse until HMRC accepts it. Polling has its own history, payroll
+// only gets the outcome. When HMRC rejects FPS, it's submitted again once payroll is corrected.
+func reportFPS(ctx workflow.Context, payrollID string, status *PayrollStatus) error {
+	var a *Activities
+	for {
+		status.Stage = PayrollStageReportingFPS
+		submittedAt := workflow.Now(ctx)
+		var submission FPSSubmission
+		err := workflow.ExecuteActivity(ctx, a.SubmitFPS, payrollID).Get(ctx, &submission)
+		if err != nil {
+			return err
+		}
+		// HMRC can reject FPS right away. Then there is no response to wait for, it's corrected the same way.
+		fpsStatus := FPSReportStatus{Details: submission.Details, Errors: submission.Errors}
+		if len(submission.Errors) == 0 {
+			status.Stage = PayrollStageAwaitingHMRC
+			status.FPSReference = FPSReportReference(submission.CorrelationID)
+
+			awaitCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+				WorkflowID: AwaitFPSResponseWorkflowID(payrollID, submission.CorrelationID),
+			})
+			err = workflow.ExecuteChildWorkflow(awaitCtx, AwaitFPSResponse, AwaitFPSResponseInput{
+				PayrollID:  payrollID,
+				Submission: submission,
+			}).Get(ctx, &fpsStatus)
+			if err != nil {
+				return err
+			}
+		}
+		if fpsStatus.WasSuccessFull {
+			workflow.GetMetricsHandler(ctx).Timer(MetricFPSSubmissionLatency).Record(workflow.Now(ctx).Sub(submittedAt))
+			return workflow.ExecuteActivity(ctx, MarkFPSAsSuccessful, payrollID).Get(ctx, nil)
+		}
+
+		workflow.GetLogger(ctx).Error("FPS was rejected by HMRC", "Details", fpsStatus.Details)
+		if workflow.GetVersion(ctx, fpsCorrectionChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+			return errors.New("FPS has business errors")
+		}
+		if err := awaitFPSCorrection(ctx, status, fpsStatus.Errors); err != nil {
+			return err
+		}
+	}
+}
+
+// awaitFPSCorrection parks payroll until someone corrects what HMRC rejected, and signals it.
+func awaitFPSCorrection(ctx workflow.Context, status *PayrollStatus, fpsErrors []FPSError) error {
+	// Corrections signalled before HMRC rejected FPS were not a reaction to these errors.
+	corrected := workflow.GetSignalChannel(ctx, FPSCorrectedSignal)
+	for corrected.ReceiveAsync(nil) {
+		workflow.GetLogger(ctx).Warn("Ignoring correction signalled before FPS was rejected")
+	}
 
-	// Report FPS.
+	status.Stage = PayrollStageAwaitingFPSCorrection
+	status.FPSErrors = fpsErrors
+	var codes []string
+	for _, fpsError := range fpsErrors {
+		if fpsError.Code != "" && !slices.Contains(codes, fpsError.Code) {
+			codes = append(codes, fpsError.Code)
+		}
+	}
+	err := workflow.UpsertTypedSearchAttributes(ctx, NeedsAttention.ValueSet(true), FPSErrorCodes.ValueSet(codes))
+	if err != nil {
+		return err
+	}
+
+	var correction FPSCorrection
+	corrected.Receive(ctx, &correction)
+	workflow.GetLogger(ctx).Info("Payroll was corrected, submitting FPS again", "Note", correction.Note)
+	status.FPSErrors = nil
+	return workflow.UpsertTypedSearchAttributes(ctx, NeedsAttention.ValueSet(false), FPSErrorCodes.ValueUnset())
+}
+
+// legacyReportFPS is how runs started before fpsPollingChange report FPS. Remove once they are past retention.
+func legacyReportFPS(ctx workflow.Context, payrollID string, status *PayrollStatus) error {
+	status.Stage = PayrollStageReportingFPS
+	submittedAt := workflow.Now(ctx)
 	var fpsReference FPSReportReference
-	err = workflow.ExecuteActivity(ctx, ReportFPS, payrollID).Get(ctx, &fpsReference)
+	var a *Activities
+	err := workflow.ExecuteActivity(ctx, a.ReportFPS, payrollID).Get(ctx, &fpsReference)
 	if err != nil {
 		return err
 	}
+	status.Stage = PayrollStageAwaitingHMRC
+	status.FPSReference = fpsReference
 
-	// HMRC can take its sweet time to validate FPS. We await until it tells us if FPS was successful or not.
-	// In realistic scenario, we would probably start this in a separate workflow, so it doesn't block other actions.
 	checkStatusCtx := workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{
 		MaximumInterval: time.Second,
-		// In reality, it would look more like this:
-		//InitialInterval:    time.Minute,
-		//BackoffCoefficient: 5,
-		//MaximumInterval:    time.Hour * 24,
 	})
 	for {
 		var fpsStatus FPSReportStatus
@@ -54,29 +238,14 @@ func ProcessPayroll(ctx workflow.Context, payrollID string) error {
 			continue
 		}
 		if !fpsStatus.WasSuccessFull {
+			workflow.GetLogger(ctx).Error("FPS was rejected by HMRC", "Details", fpsStatus.Details)
 			return errors.New("FPS has business errors")
 		}
 
-		err = workflow.ExecuteActivity(checkStatusCtx, MarkFPSAsSuccessful, payrollID).Get(checkStatusCtx, nil)
-		if err != nil {
-			return err
-		}
-		break
-	}
+		workflow.GetMetricsHandler(ctx).Timer(MetricFPSSubmissionLatency).Record(workflow.Now(ctx).Sub(submittedAt))
 
-	// We are pretending that after successful FPS submission, we send payslips to employees.
-	// Again, it probably would be its own workflow.
-	err = workflow.ExecuteActivity(checkStatusCtx, SendDocuments, payrollID).Get(checkStatusCtx, nil)
-	if err != nil {
-		return err
+		return workflow.ExecuteActivity(checkStatusCtx, MarkFPSAsSuccessful, payrollID).Get(checkStatusCtx, nil)
 	}
-
-	return workflow.Await(ctx, func() bool {
-		if !processPayments.IsReady() {
-			return false
-		}
-		return true
-	})
 }
 
 func CanPayrollBeProcessed(_ context.Context, payrollID string) (bool, error) {
@@ -85,42 +254,403 @@ func CanPayrollBeProcessed(_ context.Context, payrollID string) (bool, error) {
 
 type FPSReportReference string
 
-func ReportFPS(_ context.Context, payrollID string) (FPSReportReference, error) {
-	time.Sleep(time.Second * 9)
+// ErrorTypeInvalidFPS is set when payroll run doesn't make a valid FPS. It has to be fixed before it's reported again.
+const ErrorTypeInvalidFPS = "InvalidFPS"
+
+// ErrorTypeGovTalk is set when HMRC rejected a document for a reason other than its content, like bad credentials.
+const ErrorTypeGovTalk = "GovTalkError"
 
-	if err := failXOutOf10Times(5); err != nil {
+// ErrorTypeFPSRejected is set by ReportFPS when HMRC found errors in FPS on submission. Runs that still use it can't
+// wait for a correction.
+const ErrorTypeFPSRejected = "FPSRejected"
+
+// PayrollRunRepository is implemented by FakePayrollRuns, until there is a real payroll to fetch from.
+type PayrollRunRepository interface {
+	Get(ctx context.Context, payrollID string) (payroll.Run, error)
+}
+
+// HMRCGateway posts GovTalk documents, and returns what HMRC responded with. It's implemented by FakeHMRC.
+type HMRCGateway interface {
+	Submit(ctx context.Context, document []byte) ([]byte, error)
+	// Poll goes to endpoint HMRC acknowledged submission with.
+	Poll(ctx context.Context, endpoint string, document []byte) ([]byte, error)
+}
+
+// FPSSubmission is what HMRC acknowledged FPS with. Response is polled for with correlation ID, from endpoint, not
+// sooner than after poll interval.
+type FPSSubmission struct {
+	CorrelationID string
+	Endpoint      string
+	PollInterval  time.Duration
+	// IRmark receipt in the response has to acknowledge.
+	IRmark hmrc.IRmark
+	// PayrollIDs of employees in the order FPS has them. HMRC locates errors by that order.
+	PayrollIDs []string
+	// Details and Errors are set when HMRC rejected FPS right away, instead of acknowledging it. There is nothing to
+	// poll for then.
+	Details string
+	Errors  []FPSError
+}
+
+// SubmitFPS builds Full Payment Submission of the payroll, and submits it to HMRC.
+func (a *Activities) SubmitFPS(ctx context.Context, payrollID string) (FPSSubmission, error) {
+	run, err := a.Payrolls.Get(ctx, payrollID)
+	if err != nil {
+		return FPSSubmission{}, fmt.Errorf("fetching payroll run: %w", err)
+	}
+	document, err := hmrc.FPS(a.GovTalk, run)
+	var invalid *hmrc.ValidationError
+	if errors.As(err, &invalid) {
+		return FPSSubmission{}, temporal.NewNonRetryableApplicationError(invalid.Error(), ErrorTypeInvalidFPS, err, invalid.Problems)
+	}
+	if err != nil {
+		return FPSSubmission{}, err
+	}
+	mark, err := hmrc.ComputeIRmark(document)
+	if err != nil {
+		return FPSSubmission{}, err
+	}
+	var payrollIDs []string
+	for _, payslip := range run.Payslips {
+		payrollIDs = append(payrollIDs, payslip.Employee.PayrollID)
+	}
+	response, err := a.submitToHMRC(ctx, "", document)
+	if err != nil {
+		return FPSSubmission{}, err
+	}
+	switch response.Qualifier {
+	case hmrc.QualifierAcknowledgement:
+	case hmrc.QualifierError:
+		// Only errors HMRC found in FPS are left, submitting it again won't help until payroll is corrected.
+		activity.GetLogger(ctx).Warn("FPS rejected on submission", "IRmark", mark.Base32(), "Errors", len(response.Errors))
+		return FPSSubmission{
+			IRmark:     mark,
+			PayrollIDs: payrollIDs,
+			Details:    response.Errors.Error(),
+			Errors:     fpsErrors(response.Errors, payrollIDs),
+		}, nil
+	default:
+		message := fmt.Sprintf("HMRC responded to FPS with %s, instead of acknowledgement", response.Qualifier)
+		return FPSSubmission{}, temporal.NewNonRetryableApplicationError(message, ErrorTypeGovTalk, nil)
+	}
+	// IRmark is what HMRC's receipt refers to, and what support asks for.
+	activity.GetLogger(ctx).Info("FPS submitted", "CorrelationID", response.CorrelationID, "IRmark", mark.Base32(), "Mode", a.GovTalk.Mode, "Payslips", len(run.Payslips))
+	return FPSSubmission{
+		CorrelationID: response.CorrelationID,
+		Endpoint:      response.Endpoint,
+		PollInterval:  response.PollInterval,
+		IRmark:        mark,
+		PayrollIDs:    payrollIDs,
+	}, nil
+}
+
+// submitToHMRC submits document, or polls endpoint with it. Errors HMRC raised about the document are returned in
+// response, as they are its outcome. Recoverable ones are retried, and the rest fail without retries.
+func (a *Activities) submitToHMRC(ctx context.Context, endpoint string, document []byte) (hmrc.Response, error) {
+	var data []byte
+	var err error
+	if endpoint == "" {
+		data, err = a.HMRC.Submit(ctx, document)
+	} else {
+		data, err = a.HMRC.Poll(ctx, endpoint, document)
+	}
+	if err != nil {
+		return hmrc.Response{}, fmt.Errorf("posting to HMRC: %w", err)
+	}
+	response, err := hmrc.ParseResponse(data)
+	if err != nil {
+		return hmrc.Response{}, err
+	}
+	if response.Qualifier == hmrc.QualifierError {
+		if response.Errors.Recoverable() {
+			return hmrc.Response{}, response.Errors
+		}
+		if !raisedByDepartment(response.Errors) {
+			return hmrc.Response{}, temporal.NewNonRetryableApplicationError(response.Errors.Error(), ErrorTypeGovTalk, response.Errors, response.Errors)
+		}
+	}
+	return response, nil
+}
+
+func raisedByDepartment(errs hmrc.GovTalkErrors) bool {
+	for _, err := range errs {
+		if err.RaisedBy == "Department" {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportFPS is SubmitFPS of runs started before fpsPollingChange. They only need correlation ID.
+func (a *Activities) ReportFPS(ctx context.Context, payrollID string) (FPSReportReference, error) {
+	submission, err := a.SubmitFPS(ctx, payrollID)
+	if err != nil {
 		return "", err
 	}
+	if len(submission.Errors) > 0 {
+		return "", temporal.NewNonRetryableApplicationError(submission.Details, ErrorTypeFPSRejected, nil, submission.Errors)
+	}
+	return FPSReportReference(submission.CorrelationID), nil
+}
 
-	return FPSReportReference("fps-" + payrollID), nil
+// FakePayrollRuns pays the same two employees on every payroll.
+type FakePayrollRuns struct{}
+
+func (FakePayrollRuns) Get(_ context.Context, payrollID string) (payroll.Run, error) {
+	return payroll.Run{
+		PayrollID: payrollID,
+		Employer: payroll.Employer{
+			TaxOfficeNumber:         "123",
+			TaxOfficeReference:      "AB456",
+			AccountsOfficeReference: "123PA00045678",
+		},
+		// Fixed, so FPS is always valid for a schema we have.
+		PaymentDate: time.Date(2025, time.May, 30, 0, 0, 0, 0, time.UTC),
+		Frequency:   payroll.Monthly,
+		Period:      2,
+		Payslips: []payroll.Payslip{
+			{
+				Employee: payroll.Employee{
+					PayrollID: "employee-1",
+					NINO:      "AB123456C",
+					Forename:  "Joe",
+					Surname:   "Smith",
+					BirthDate: time.Date(1990, time.January, 15, 0, 0, 0, 0, time.UTC),
+					Gender:    "M",
+					StartDate: time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC),
+				},
+				HoursWorked:    payroll.Hours30OrMore,
+				TaxCode:        "1257L",
+				NICategory:     "A",
+				TaxablePay:     2_500_00,
+				TaxablePayYTD:  5_000_00,
+				Tax:            290_40,
+				TaxYTD:         580_80,
+				NIablePay:      2_500_00,
+				NIablePayYTD:   5_000_00,
+				AtLELYTD:       1_084_00,
+				LELToPTYTD:     174_00,
+				PTToUELYTD:     3_742_00,
+				EmployeeNIC:    119_60,
+				EmployeeNICYTD: 239_20,
+				EmployerNIC:    300_00,
+				EmployerNICYTD: 600_00,
+			},
+			{
+				Employee: payroll.Employee{
+					PayrollID: "employee-2",
+					NINO:      "CE654321A",
+					Forename:  "Jane",
+					Surname:   "Doe",
+					BirthDate: time.Date(2001, time.July, 3, 0, 0, 0, 0, time.UTC),
+					Gender:    "F",
+					StartDate: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC),
+				},
+				NewStarter:    true,
+				HoursWorked:   payroll.HoursUpTo16,
+				TaxCode:       "S1257L M1",
+				NICategory:    "M",
+				TaxablePay:    800_00,
+				TaxablePayYTD: 800_00,
+				NIablePay:     800_00,
+				NIablePayYTD:  800_00,
+				AtLELYTD:      542_00,
+				LELToPTYTD:    87_00,
+				PTToUELYTD:    171_00,
+				EmployerNIC:   45_00,
+			},
+		},
+	}, nil
 }
 
-type FPSReportStatus struct {
-	StillPending   bool
-	WasSuccessFull bool
-	Details        string
+// FakeHMRC takes any document with correct IRmark, and has a response ready after ProcessingTime, until we talk to
+// the real one. It keeps submissions in memory, polls after restart get an error, unless Dir is set.
+type FakeHMRC struct {
+	ProcessingTime time.Duration
+	PollInterval   time.Duration
+	// Dir keeps submissions in JSON files, so polls after restart still find them. Same as delivery.FileStore, writes
+	// are serialised within a process only.
+	Dir string
+
+	mu          sync.Mutex
+	submissions map[string]fakeSubmission
 }
 
-func CheckFPSReport(_ context.Context, reference FPSReportReference) (FPSReportStatus, error) {
-	time.Sleep(time.Second)
+type fakeSubmission struct {
+	IRmark      hmrc.IRmark
+	SubmittedAt time.Time
+}
 
-	if err := failXOutOf10Times(9); err != nil {
-		return FPSReportStatus{StillPending: true}, err
+func NewFakeHMRC() *FakeHMRC {
+	return &FakeHMRC{
+		ProcessingTime: 5 * time.Second,
+		PollInterval:   2 * time.Second,
+		submissions:    map[string]fakeSubmission{},
 	}
+}
 
-	if err := failXOutOf10Times(5); err != nil {
-		return FPSReportStatus{WasSuccessFull: false, Details: "HMRC is down"}, err
+const fakeHMRCEndpoint = "https://fake-hmrc.local/poll"
+
+// Submit derives correlation ID from the document, so a retried submission gets the same one.
+func (h *FakeHMRC) Submit(_ context.Context, document []byte) ([]byte, error) {
+	mark, err := hmrc.Verify(document)
+	if err != nil {
+		return fakeHMRCResponse("error", "", "", fakeHMRCError("Gateway", "fatal", err.Error()), ""), nil
+	}
+	sum := sha1.Sum(document)
+	correlationID := strings.ToUpper(hex.EncodeToString(sum[:16]))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok, err := h.submission(correlationID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := h.save(correlationID, fakeSubmission{IRmark: mark, SubmittedAt: time.Now()}); err != nil {
+			return nil, err
+		}
+	}
+	return h.acknowledgement(correlationID), nil
+}
+
+func (h *FakeHMRC) Poll(_ context.Context, _ string, document []byte) ([]byte, error) {
+	var poll struct {
+		CorrelationID string `xml:"Header>MessageDetails>CorrelationID"`
+	}
+	if err := xml.Unmarshal(document, &poll); err != nil {
+		return nil, err
 	}
 
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	submission, ok, err := h.submission(poll.CorrelationID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return fakeHMRCResponse("error", poll.CorrelationID, "", fakeHMRCError("Gateway", "fatal", "Unknown correlation ID"), ""), nil
+	}
+	if time.Since(submission.SubmittedAt) < h.ProcessingTime {
+		return h.acknowledgement(poll.CorrelationID), nil
+	}
+	receipt := fmt.Sprintf(`<SuccessResponse xmlns="http://www.inlandrevenue.gov.uk/SuccessResponse">
+      <IRmarkReceipt>
+        <Message code="0">HMRC has received the HMRC-PAYE-RTI-FPS document with the IRmark %s on %s.</Message>
+      </IRmarkReceipt>
+    </SuccessResponse>`, submission.IRmark.Base32(), time.Now().Format(time.DateOnly))
+	return fakeHMRCResponse("response", poll.CorrelationID, "", "", receipt), nil
+}
+
+// submission is looked up in memory first, and in Dir when this process didn't see it.
+func (h *FakeHMRC) submission(correlationID string) (fakeSubmission, bool, error) {
+	if submission, ok := h.submissions[correlationID]; ok || h.Dir == "" {
+		return submission, ok, nil
+	}
+	data, err := os.ReadFile(h.path(correlationID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fakeSubmission{}, false, nil
+	}
+	if err != nil {
+		return fakeSubmission{}, false, err
+	}
+	var submission fakeSubmission
+	if err := json.Unmarshal(data, &submission); err != nil {
+		return fakeSubmission{}, false, fmt.Errorf("reading submission %s: %w", correlationID, err)
+	}
+	h.submissions[correlationID] = submission
+	return submission, true, nil
+}
+
+func (h *FakeHMRC) save(correlationID string, submission fakeSubmission) error {
+	if h.Dir != "" {
+		data, err := json.Marshal(submission)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(h.Dir, 0o700); err != nil {
+			return fmt.Errorf("creating fake HMRC directory: %w", err)
+		}
+		// Write and rename, so a poll can't read half-written submission.
+		tmp, err := os.CreateTemp(h.Dir, ".tmp-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(data); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), h.path(correlationID)); err != nil {
+			return err
+		}
+	}
+	h.submissions[correlationID] = submission
+	return nil
+}
+
+// path escapes correlation ID, so it can't point outside of the directory.
+func (h *FakeHMRC) path(correlationID string) string {
+	return filepath.Join(h.Dir, url.PathEscape(correlationID)+".json")
+}
+
+func (h *FakeHMRC) acknowledgement(correlationID string) []byte {
+	endpoint := fmt.Sprintf(`<ResponseEndPoint PollInterval="%d">%s</ResponseEndPoint>`, int(h.PollInterval.Seconds()), fakeHMRCEndpoint)
+	return fakeHMRCResponse("acknowledgement", correlationID, endpoint, "", "")
+}
+
+func fakeHMRCError(raisedBy, errorType, text string) string {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(text))
+	return fmt.Sprintf(`<GovTalkErrors><Error><RaisedBy>%s</RaisedBy><Type>%s</Type><Text>%s</Text></Error></GovTalkErrors>`, raisedBy, errorType, escaped.String())
+}
+
+// fakeHMRCResponse is a GovTalk message with endpoint element, errors and body as they are.
+func fakeHMRCResponse(qualifier, correlationID, endpoint, errors, body string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<GovTalkMessage xmlns="http://www.govtalk.gov.uk/CM/envelope">
+  <EnvelopeVersion>2.0</EnvelopeVersion>
+  <Header>
+    <MessageDetails>
+      <Class>HMRC-PAYE-RTI-FPS</Class>
+      <Qualifier>%s</Qualifier>
+      <Function>submit</Function>
+      <CorrelationID>%s</CorrelationID>
+      %s
+    </MessageDetails>
+    <SenderDetails/>
+  </Header>
+  <GovTalkDetails>%s</GovTalkDetails>
+  <Body>%s</Body>
+</GovTalkMessage>
+`, qualifier, correlationID, endpoint, errors, body))
+}
+
+type FPSReportStatus struct {
+	StillPending   bool
+	WasSuccessFull bool
+	Details        string
+	// Errors HMRC found in FPS, when it wasn't successful.
+	Errors []FPSError
+	// Endpoint and PollInterval say where and when to poll next, while pending.
+	Endpoint     string
+	PollInterval time.Duration
+}
+
+// CheckFPSReport is polled by runs started before fpsPollingChange.
+func CheckFPSReport(_ context.Context, reference FPSReportReference) (FPSReportStatus, error) {
 	return FPSReportStatus{WasSuccessFull: true}, nil
 }
 
-func MarkFPSAsSuccessful(_ context.Context, payrollID string) error {
-	fmt.Printf("FPS for payroll %q was accepted by HMRC\n", payrollID)
+func MarkFPSAsSuccessful(ctx context.Context, payrollID string) error {
+	activity.GetLogger(ctx).Info("FPS was accepted by HMRC")
 	return nil
 }
 
-func SendDocuments(_ context.Context, payrollID string) error {
-	fmt.Println("Sending payslips to employees...")
+func SendDocuments(ctx context.Context, payrollID string) error {
+	activity.GetLogger(ctx).Info("Sending payslips to employees")
 	return nil
 }
@@ -0,0 +1,289 @@
+package workflows
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type ProcessPayrollTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestProcessPayroll(t *testing.T) {
+	suite.Run(t, new(ProcessPayrollTestSuite))
+}
+
+func (s *ProcessPayrollTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterWorkflow(ProcessPayments)
+	s.env.RegisterWorkflow(AwaitFPSResponse)
+}
+
+func (s *ProcessPayrollTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+const fpsReference = FPSReportReference("fps-payroll-1")
+
+var fpsSubmission = FPSSubmission{
+	CorrelationID: string(fpsReference),
+	Endpoint:      "https://hmrc.example/poll",
+	PollInterval:  10 * time.Second,
+}
+
+// mockFPSAccepted mocks everything up to, and including, HMRC accepting FPS.
+func (s *ProcessPayrollTestSuite) mockFPSAccepted() {
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(true, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(fpsSubmission, nil).Once()
+	s.env.OnWorkflow(AwaitFPSResponse, mock.Anything, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission}).
+		Return(FPSReportStatus{WasSuccessFull: true}, nil).Once()
+	s.env.OnActivity(MarkFPSAsSuccessful, mock.Anything, "payroll-1").Return(nil).Once()
+}
+
+func (s *ProcessPayrollTestSuite) Test_ProcessesPayroll() {
+	s.mockFPSAccepted()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Once()
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(PayrollStatus{Stage: PayrollStageCompleted, FPSReference: fpsReference}, s.queryStatus())
+}
+
+func (s *ProcessPayrollTestSuite) Test_SkipsPayroll_WhenItCantBeProcessed() {
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(false, nil).Once()
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(PayrollStageSkipped, s.queryStatus().Stage)
+	s.env.AssertNotCalled(s.T(), "ReportFPS", mock.Anything, mock.Anything)
+}
+
+func (s *ProcessPayrollTestSuite) Test_Fails_WhenFPSHasBusinessErrors_BeforeFPSCorrection() {
+	s.env.OnGetVersion(fpsCorrectionChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(true, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(fpsSubmission, nil).Once()
+	s.env.OnWorkflow(AwaitFPSResponse, mock.Anything, mock.Anything).Return(FPSReportStatus{Details: "invalid NI number"}, nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Maybe()
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "FPS has business errors")
+	s.env.AssertNotCalled(s.T(), "MarkFPSAsSuccessful", mock.Anything, mock.Anything)
+	s.env.AssertNotCalled(s.T(), "SendDocuments", mock.Anything, mock.Anything)
+}
+
+var rejectedFPSErrors = []FPSError{
+	{Code: "7786", Message: "NINO is not valid", PayrollID: "employee-2", Field: "EmployeeDetails/NINO"},
+	{Code: "7786", Message: "NINO is not valid", PayrollID: "employee-3", Field: "EmployeeDetails/NINO"},
+	{Code: "7801", Message: "Related tax year is wrong", Field: "/IRenvelope/FullPaymentSubmission/RelatedTaxYear"},
+}
+
+// mockFPSRejected mocks HMRC rejecting the first FPS, and accepting the corrected one.
+func (s *ProcessPayrollTestSuite) mockFPSRejected() {
+	corrected := fpsSubmission
+	corrected.CorrelationID = "fps-payroll-1-corrected"
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(true, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(fpsSubmission, nil).Once()
+	s.env.OnWorkflow(AwaitFPSResponse, mock.Anything, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission}).
+		After(time.Minute).Return(FPSReportStatus{Details: "GovTalk errors", Errors: rejectedFPSErrors}, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(corrected, nil).Once()
+	s.env.OnWorkflow(AwaitFPSResponse, mock.Anything, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: corrected}).
+		Return(FPSReportStatus{WasSuccessFull: true}, nil).Once()
+	s.env.OnActivity(MarkFPSAsSuccessful, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Once()
+}
+
+func (s *ProcessPayrollTestSuite) Test_AwaitsCorrection_AndSubmitsFPSAgain_WhenFPSHasBusinessErrors() {
+	s.mockFPSRejected()
+	var searchAttributes []temporal.SearchAttributes
+	s.env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+		searchAttributes = append(searchAttributes, args.Get(0).(temporal.SearchAttributes))
+	}).Return(nil)
+	s.env.RegisterDelayedCallback(func() {
+		status := s.queryStatus()
+		s.Equal(PayrollStageAwaitingFPSCorrection, status.Stage)
+		s.Equal(rejectedFPSErrors, status.FPSErrors)
+	}, time.Hour)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FPSCorrectedSignal, FPSCorrection{Note: "fixed NINOs"})
+	}, 2*time.Hour)
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	status := s.queryStatus()
+	s.Equal(PayrollStageCompleted, status.Stage)
+	s.Equal(FPSReportReference("fps-payroll-1-corrected"), status.FPSReference)
+	s.Empty(status.FPSErrors)
+
+	s.Require().Len(searchAttributes, 2)
+	needsAttention, _ := searchAttributes[0].GetBool(NeedsAttention)
+	s.True(needsAttention)
+	codes, _ := searchAttributes[0].GetKeywordList(FPSErrorCodes)
+	s.Equal([]string{"7786", "7801"}, codes)
+	needsAttention, _ = searchAttributes[1].GetBool(NeedsAttention)
+	s.False(needsAttention)
+	s.False(searchAttributes[1].ContainsKey(FPSErrorCodes))
+}
+
+func (s *ProcessPayrollTestSuite) Test_IgnoresCorrection_SignalledBeforeFPSWasRejected() {
+	s.mockFPSRejected()
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FPSCorrectedSignal, FPSCorrection{Note: "too early"})
+	}, time.Second)
+	s.env.RegisterDelayedCallback(func() {
+		s.Equal(PayrollStageAwaitingFPSCorrection, s.queryStatus().Stage)
+	}, time.Hour)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FPSCorrectedSignal, FPSCorrection{Note: "fixed NINOs"})
+	}, 2*time.Hour)
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *ProcessPayrollTestSuite) Test_AwaitsCorrection_WhenFPSIsRejectedOnSubmission() {
+	rejected := FPSSubmission{Details: "GovTalk errors", Errors: rejectedFPSErrors}
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(true, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(rejected, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(fpsSubmission, nil).Once()
+	s.env.OnWorkflow(AwaitFPSResponse, mock.Anything, AwaitFPSResponseInput{PayrollID: "payroll-1", Submission: fpsSubmission}).
+		Return(FPSReportStatus{WasSuccessFull: true}, nil).Once()
+	s.env.OnActivity(MarkFPSAsSuccessful, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnUpsertTypedSearchAttributes(mock.Anything).Return(nil)
+	s.env.RegisterDelayedCallback(func() {
+		status := s.queryStatus()
+		s.Equal(PayrollStageAwaitingFPSCorrection, status.Stage)
+		s.Equal(rejectedFPSErrors, status.FPSErrors)
+	}, time.Hour)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(FPSCorrectedSignal, FPSCorrection{Note: "fixed NINOs"})
+	}, 2*time.Hour)
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(PayrollStatus{Stage: PayrollStageCompleted, FPSReference: fpsReference}, s.queryStatus())
+}
+
+func (s *ProcessPayrollTestSuite) Test_AwaitsFPSResponseInChildWorkflow() {
+	s.mockFPSAccepted()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Once()
+
+	var childIDs []string
+	s.env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, _ workflow.Context, _ converter.EncodedValues) {
+		childIDs = append(childIDs, info.WorkflowExecution.ID)
+	})
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Contains(childIDs, AwaitFPSResponseWorkflowID("payroll-1", string(fpsReference)))
+}
+
+func (s *ProcessPayrollTestSuite) Test_Fails_WhenHMRCDoesNotRespond() {
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(true, nil).Once()
+	s.env.OnActivity(a.SubmitFPS, mock.Anything, "payroll-1").Return(fpsSubmission, nil).Once()
+	s.env.OnWorkflow(AwaitFPSResponse, mock.Anything, mock.Anything).
+		Return(FPSReportStatus{}, temporal.NewNonRetryableApplicationError("no response", ErrorTypeFPSResponseTimeout, nil)).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Maybe()
+
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	var appErr *temporal.ApplicationError
+	s.Require().ErrorAs(s.env.GetWorkflowError(), &appErr)
+	s.Equal(ErrorTypeFPSResponseTimeout, appErr.Type())
+	s.env.AssertNotCalled(s.T(), "SendDocuments", mock.Anything, mock.Anything)
+}
+
+func (s *ProcessPayrollTestSuite) Test_PollsHMRC_UntilFPSIsNoLongerPending_BeforeFPSPolling() {
+	s.env.OnGetVersion(fpsPollingChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.env.OnActivity(CanPayrollBeProcessed, mock.Anything, "payroll-1").Return(true, nil).Once()
+	s.env.OnActivity(a.ReportFPS, mock.Anything, "payroll-1").Return(fpsReference, nil).Once()
+	// HMRC being down is retried by activity retry policy, and pending status by the loop in workflow.
+	s.env.OnActivity(CheckFPSReport, mock.Anything, mock.Anything).Return(FPSReportStatus{}, errors.New("HMRC is down")).Times(3)
+	s.env.OnActivity(CheckFPSReport, mock.Anything, mock.Anything).Return(FPSReportStatus{StillPending: true}, nil).Times(3)
+	s.env.OnActivity(CheckFPSReport, mock.Anything, mock.Anything).Return(FPSReportStatus{WasSuccessFull: true}, nil).Once()
+	s.env.OnActivity(MarkFPSAsSuccessful, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").Return(nil).Once()
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	// Retries are backed off, but test environment skips time instead of waiting.
+	s.True(s.env.Now().After(start))
+}
+
+func (s *ProcessPayrollTestSuite) Test_WaitsForPayments() {
+	s.mockFPSAccepted()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnWorkflow(ProcessPayments, mock.Anything, "payroll-1").After(time.Hour).Return(nil).Once()
+
+	stageBeforePayments := PayrollStage("")
+	s.env.RegisterDelayedCallback(func() {
+		stageBeforePayments = s.queryStatus().Stage
+	}, 30*time.Minute)
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(PayrollStageAwaitingPayments, stageBeforePayments)
+}
+
+func (s *ProcessPayrollTestSuite) Test_ProcessesPaymentsInChildWorkflow() {
+	s.mockFPSAccepted()
+	s.env.OnActivity(SendDocuments, mock.Anything, "payroll-1").Return(nil).Once()
+	s.env.OnActivity(FindPayments, mock.Anything, "payroll-1").Return(payments, nil).Once()
+	s.env.OnActivity(SchedulePayment, mock.Anything, mock.Anything).Return(nil).Times(len(payments))
+	s.env.OnActivity(IsPaymentPaid, mock.Anything, mock.Anything).Return(true, nil).Times(len(payments))
+	s.env.OnActivity(ReconcileInAccountingIntegration, mock.Anything, mock.Anything).Return(nil).Times(len(payments))
+
+	var childTaskQueue string
+	s.env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, _ workflow.Context, _ converter.EncodedValues) {
+		if info.WorkflowType.Name == "ProcessPayments" {
+			childTaskQueue = info.TaskQueueName
+		}
+	})
+	s.env.ExecuteWorkflow(ProcessPayroll, "payroll-1")
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(TaskQueuePayments, childTaskQueue)
+}
+
+func (s *ProcessPayrollTestSuite) queryStatus() PayrollStatus {
+	value, err := s.env.QueryWorkflow(PayrollStatusQuery)
+	s.Require().NoError(err)
+	var status PayrollStatus
+	s.Require().NoError(value.Get(&status))
+	return status
+}
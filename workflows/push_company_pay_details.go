@@ -0,0 +1,279 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/delivery"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// PayslipReadySignal queues pay details of a payslip for PushCompanyPayDetails. Send PayDetailsRef with
+// SignalWithStartWorkflow and PushCompanyPayDetailsOptions, so workflow is started when it's not running.
+const PayslipReadySignal = "payslip-ready"
+
+type PushCompanyPayDetailsInput struct {
+	CompanyID string
+	// Pending payslips, carried over by continue-as-new.
+	Pending []string
+}
+
+const (
+	// payDetailsBatchSize payslips are pushed to Bob in a single request.
+	payDetailsBatchSize = 100
+	// payDetailsFlushInterval is the longest a payslip waits for its batch to fill up.
+	payDetailsFlushInterval = 10 * time.Second
+	// payDetailsBatchesPerRun keeps history short. Payroll of 5,000 employees still fits in a single run.
+	payDetailsBatchesPerRun = 100
+	// payDetailsIdleTimeout ends the workflow when no payslips come for a while. Next one starts it again.
+	payDetailsIdleTimeout = time.Hour
+)
+
+// handOverFailedPayDetailsChange hands payslips that failed in a batch over to PushPayDetails workflows of their own,
+// which retry and then wait for an operator. Before, they were recorded as failed, and nobody looked at them again.
+const handOverFailedPayDetailsChange = "hand-over-failed-pay-details"
+
+// PushCompanyPayDetails pushes pay details of a company to Bob in batches. Whole payroll ends up as a few workflows
+// and requests, instead of one per payslip.
+func PushCompanyPayDetails(ctx workflow.Context, input PushCompanyPayDetailsInput) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:           TaskQueueDocuments,
+		StartToCloseTimeout: time.Minute,
+	})
+
+	payslips := workflow.GetSignalChannel(ctx, PayslipReadySignal)
+	pending := input.Pending
+	// Payslip queued twice is pushed once, with whatever its pay details are by then.
+	queued := map[string]bool{}
+	for _, payslipID := range pending {
+		queued[payslipID] = true
+	}
+	receive := func(ref PayDetailsRef) {
+		if !queued[ref.PayslipID] {
+			queued[ref.PayslipID] = true
+			pending = append(pending, ref.PayslipID)
+		}
+	}
+
+	for batches := 1; ; batches++ {
+		if len(pending) == 0 {
+			var ref PayDetailsRef
+			if ok, _ := payslips.ReceiveWithTimeout(ctx, payDetailsIdleTimeout, &ref); !ok {
+				// Payslip could have come right after the timer fired. Completing now would lose it.
+				if !payslips.ReceiveAsync(&ref) {
+					workflow.GetLogger(ctx).Info("No payslips for a while, stopping")
+					return nil
+				}
+			}
+			receive(ref)
+		}
+
+		deadline := workflow.Now(ctx).Add(payDetailsFlushInterval)
+		for len(pending) < payDetailsBatchSize {
+			wait := deadline.Sub(workflow.Now(ctx))
+			if wait <= 0 {
+				break
+			}
+			var ref PayDetailsRef
+			if ok, _ := payslips.ReceiveWithTimeout(ctx, wait, &ref); !ok {
+				break
+			}
+			receive(ref)
+		}
+
+		size := min(len(pending), payDetailsBatchSize)
+		if err := pushPayDetailsBatch(ctx, PayDetailsBatch{CompanyID: input.CompanyID, PayslipIDs: pending[:size]}); err != nil {
+			return err
+		}
+		for _, payslipID := range pending[:size] {
+			delete(queued, payslipID)
+		}
+		pending = pending[size:]
+
+		if batches >= payDetailsBatchesPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			var ref PayDetailsRef
+			for payslips.ReceiveAsync(&ref) {
+				receive(ref)
+			}
+			input.Pending = pending
+			return workflow.NewContinueAsNewError(ctx, PushCompanyPayDetails, input)
+		}
+	}
+}
+
+// pushPayDetailsBatch fails only if outcomes can't be recorded, or failed payslips can't be handed over.
+func pushPayDetailsBatch(ctx workflow.Context, batch PayDetailsBatch) error {
+	var a *Activities
+	// Same as for a single payslip, we give up after 5 attempts.
+	limitedRetryCtx := workflow.WithRetryPolicy(ctx, temporal.RetryPolicy{
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    time.Second * 100,
+		MaximumAttempts:    5,
+	})
+	var outcomes []PayDetailsOutcome
+	err := workflow.ExecuteActivity(limitedRetryCtx, a.PushPayDetailsBatchToBob, batch).Get(limitedRetryCtx, &outcomes)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Unable to push batch of pay details to Bob", "Payslips", len(batch.PayslipIDs), "Error", err)
+		outcomes = make([]PayDetailsOutcome, len(batch.PayslipIDs))
+		for i, payslipID := range batch.PayslipIDs {
+			outcomes[i] = PayDetailsOutcome{PayslipID: payslipID, Error: err.Error()}
+		}
+	}
+
+	var failed []string
+	for _, outcome := range outcomes {
+		if !outcome.Sent {
+			failed = append(failed, outcome.PayslipID)
+		}
+	}
+	handOver := false
+	if len(failed) > 0 {
+		workflow.GetMetricsHandler(ctx).Counter(MetricPayDetailsPushFailures).Inc(int64(len(failed)))
+		handOver = workflow.GetVersion(ctx, handOverFailedPayDetailsChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+	}
+	recorded := PayDetailsOutcomes{CompanyID: batch.CompanyID, Outcomes: outcomes, HandedOver: handOver}
+	if err := workflow.ExecuteActivity(ctx, a.RecordPayDetailsOutcomes, recorded).Get(ctx, nil); err != nil {
+		return err
+	}
+	if !handOver {
+		return nil
+	}
+	return handOverPayDetails(ctx, batch.CompanyID, failed)
+}
+
+// handOverPayDetails starts PushPayDetails of each payslip, and doesn't wait for them to finish. Pushes outlive the
+// batch, which continues as new, or stops when idle.
+func handOverPayDetails(ctx workflow.Context, companyID string, payslipIDs []string) error {
+	started := make([]workflow.Future, len(payslipIDs))
+	for i, payslipID := range payslipIDs {
+		input := PushPayDetailsInput{CompanyID: companyID, PayslipID: payslipID}
+		pushCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			WorkflowID:            PushPayDetailsWorkflowID(input),
+			TaskQueue:             TaskQueueDocuments,
+			WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+			ParentClosePolicy:     enums.PARENT_CLOSE_POLICY_ABANDON,
+		})
+		started[i] = workflow.ExecuteChildWorkflow(pushCtx, PushPayDetails, input).GetChildWorkflowExecution()
+	}
+	for i, future := range started {
+		err := future.Get(ctx, nil)
+		if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			// Someone pushes it on its own already, and an operator will see that one if it fails.
+			workflow.GetLogger(ctx).Info("Payslip is already being pushed", "PayslipID", payslipIDs[i])
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("handing over payslip %s: %w", payslipIDs[i], err)
+		}
+	}
+	return nil
+}
+
+type PayDetailsBatch struct {
+	CompanyID  string
+	PayslipIDs []string
+}
+
+type PayDetailsOutcome struct {
+	PayslipID string
+	Sent      bool
+	// Error tells why pay details weren't sent.
+	Error string
+}
+
+type PayDetailsOutcomes struct {
+	CompanyID string
+	Outcomes  []PayDetailsOutcome
+	// HandedOver payslips that failed are pushed again on their own, so they're recorded as failed attempts.
+	HandedOver bool
+}
+
+// PushPayDetailsBatchToBob fetches pay details of every payslip, and pushes them in a single request. Payslips that
+// can't be found, or that Bob rejects, fail on their own. The rest of the batch is still sent, and recorded as sending
+// first, same as single pushes.
+func (a *Activities) PushPayDetailsBatchToBob(ctx context.Context, batch PayDetailsBatch) ([]PayDetailsOutcome, error) {
+	outcomes := make([]PayDetailsOutcome, len(batch.PayslipIDs))
+	var details []bob.PayDetails
+	for i, payslipID := range batch.PayslipIDs {
+		outcomes[i].PayslipID = payslipID
+		payDetails, err := a.PayDetails.Get(ctx, PayDetailsRef{CompanyID: batch.CompanyID, PayslipID: payslipID})
+		if errors.Is(err, ErrPayDetailsNotFound) {
+			outcomes[i].Error = err.Error()
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fetching pay details: %w", err)
+		}
+		details = append(details, bob.PayDetails{
+			CompanyID: payDetails.CompanyID,
+			PayslipID: payDetails.PayslipID,
+			Payday:    payDetails.Payday,
+			FirstName: payDetails.FirstName,
+			LastName:  payDetails.LastName,
+			Salary:    payDetails.Salary,
+		})
+	}
+	if len(details) == 0 {
+		return outcomes, nil
+	}
+	for _, payDetails := range details {
+		ref := PayDetailsRef{CompanyID: batch.CompanyID, PayslipID: payDetails.PayslipID}
+		if err := a.recordDelivery(ctx, ref, delivery.StateSending, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := a.Bob.PushPayDetailsBatch(ctx, batch.CompanyID, details)
+	if err != nil {
+		return nil, bobError(err)
+	}
+	errorsByPayslip := map[string]string{}
+	for _, result := range results {
+		errorsByPayslip[result.PayslipID] = result.Error
+	}
+	for i := range outcomes {
+		if outcomes[i].Error != "" {
+			continue
+		}
+		rejection, ok := errorsByPayslip[outcomes[i].PayslipID]
+		switch {
+		case !ok:
+			outcomes[i].Error = "bob didn't report outcome of the payslip"
+		case rejection != "":
+			outcomes[i].Error = rejection
+		default:
+			outcomes[i].Sent = true
+		}
+	}
+	return outcomes, nil
+}
+
+func (a *Activities) RecordPayDetailsOutcomes(ctx context.Context, outcomes PayDetailsOutcomes) error {
+	logger := activity.GetLogger(ctx)
+	for _, outcome := range outcomes.Outcomes {
+		ref := PayDetailsRef{CompanyID: outcomes.CompanyID, PayslipID: outcome.PayslipID}
+		state := delivery.StateSent
+		if outcome.Sent {
+			logger.Info("Pay details sent", "PayslipID", outcome.PayslipID)
+		} else {
+			logger.Warn("Pay details failed", "PayslipID", outcome.PayslipID, "Error", outcome.Error)
+			state = delivery.StateFailed
+			if outcomes.HandedOver {
+				state = delivery.StateAttemptFailed
+			}
+		}
+		// Retry records outcomes again, repeated ones are ignored. Failed attempts are not, they may count twice.
+		if err := a.recordDelivery(ctx, ref, state, outcome.Error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
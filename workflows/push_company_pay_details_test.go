@@ -0,0 +1,163 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type PushCompanyPayDetailsTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestPushCompanyPayDetails(t *testing.T) {
+	suite.Run(t, new(PushCompanyPayDetailsTestSuite))
+}
+
+func (s *PushCompanyPayDetailsTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+func (s *PushCompanyPayDetailsTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+// payslipsReady signals all payslips at once, after delay.
+func (s *PushCompanyPayDetailsTestSuite) payslipsReady(delay time.Duration, ids ...string) {
+	s.env.RegisterDelayedCallback(func() {
+		for _, id := range ids {
+			s.env.SignalWorkflow(PayslipReadySignal, PayDetailsRef{CompanyID: "company-1", PayslipID: id})
+		}
+	}, delay)
+}
+
+func payslipIDs(from, to int) []string {
+	var ids []string
+	for i := from; i <= to; i++ {
+		ids = append(ids, fmt.Sprintf("payslip-%d", i))
+	}
+	return ids
+}
+
+func sent(ids []string) []PayDetailsOutcome {
+	outcomes := make([]PayDetailsOutcome, len(ids))
+	for i, id := range ids {
+		outcomes[i] = PayDetailsOutcome{PayslipID: id, Sent: true}
+	}
+	return outcomes
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_FlushesBatch_OnTimer() {
+	s.payslipsReady(0, "payslip-1")
+	s.payslipsReady(5*time.Second, "payslip-2", "payslip-1")
+	s.payslipsReady(20*time.Second, "payslip-3")
+	first := PayDetailsBatch{CompanyID: "company-1", PayslipIDs: []string{"payslip-1", "payslip-2"}}
+	second := PayDetailsBatch{CompanyID: "company-1", PayslipIDs: []string{"payslip-3"}}
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, first).Return(sent(first.PayslipIDs), nil).Once()
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, second).Return(sent(second.PayslipIDs), nil).Once()
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, PayDetailsOutcomes{CompanyID: "company-1", Outcomes: sent(first.PayslipIDs)}).Return(nil).Once()
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, PayDetailsOutcomes{CompanyID: "company-1", Outcomes: sent(second.PayslipIDs)}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1"})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_FlushesBatch_WhenFull() {
+	s.payslipsReady(0, payslipIDs(1, payDetailsBatchSize+1)...)
+	var pushedAt []time.Time
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, PayDetailsBatch{CompanyID: "company-1", PayslipIDs: payslipIDs(1, payDetailsBatchSize)}).
+		Run(func(mock.Arguments) { pushedAt = append(pushedAt, s.env.Now()) }).
+		Return(sent(payslipIDs(1, payDetailsBatchSize)), nil).Once()
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, PayDetailsBatch{CompanyID: "company-1", PayslipIDs: payslipIDs(payDetailsBatchSize+1, payDetailsBatchSize+1)}).
+		Run(func(mock.Arguments) { pushedAt = append(pushedAt, s.env.Now()) }).
+		Return(sent(payslipIDs(payDetailsBatchSize+1, payDetailsBatchSize+1)), nil).Once()
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, mock.Anything).Return(nil).Twice()
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1"})
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Require().Len(pushedAt, 2)
+	s.Equal(start, pushedAt[0], "full batch doesn't wait for timer")
+	s.Equal(payDetailsFlushInterval, pushedAt[1].Sub(start))
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_HandsFailedPayslipsOver_WhenRetriesAreExhausted() {
+	s.payslipsReady(0, "payslip-1", "payslip-2")
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, mock.Anything).Return(nil, errors.New("bob is down"))
+	var recorded PayDetailsOutcomes
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { recorded = args.Get(1).(PayDetailsOutcomes) }).
+		Return(nil).Once()
+	for _, id := range []string{"payslip-1", "payslip-2"} {
+		s.env.OnWorkflow(PushPayDetails, mock.Anything, PushPayDetailsInput{CompanyID: "company-1", PayslipID: id}).Return(nil).Once()
+	}
+
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1"})
+
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertNumberOfCalls(s.T(), "PushPayDetailsBatchToBob", 5)
+	s.True(recorded.HandedOver)
+	s.Require().Len(recorded.Outcomes, 2)
+	s.False(recorded.Outcomes[0].Sent)
+	s.Contains(recorded.Outcomes[1].Error, "bob is down")
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_HandsOverOnlyFailedPayslips() {
+	s.payslipsReady(0, "payslip-1", "payslip-2")
+	outcomes := []PayDetailsOutcome{{PayslipID: "payslip-1", Sent: true}, {PayslipID: "payslip-2", Error: "unknown employee"}}
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, mock.Anything).Return(outcomes, nil).Once()
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, PayDetailsOutcomes{CompanyID: "company-1", Outcomes: outcomes, HandedOver: true}).Return(nil).Once()
+	s.env.OnWorkflow(PushPayDetails, mock.Anything, PushPayDetailsInput{CompanyID: "company-1", PayslipID: "payslip-2"}).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1"})
+
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_RecordsFailedPayslips_OfBatchStartedBeforeHandOver() {
+	s.env.OnGetVersion(handOverFailedPayDetailsChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.payslipsReady(0, "payslip-1")
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, mock.Anything).Return(nil, errors.New("bob is down"))
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, mock.MatchedBy(func(o PayDetailsOutcomes) bool { return !o.HandedOver })).
+		Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1"})
+
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertNotCalled(s.T(), "PushPayDetails", mock.Anything, mock.Anything)
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_ContinuesAsNew_WithPendingPayslips() {
+	for i := range payDetailsBatchesPerRun + 1 {
+		s.payslipsReady(time.Duration(i)*time.Minute, fmt.Sprintf("payslip-%d", i))
+	}
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, mock.Anything).Return(nil, nil)
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, mock.Anything).Return(nil)
+
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1"})
+
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+	s.env.AssertNumberOfCalls(s.T(), "PushPayDetailsBatchToBob", payDetailsBatchesPerRun)
+}
+
+func (s *PushCompanyPayDetailsTestSuite) Test_PushesPendingPayslips_AfterContinueAsNew() {
+	batch := PayDetailsBatch{CompanyID: "company-1", PayslipIDs: []string{"payslip-7"}}
+	s.env.OnActivity(a.PushPayDetailsBatchToBob, mock.Anything, batch).Return(sent(batch.PayslipIDs), nil).Once()
+	s.env.OnActivity(a.RecordPayDetailsOutcomes, mock.Anything, mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushCompanyPayDetails, PushCompanyPayDetailsInput{CompanyID: "company-1", Pending: []string{"payslip-7"}})
+
+	s.NoError(s.env.GetWorkflowError())
+}
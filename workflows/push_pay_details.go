@@ -2,9 +2,14 @@ package workflows
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"temporal-poc/bob"
+	"temporal-poc/delivery"
+
+	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
@@ -14,24 +19,93 @@ type PushPayDetailsInput struct {
 	PayslipID string
 }
 
+// payDetailsByReferenceChange passes references to activities, instead of pay details.
+const payDetailsByReferenceChange = "pay-details-by-reference"
+
+// operatorResolutionChange parks pushes that failed, until an operator retries or abandons them. Before, workflow
+// failed, and the only way out was starting another one.
+const operatorResolutionChange = "operator-resolution"
+
+// supersededPayDetailsChange records payslip replaced by retry with new data as superseded, and the new one as being
+// sent. Before, the replaced one was left as sending.
+const supersededPayDetailsChange = "superseded-pay-details"
+
+// abandonedPushChange records push abandoned by operator as abandoned, and completes. Before, payslip was recorded as
+// failed, and workflow failed, as if something went wrong.
+const abandonedPushChange = "abandoned-push"
+
+// Operators resolve failed pushes with these signals. Find them with `NeedsAttention = true` query.
+const (
+	// RetrySignal pushes the same payslip again. It takes no argument.
+	RetrySignal = "retry"
+	// RetryWithNewDataSignal pushes pay details of another payslip instead, see RetryWithNewData.
+	RetryWithNewDataSignal = "retry-with-new-data"
+	// AbandonSignal gives up on the push, see Abandon.
+	AbandonSignal = "abandon"
+)
+
+// PayDetailsPushStatusQuery returns PayDetailsPushStatus of a PushPayDetails workflow.
+const PayDetailsPushStatusQuery = "pay-details-push-status"
+
+// payDetailsEscalationTimeout is how long a failed push waits for an operator, before it's escalated.
+const payDetailsEscalationTimeout = 24 * time.Hour
+
+type PayDetailsPushStage string
+
+const (
+	PayDetailsPushStageSending        PayDetailsPushStage = "sending"
+	PayDetailsPushStageNeedsAttention PayDetailsPushStage = "needs-attention"
+	PayDetailsPushStageEscalated      PayDetailsPushStage = "escalated"
+	PayDetailsPushStageSent           PayDetailsPushStage = "sent"
+	PayDetailsPushStageAbandoned      PayDetailsPushStage = "abandoned"
+)
+
+type PayDetailsPushStatus struct {
+	Stage PayDetailsPushStage
+	// PayslipID is what's being pushed. Retry with new data can change it.
+	PayslipID string
+	// LastError and NeedsAttentionSince are set once push fails.
+	LastError           string
+	NeedsAttentionSince time.Time
+}
+
+// RetryWithNewData is an argument of RetryWithNewDataSignal. Pay details of a payslip can't change once it's issued,
+// corrections are issued as a new payslip.
+type RetryWithNewData struct {
+	PayslipID string
+}
+
+// Abandon is an argument of AbandonSignal.
+type Abandon struct {
+	Reason string
+}
+
 func PushPayDetails(ctx workflow.Context, input PushPayDetailsInput) error {
+	status := PayDetailsPushStatus{Stage: PayDetailsPushStageSending, PayslipID: input.PayslipID}
+	err := workflow.SetQueryHandler(ctx, PayDetailsPushStatusQuery, func() (PayDetailsPushStatus, error) {
+		return status, nil
+	})
+	if err != nil {
+		return err
+	}
+
 	// Most actions should always complete. We're creating infinite-retry here.
 	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:           TaskQueueDocuments,
 		StartToCloseTimeout: 10 * time.Second,
 	})
 
-	// I'm not sure if this shouldn't be an action as well?
-	// If we wanted data to be re-fetched on retry, it would have to be under `PushPayDetailsToBob`.
-	// Since this is passed to each activity below, pay details will be recorded within temporal. This has its issues:
-	// - PII could end up in temporal
-	// - If input (payDetails) is big enough, this could affect performance of temporal.
-	payDetails, err := pullData(input.CompanyID, input.PayslipID)
-	if err != nil {
-		return err
+	// Activities fetch pay details on their own, so PII stays out of history, and retries get fresh data.
+	var payDetails interface{} = PayDetailsRef(input)
+	if workflow.GetVersion(ctx, payDetailsByReferenceChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		// Executions started before the change carry on as they started. Activities still accept whole pay details,
+		// as they decode into PayDetailsRef. Remove once such executions are past retention.
+		payDetails = legacyPayDetails(input)
 	}
+	var a *Activities
 
 	// User would like to know that we're trying to send something.
-	err = workflow.ExecuteActivity(ctx, MarkPayDetailsAsBeingSent, payDetails).Get(ctx, nil)
+	err = workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsBeingSent, payDetails).Get(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -43,19 +117,128 @@ func PushPayDetails(ctx workflow.Context, input PushPayDetailsInput) error {
 		MaximumInterval:    time.Second * 100,
 		MaximumAttempts:    5,
 	})
-	err = workflow.ExecuteActivity(limitedRetryCtx, PushPayDetailsToBob, payDetails).Get(limitedRetryCtx, nil)
-	if err != nil {
-		// No dice. We'll mark entire workflow as failed.
-		_ = workflow.ExecuteActivity(ctx, MarkPayDetailsAsFailed, payDetails).Get(ctx, nil)
-		return err
+	for {
+		err = workflow.ExecuteActivity(limitedRetryCtx, a.PushPayDetailsToBob, payDetails).Get(limitedRetryCtx, nil)
+		if err == nil {
+			break
+		}
+		workflow.GetLogger(ctx).Error("Unable to push pay details to Bob", "Error", err)
+		workflow.GetMetricsHandler(ctx).Counter(MetricPayDetailsPushFailures).Inc(1)
+
+		if workflow.GetVersion(ctx, operatorResolutionChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+			// No dice. We'll mark entire workflow as failed.
+			_ = workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsFailed, payDetails).Get(ctx, nil)
+			return err
+		}
+
+		status.Stage = PayDetailsPushStageNeedsAttention
+		status.LastError = err.Error()
+		status.NeedsAttentionSince = workflow.Now(ctx)
+		decision, err := awaitOperator(ctx, &status, PayDetailsRef{CompanyID: input.CompanyID, PayslipID: status.PayslipID})
+		if err != nil {
+			return err
+		}
+		switch {
+		case decision.abandon != nil:
+			workflow.GetLogger(ctx).Warn("Push abandoned by operator", "Reason", decision.abandon.Reason)
+			status.Stage = PayDetailsPushStageAbandoned
+			if workflow.GetVersion(ctx, abandonedPushChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				_ = workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsFailed, payDetails).Get(ctx, nil)
+				return temporal.NewNonRetryableApplicationError("push abandoned by operator: "+decision.abandon.Reason, "Abandoned", nil)
+			}
+			// Operator decided, nothing went wrong. Delivery tells what happened to the payslip.
+			return workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsAbandoned, payDetails, decision.abandon.Reason).Get(ctx, nil)
+		case decision.newData != nil:
+			superseded := payDetails
+			status.PayslipID = decision.newData.PayslipID
+			payDetails = PayDetailsRef{CompanyID: input.CompanyID, PayslipID: decision.newData.PayslipID}
+			if workflow.GetVersion(ctx, supersededPayDetailsChange, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+				if err := workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsSuperseded, superseded).Get(ctx, nil); err != nil {
+					return err
+				}
+				if err := workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsBeingSent, payDetails).Get(ctx, nil); err != nil {
+					return err
+				}
+			}
+		}
+		status.Stage = PayDetailsPushStageSending
 	}
 
 	// Success!
-	_ = workflow.ExecuteActivity(ctx, MarkPayDetailsAsSent, payDetails).Get(ctx, nil)
+	status.Stage = PayDetailsPushStageSent
+	_ = workflow.ExecuteActivity(ctx, a.MarkPayDetailsAsSent, payDetails).Get(ctx, nil)
 
 	return nil
 }
 
+// operatorDecision is retry, unless one of the fields is set.
+type operatorDecision struct {
+	newData *RetryWithNewData
+	abandon *Abandon
+}
+
+// awaitOperator parks the workflow until an operator signals what to do. If nobody does for too long, push is
+// escalated, and keeps waiting.
+func awaitOperator(ctx workflow.Context, status *PayDetailsPushStatus, ref PayDetailsRef) (operatorDecision, error) {
+	// Signals sent while push was still in progress were not a reaction to this failure.
+	for _, signal := range []string{RetrySignal, RetryWithNewDataSignal, AbandonSignal} {
+		for workflow.GetSignalChannel(ctx, signal).ReceiveAsync(nil) {
+			workflow.GetLogger(ctx).Warn("Ignoring signal sent before push needed attention", "Signal", signal)
+		}
+	}
+	retry := workflow.GetSignalChannel(ctx, RetrySignal)
+	retryWithNewData := workflow.GetSignalChannel(ctx, RetryWithNewDataSignal)
+	abandon := workflow.GetSignalChannel(ctx, AbandonSignal)
+
+	if err := workflow.UpsertTypedSearchAttributes(ctx, NeedsAttention.ValueSet(true)); err != nil {
+		return operatorDecision{}, err
+	}
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	defer cancelTimer()
+
+	var decision operatorDecision
+	decided := false
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(retry, func(c workflow.ReceiveChannel, _ bool) {
+		c.Receive(ctx, nil)
+		decided = true
+	})
+	selector.AddReceive(retryWithNewData, func(c workflow.ReceiveChannel, _ bool) {
+		var newData RetryWithNewData
+		c.Receive(ctx, &newData)
+		decision.newData = &newData
+		decided = true
+	})
+	selector.AddReceive(abandon, func(c workflow.ReceiveChannel, _ bool) {
+		var reason Abandon
+		c.Receive(ctx, &reason)
+		decision.abandon = &reason
+		decided = true
+	})
+	selector.AddFuture(workflow.NewTimer(timerCtx, payDetailsEscalationTimeout), func(f workflow.Future) {
+		if f.Get(ctx, nil) != nil {
+			return
+		}
+		status.Stage = PayDetailsPushStageEscalated
+		workflow.GetMetricsHandler(ctx).Counter(MetricPayDetailsPushEscalations).Inc(1)
+		var a *Activities
+		if err := workflow.ExecuteActivity(ctx, a.EscalatePayDetailsPush, ref).Get(ctx, nil); err != nil {
+			workflow.GetLogger(ctx).Error("Unable to escalate push", "Error", err)
+		}
+	})
+	for !decided {
+		selector.Select(ctx)
+	}
+
+	return decision, workflow.UpsertTypedSearchAttributes(ctx, NeedsAttention.ValueSet(false))
+}
+
+// PayDetailsRef is all activities get. Anything else they fetch from PayDetailsRepository.
+type PayDetailsRef struct {
+	CompanyID string
+	PayslipID string
+}
+
 type PayDetails struct {
 	CompanyID string
 	PayslipID string
@@ -65,34 +248,103 @@ type PayDetails struct {
 	Salary    int
 }
 
-func pullData(companyID, payslipID string) (PayDetails, error) {
-	fmt.Println("fetching data...")
+// legacyPayDetails is what workflow used to fetch on its own, before the payDetailsByReferenceChange.
+func legacyPayDetails(input PushPayDetailsInput) PayDetails {
 	return PayDetails{
-		CompanyID: companyID,
-		PayslipID: payslipID,
+		CompanyID: input.CompanyID,
+		PayslipID: input.PayslipID,
 		Payday:    time.Time{},
 		FirstName: "Joe",
 		LastName:  "Smith",
 		Salary:    10_000_00,
-	}, nil
+	}
+}
+
+var ErrPayDetailsNotFound = errors.New("pay details not found")
+
+type PayDetailsRepository interface {
+	// Get returns ErrPayDetailsNotFound if payslip doesn't exist.
+	Get(ctx context.Context, ref PayDetailsRef) (PayDetails, error)
+}
+
+// FakePayDetailsRepository has the same employee on every payslip, until there is a real payroll to fetch from.
+type FakePayDetailsRepository struct{}
+
+func (FakePayDetailsRepository) Get(_ context.Context, ref PayDetailsRef) (PayDetails, error) {
+	return legacyPayDetails(PushPayDetailsInput(ref)), nil
+}
+
+func (a *Activities) PushPayDetailsToBob(ctx context.Context, ref PayDetailsRef) error {
+	err := a.pushPayDetailsToBob(ctx, ref)
+	if err != nil {
+		// Losing a failed attempt from delivery status is better than pushing pay details again because of it.
+		if recordErr := a.recordDelivery(ctx, ref, delivery.StateAttemptFailed, err.Error()); recordErr != nil {
+			activity.GetLogger(ctx).Warn("Unable to record failed attempt", "Error", recordErr)
+		}
+	}
+	return err
+}
+
+func (a *Activities) pushPayDetailsToBob(ctx context.Context, ref PayDetailsRef) error {
+	payDetails, err := a.PayDetails.Get(ctx, ref)
+	if errors.Is(err, ErrPayDetailsNotFound) {
+		// Retrying won't make payslip appear.
+		return temporal.NewNonRetryableApplicationError(err.Error(), "PayDetailsNotFound", err)
+	}
+	if err != nil {
+		return fmt.Errorf("fetching pay details: %w", err)
+	}
+	return bobError(a.Bob.PushPayDetails(ctx, bob.PayDetails{
+		CompanyID: payDetails.CompanyID,
+		PayslipID: payDetails.PayslipID,
+		Payday:    payDetails.Payday,
+		FirstName: payDetails.FirstName,
+		LastName:  payDetails.LastName,
+		Salary:    payDetails.Salary,
+	}))
+}
+
+func (a *Activities) MarkPayDetailsAsBeingSent(ctx context.Context, ref PayDetailsRef) error {
+	activity.GetLogger(ctx).Info("Trying to send pay details")
+	return a.recordDelivery(ctx, ref, delivery.StateSending, "")
+}
+
+func (a *Activities) MarkPayDetailsAsFailed(ctx context.Context, ref PayDetailsRef) error {
+	activity.GetLogger(ctx).Warn("Pay details failed")
+	return a.recordDelivery(ctx, ref, delivery.StateFailed, "")
 }
 
-func PushPayDetailsToBob(ctx context.Context, payDetails PayDetails) error {
-	time.Sleep(time.Second)
-	return failXOutOf10Times(3)
+// MarkPayDetailsAsSuperseded is recorded when an operator retries push with pay details of another payslip.
+func (a *Activities) MarkPayDetailsAsSuperseded(ctx context.Context, ref PayDetailsRef) error {
+	activity.GetLogger(ctx).Info("Pay details superseded by another payslip")
+	return a.recordDelivery(ctx, ref, delivery.StateSuperseded, "")
 }
 
-func MarkPayDetailsAsBeingSent(ctx context.Context, payDetails PayDetails) error {
-	fmt.Println("Trying to send pay details")
+// MarkPayDetailsAsAbandoned is recorded when an operator gives up on the push.
+func (a *Activities) MarkPayDetailsAsAbandoned(ctx context.Context, ref PayDetailsRef, reason string) error {
+	activity.GetLogger(ctx).Warn("Pay details abandoned", "Reason", reason)
+	transition := delivery.Transition{State: delivery.StateAbandoned, At: a.now(), Reason: reason}
+	if err := a.Deliveries.Record(ctx, delivery.Ref(ref), transition); err != nil {
+		return fmt.Errorf("recording delivery: %w", err)
+	}
 	return nil
 }
 
-func MarkPayDetailsAsFailed(ctx context.Context, payDetails PayDetails) error {
-	fmt.Println("Pay details failed")
+// EscalatePayDetailsPush is where someone gets paged, once there is someone to page.
+func (a *Activities) EscalatePayDetailsPush(ctx context.Context, ref PayDetailsRef) error {
+	activity.GetLogger(ctx).Error("Pay details push needs attention for too long")
 	return nil
 }
 
-func MarkPayDetailsAsSent(ctx context.Context, payDetails PayDetails) error {
-	fmt.Println("Pay details sent")
+func (a *Activities) MarkPayDetailsAsSent(ctx context.Context, ref PayDetailsRef) error {
+	activity.GetLogger(ctx).Info("Pay details sent")
+	return a.recordDelivery(ctx, ref, delivery.StateSent, "")
+}
+
+func (a *Activities) recordDelivery(ctx context.Context, ref PayDetailsRef, state delivery.State, errorMessage string) error {
+	transition := delivery.Transition{State: state, At: a.now(), Error: errorMessage}
+	if err := a.Deliveries.Record(ctx, delivery.Ref(ref), transition); err != nil {
+		return fmt.Errorf("recording delivery: %w", err)
+	}
 	return nil
 }
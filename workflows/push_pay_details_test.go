@@ -0,0 +1,235 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"temporal-poc/delivery"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type PushPayDetailsTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestPushPayDetails(t *testing.T) {
+	suite.Run(t, new(PushPayDetailsTestSuite))
+}
+
+func (s *PushPayDetailsTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+func (s *PushPayDetailsTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+var (
+	pushPayDetailsInput = PushPayDetailsInput{CompanyID: "company-1", PayslipID: "payslip-1"}
+	payDetailsRef       = PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-1"}
+	a                   *Activities
+)
+
+func (s *PushPayDetailsTestSuite) Test_PushesPayDetails() {
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, payDetailsRef).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertNotCalled(s.T(), "MarkPayDetailsAsFailed", mock.Anything, mock.Anything)
+}
+
+func (s *PushPayDetailsTestSuite) Test_MarksAsFailed_WhenRetriesAreExhausted_BeforeOperatorResolution() {
+	s.env.OnGetVersion(operatorResolutionChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, mock.Anything).Return(errors.New("bob is down"))
+	s.env.OnActivity(a.MarkPayDetailsAsFailed, mock.Anything, mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "bob is down")
+	s.env.AssertNumberOfCalls(s.T(), "PushPayDetailsToBob", 5)
+	s.env.AssertNotCalled(s.T(), "MarkPayDetailsAsSent", mock.Anything, mock.Anything)
+}
+
+func (s *PushPayDetailsTestSuite) Test_SucceedsAfterRetry() {
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, mock.Anything).Return(errors.New("bob is down")).Twice()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertNotCalled(s.T(), "MarkPayDetailsAsFailed", mock.Anything, mock.Anything)
+}
+
+// Executions started before the change pass whole pay details. Activities have to accept them as references.
+func (s *PushPayDetailsTestSuite) Test_ActivitiesAcceptWholePayDetails_FromExecutionsStartedBeforeReferences() {
+	s.env.OnGetVersion(payDetailsByReferenceChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, payDetailsRef).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *PushPayDetailsTestSuite) Test_FailsWithoutRetries_WhenPayslipDoesNotExist_BeforeOperatorResolution() {
+	s.env.OnGetVersion(operatorResolutionChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.env.RegisterActivity(&Activities{PayDetails: missingPayDetails{}, Deliveries: delivery.NewMemoryStore()})
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsFailed, mock.Anything, mock.Anything).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "pay details not found")
+}
+
+// failPushes makes the first push exhaust its attempts, and the following ones succeed.
+func (s *PushPayDetailsTestSuite) failPushes() {
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).Return(errors.New("bob is down")).Times(5)
+}
+
+// operatorSignals after push needed attention for a while.
+func (s *PushPayDetailsTestSuite) operatorSignals(delay time.Duration, signal string, arg interface{}) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(signal, arg)
+	}, delay)
+}
+
+func (s *PushPayDetailsTestSuite) status() PayDetailsPushStatus {
+	value, err := s.env.QueryWorkflow(PayDetailsPushStatusQuery)
+	s.Require().NoError(err)
+	var status PayDetailsPushStatus
+	s.Require().NoError(value.Get(&status))
+	return status
+}
+
+func (s *PushPayDetailsTestSuite) Test_WaitsForOperator_AndRetries() {
+	s.failPushes()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		status := s.status()
+		s.Equal(PayDetailsPushStageNeedsAttention, status.Stage)
+		s.Contains(status.LastError, "bob is down")
+	}, time.Hour)
+	s.operatorSignals(2*time.Hour, RetrySignal, nil)
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(PayDetailsPushStageSent, s.status().Stage)
+	s.env.AssertNotCalled(s.T(), "MarkPayDetailsAsFailed", mock.Anything, mock.Anything)
+}
+
+func (s *PushPayDetailsTestSuite) Test_WaitsForOperator_AndRetriesWithNewData() {
+	corrected := PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-2"}
+	s.failPushes()
+	var recorded []PayDetailsRef
+	s.env.OnActivity(a.MarkPayDetailsAsSuperseded, mock.Anything, payDetailsRef).
+		Run(func(mock.Arguments) { recorded = append(recorded, payDetailsRef) }).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, corrected).
+		Run(func(mock.Arguments) { recorded = append(recorded, corrected) }).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, corrected).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, corrected).Return(nil).Once()
+	s.operatorSignals(time.Hour, RetryWithNewDataSignal, RetryWithNewData{PayslipID: "payslip-2"})
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal("payslip-2", s.status().PayslipID)
+	s.Equal([]PayDetailsRef{payDetailsRef, corrected}, recorded)
+}
+
+func (s *PushPayDetailsTestSuite) Test_RetriesWithNewData_WithoutRecordingIt_BeforeSupersededPayDetails() {
+	corrected := PayDetailsRef{CompanyID: "company-1", PayslipID: "payslip-2"}
+	s.env.OnGetVersion(supersededPayDetailsChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.failPushes()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, corrected).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, corrected).Return(nil).Once()
+	s.operatorSignals(time.Hour, RetryWithNewDataSignal, RetryWithNewData{PayslipID: "payslip-2"})
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertNotCalled(s.T(), "MarkPayDetailsAsSuperseded", mock.Anything, mock.Anything)
+}
+
+func (s *PushPayDetailsTestSuite) Test_WaitsForOperator_AndAbandons() {
+	s.failPushes()
+	s.env.OnActivity(a.MarkPayDetailsAsAbandoned, mock.Anything, payDetailsRef, "employee left").Return(nil).Once()
+	s.operatorSignals(time.Hour, AbandonSignal, Abandon{Reason: "employee left"})
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(PayDetailsPushStageAbandoned, s.status().Stage)
+	s.env.AssertNotCalled(s.T(), "MarkPayDetailsAsFailed", mock.Anything, mock.Anything)
+}
+
+func (s *PushPayDetailsTestSuite) Test_FailsAbandonedPush_StartedBeforeItCompletedInstead() {
+	s.env.OnGetVersion(abandonedPushChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	s.failPushes()
+	s.env.OnActivity(a.MarkPayDetailsAsFailed, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.operatorSignals(time.Hour, AbandonSignal, Abandon{Reason: "employee left"})
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.ErrorContains(s.env.GetWorkflowError(), "employee left")
+}
+
+func (s *PushPayDetailsTestSuite) Test_Escalates_WhenNobodyReacts() {
+	s.failPushes()
+	s.env.OnActivity(a.EscalatePayDetailsPush, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		s.Equal(PayDetailsPushStageEscalated, s.status().Stage)
+	}, payDetailsEscalationTimeout+time.Hour)
+	s.operatorSignals(payDetailsEscalationTimeout+2*time.Hour, RetrySignal, nil)
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *PushPayDetailsTestSuite) Test_IgnoresSignals_SentBeforePushNeededAttention() {
+	s.env.OnActivity(a.MarkPayDetailsAsBeingSent, mock.Anything, mock.Anything).Return(nil).Once()
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).After(time.Minute).Return(errors.New("bob is down")).Times(5)
+	s.env.OnActivity(a.PushPayDetailsToBob, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.env.OnActivity(a.MarkPayDetailsAsSent, mock.Anything, payDetailsRef).Return(nil).Once()
+	s.operatorSignals(0, AbandonSignal, Abandon{Reason: "too early"})
+	s.operatorSignals(time.Hour, RetrySignal, nil)
+
+	s.env.ExecuteWorkflow(PushPayDetails, pushPayDetailsInput)
+
+	s.NoError(s.env.GetWorkflowError())
+}
+
+type missingPayDetails struct{}
+
+func (missingPayDetails) Get(context.Context, PayDetailsRef) (PayDetails, error) {
+	return PayDetails{}, ErrPayDetailsNotFound
+}
@@ -0,0 +1,13 @@
+package workflows
+
+// Each part of the system gets its own task queue. This way slow HMRC calls or a burst of payments can't starve
+// Bob sync, and each queue can be tuned (or scaled) separately.
+const (
+	TaskQueueBobSync  = "bob-sync"
+	TaskQueuePayroll  = "payroll"
+	TaskQueuePayments = "payments"
+	// TaskQueueDocuments delivers documents and pay details to employees and Bob.
+	TaskQueueDocuments = "documents"
+)
+
+var AllTaskQueues = []string{TaskQueueBobSync, TaskQueuePayroll, TaskQueuePayments, TaskQueueDocuments}
@@ -0,0 +1,55 @@
+package workflows
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/worker"
+)
+
+// historiesDir has histories recorded from real executions, see `temporal-poc histories download`.
+const historiesDir = "../testdata/histories"
+
+// TestReplayHistories catches non-deterministic changes. Every recorded history has to replay against current code,
+// as the same happens to executions in flight during deploy.
+func TestReplayHistories(t *testing.T) {
+	workflows := map[string]interface{}{
+		"SyncDataFromBob":       SyncDataFromBob,
+		"SyncCompanyFromBob":    SyncCompanyFromBob,
+		"EmployeeChanges":       EmployeeChanges,
+		"ProcessPayroll":        ProcessPayroll,
+		"AwaitFPSResponse":      AwaitFPSResponse,
+		"ProcessPayments":       ProcessPayments,
+		"PushPayDetails":        PushPayDetails,
+		"PushCompanyPayDetails": PushCompanyPayDetails,
+	}
+
+	for name := range workflows {
+		files, err := filepath.Glob(filepath.Join(historiesDir, name, "*.json"))
+		require.NoError(t, err)
+		if len(files) == 0 {
+			t.Errorf("No recorded histories of %s in %s", name, historiesDir)
+		}
+
+		for _, file := range files {
+			t.Run(name+"/"+filepath.Base(file), func(t *testing.T) {
+				replayer := worker.NewWorkflowReplayer()
+				for _, wf := range workflows {
+					replayer.RegisterWorkflow(wf)
+				}
+				require.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(nil, file))
+			})
+		}
+	}
+
+	// Histories of removed workflows can't be replayed, remove them too.
+	dirs, err := os.ReadDir(historiesDir)
+	require.NoError(t, err)
+	for _, dir := range dirs {
+		if _, ok := workflows[dir.Name()]; !ok {
+			t.Errorf("Histories of unknown workflow in %s", filepath.Join(historiesDir, dir.Name()))
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package workflows
+
+import (
+	"go.temporal.io/sdk/temporal"
+)
+
+// NeedsAttention is set on workflows waiting for an operator.
+var NeedsAttention = temporal.NewSearchAttributeKeyBool("NeedsAttention")
+
+// FPSErrorCodes are codes of business errors HMRC found in FPS of a payroll awaiting correction.
+var FPSErrorCodes = temporal.NewSearchAttributeKeyKeywordList("FPSErrorCodes")
+
+// SearchAttributes are custom search attributes workflows set. Worker registers missing ones on start.
+var SearchAttributes = []temporal.SearchAttributeKey{NeedsAttention, FPSErrorCodes}
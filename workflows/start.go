@@ -0,0 +1,97 @@
+package workflows
+
+import (
+	"fmt"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+)
+
+// Workflow IDs are derived from business IDs. This way, starting the same thing twice can't create a duplicate,
+// and anyone can find a workflow knowing only payroll or payslip.
+
+const (
+	SyncDataFromBobScheduleID   = "sync-data-from-bob-every-minute"
+	FullResyncFromBobWorkflowID = "full-resync-from-bob"
+)
+
+func ProcessPayrollWorkflowID(payrollID string) string {
+	return fmt.Sprintf("process-payroll-%s", payrollID)
+}
+
+// AwaitFPSResponseWorkflowID is per submission, as the same payroll can be reported more than once.
+func AwaitFPSResponseWorkflowID(payrollID, correlationID string) string {
+	return fmt.Sprintf("await-fps-response-%s-%s", payrollID, correlationID)
+}
+
+func PushCompanyPayDetailsWorkflowID(companyID string) string {
+	return fmt.Sprintf("push-company-pay-details-%s", companyID)
+}
+
+func SyncCompanyFromBobWorkflowID(companyID string) string {
+	return fmt.Sprintf("sync-company-from-bob-%s", companyID)
+}
+
+func EmployeeChangesWorkflowID(employeeID string) string {
+	return fmt.Sprintf("employee-changes-%s", employeeID)
+}
+
+func PushPayDetailsWorkflowID(input PushPayDetailsInput) string {
+	return fmt.Sprintf("push-pay-details-%s-%s", input.CompanyID, input.PayslipID)
+}
+
+// ProcessPayrollOptions allows re-running payroll only if previous attempt failed. We don't want to pay anyone twice.
+func ProcessPayrollOptions(payrollID string) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    ProcessPayrollWorkflowID(payrollID),
+		TaskQueue:             TaskQueuePayroll,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY,
+	}
+}
+
+// PushPayDetailsOptions replaces any push still in progress. Latest pay details are the only ones that matter.
+func PushPayDetailsOptions(input PushPayDetailsInput) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    PushPayDetailsWorkflowID(input),
+		TaskQueue:             TaskQueueDocuments,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_TERMINATE_IF_RUNNING,
+	}
+}
+
+// FullResyncFromBobOptions runs at most one full resync at a time. Scheduled syncs carry on alongside it, with their
+// own watermark.
+func FullResyncFromBobOptions() client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    FullResyncFromBobWorkflowID,
+		TaskQueue:             TaskQueueBobSync,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+	}
+}
+
+// SyncCompanyFromBobOptions are meant for SignalWithStartWorkflow. Signal goes to the running sync of the company, or
+// starts a new one.
+func SyncCompanyFromBobOptions(companyID string) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    SyncCompanyFromBobWorkflowID(companyID),
+		TaskQueue:             TaskQueueBobSync,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+	}
+}
+
+// EmployeeChangesOptions are meant for SignalWithStartWorkflow, same as SyncCompanyFromBobOptions.
+func EmployeeChangesOptions(employeeID string) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    EmployeeChangesWorkflowID(employeeID),
+		TaskQueue:             TaskQueueBobSync,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+	}
+}
+
+// PushCompanyPayDetailsOptions are meant for SignalWithStartWorkflow, same as SyncCompanyFromBobOptions.
+func PushCompanyPayDetailsOptions(companyID string) client.StartWorkflowOptions {
+	return client.StartWorkflowOptions{
+		ID:                    PushCompanyPayDetailsWorkflowID(companyID),
+		TaskQueue:             TaskQueueDocuments,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+	}
+}
@@ -0,0 +1,114 @@
+package workflows
+
+import (
+	"time"
+
+	"temporal-poc/employees"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// EmployeeChangedSignal tells SyncCompanyFromBob that Bob changed an employee. Send it with SignalWithStartWorkflow and
+// SyncCompanyFromBobOptions, so workflow is started when it's not running.
+const EmployeeChangedSignal = "bob-employee-changed"
+
+type EmployeeChanged struct {
+	EmployeeID string
+	ChangedAt  time.Time
+}
+
+type SyncCompanyFromBobInput struct {
+	CompanyID string
+	// Changes received but not synced yet, carried over by continue-as-new. PendingSince is the earliest of them.
+	Pending      int
+	PendingSince time.Time
+}
+
+const (
+	// coalesceQuietPeriod waits for more changes after the last one. Bulk edits in Bob send a burst of them.
+	coalesceQuietPeriod = 10 * time.Second
+	// coalesceMaxDelay limits how long a steady stream of changes can postpone sync.
+	coalesceMaxDelay = time.Minute
+	// companySyncIdleTimeout ends the workflow when nothing changes for a while. Next change starts it again.
+	companySyncIdleTimeout = time.Hour
+	// companySyncsPerRun keeps history of busy companies short.
+	companySyncsPerRun = 100
+)
+
+// SyncCompanyFromBob syncs employees of a company as soon as Bob tells us they changed. Changes are coalesced, so a
+// burst of them ends up as a single sync of everything changed since the earliest one.
+func SyncCompanyFromBob(ctx workflow.Context, input SyncCompanyFromBobInput) error {
+	activityOptions := workflow.ActivityOptions{
+		TaskQueue:           TaskQueueBobSync,
+		StartToCloseTimeout: 10 * time.Second,
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+	logger := workflow.GetLogger(ctx)
+
+	changes := workflow.GetSignalChannel(ctx, EmployeeChangedSignal)
+	pending, pendingSince := input.Pending, input.PendingSince
+	receive := func(change EmployeeChanged) {
+		if pending == 0 || change.ChangedAt.Before(pendingSince) {
+			pendingSince = change.ChangedAt
+		}
+		pending++
+	}
+
+	for syncs := 0; ; syncs++ {
+		if pending == 0 {
+			var change EmployeeChanged
+			if ok, _ := changes.ReceiveWithTimeout(ctx, companySyncIdleTimeout, &change); !ok {
+				// Change could have come right after the timer fired. Completing now would lose it.
+				if !changes.ReceiveAsync(&change) {
+					logger.Info("No changes for a while, stopping")
+					return nil
+				}
+			}
+			receive(change)
+		}
+
+		deadline := workflow.Now(ctx).Add(coalesceMaxDelay)
+		for wait := coalesceQuietPeriod; wait > 0; wait = min(coalesceQuietPeriod, deadline.Sub(workflow.Now(ctx))) {
+			var change EmployeeChanged
+			if ok, _ := changes.ReceiveWithTimeout(ctx, wait, &change); !ok {
+				break
+			}
+			receive(change)
+		}
+
+		summary, err := syncEmployeesChangedSince(ctx, input.CompanyID, pendingSince.Add(-watermarkOverlap))
+		if err != nil {
+			return err
+		}
+		logger.Info("Synced changed employees from Bob", "Changes", pending, "Created", summary.Created,
+			"Updated", summary.Updated, "Unchanged", summary.Unchanged)
+		pending = 0
+
+		if syncs+1 >= companySyncsPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			var change EmployeeChanged
+			for changes.ReceiveAsync(&change) {
+				receive(change)
+			}
+			input.Pending, input.PendingSince = pending, pendingSince
+			return workflow.NewContinueAsNewError(ctx, SyncCompanyFromBob, input)
+		}
+	}
+}
+
+// syncEmployeesChangedSince pages through all of them in the company. Unlike scheduled sync, there are only a few.
+func syncEmployeesChangedSince(ctx workflow.Context, companyID string, since time.Time) (employees.Summary, error) {
+	var a *Activities
+	var summary employees.Summary
+	req := SyncEmployeesPageRequest{CompanyID: companyID, UpdatedSince: since, Limit: syncPageSize}
+	for {
+		var result SyncEmployeesPageResult
+		if err := workflow.ExecuteActivity(ctx, a.SyncEmployeesPage, req).Get(ctx, &result); err != nil {
+			return summary, err
+		}
+		summary = summary.Add(result.Summary)
+		if result.NextCursor == "" {
+			return summary, nil
+		}
+		req.Cursor = result.NextCursor
+	}
+}
@@ -0,0 +1,100 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"temporal-poc/employees"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type SyncCompanyFromBobTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestSyncCompanyFromBob(t *testing.T) {
+	suite.Run(t, new(SyncCompanyFromBobTestSuite))
+}
+
+func (s *SyncCompanyFromBobTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+func (s *SyncCompanyFromBobTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+var changedAt = time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+
+func (s *SyncCompanyFromBobTestSuite) signalAfter(delay time.Duration, change EmployeeChanged) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(EmployeeChangedSignal, change)
+	}, delay)
+}
+
+func (s *SyncCompanyFromBobTestSuite) Test_CoalescesBurstOfChanges_IntoSingleSync() {
+	s.signalAfter(0, EmployeeChanged{EmployeeID: "employee-1", ChangedAt: changedAt})
+	s.signalAfter(5*time.Second, EmployeeChanged{EmployeeID: "employee-2", ChangedAt: changedAt.Add(-time.Minute)})
+	s.signalAfter(12*time.Second, EmployeeChanged{EmployeeID: "employee-3", ChangedAt: changedAt.Add(time.Minute)})
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{CompanyID: "42", UpdatedSince: changedAt.Add(-time.Minute - watermarkOverlap), Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{NextCursor: "2", Summary: employees.Summary{Updated: 2}}, nil).Once()
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{CompanyID: "42", UpdatedSince: changedAt.Add(-time.Minute - watermarkOverlap), Cursor: "2", Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{Summary: employees.Summary{Updated: 1}}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncCompanyFromBob, SyncCompanyFromBobInput{CompanyID: "42"})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *SyncCompanyFromBobTestSuite) Test_SyncsAfterMaxDelay_WhenChangesKeepComing() {
+	for i := range 10 {
+		s.signalAfter(time.Duration(i)*8*time.Second, EmployeeChanged{EmployeeID: "employee-1", ChangedAt: changedAt.Add(time.Duration(i) * time.Second)})
+	}
+	var syncedAt []time.Time
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { syncedAt = append(syncedAt, s.env.Now()) }).
+		Return(SyncEmployeesPageResult{}, nil).Twice()
+
+	start := s.env.Now()
+	s.env.ExecuteWorkflow(SyncCompanyFromBob, SyncCompanyFromBobInput{CompanyID: "42"})
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Require().Len(syncedAt, 2)
+	s.LessOrEqual(syncedAt[0].Sub(start), coalesceMaxDelay+time.Second)
+}
+
+func (s *SyncCompanyFromBobTestSuite) Test_Stops_WhenIdle() {
+	s.env.ExecuteWorkflow(SyncCompanyFromBob, SyncCompanyFromBobInput{CompanyID: "42"})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *SyncCompanyFromBobTestSuite) Test_SyncsPendingChanges_AfterContinueAsNew() {
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{CompanyID: "42", UpdatedSince: changedAt.Add(-watermarkOverlap), Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncCompanyFromBob, SyncCompanyFromBobInput{CompanyID: "42", Pending: 3, PendingSince: changedAt})
+
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *SyncCompanyFromBobTestSuite) Test_ContinuesAsNew_AfterManySyncs() {
+	for i := range companySyncsPerRun {
+		s.signalAfter(time.Duration(i)*time.Hour/2, EmployeeChanged{EmployeeID: "employee-1", ChangedAt: changedAt})
+	}
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, mock.Anything).Return(SyncEmployeesPageResult{}, nil)
+
+	s.env.ExecuteWorkflow(SyncCompanyFromBob, SyncCompanyFromBobInput{CompanyID: "42"})
+
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+	s.env.AssertNumberOfCalls(s.T(), "SyncEmployeesPage", companySyncsPerRun)
+}
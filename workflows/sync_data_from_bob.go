@@ -2,47 +2,202 @@ package workflows
 
 import (
 	"context"
-	"fmt"
+	"slices"
 	"time"
 
+	"temporal-poc/bob"
+	"temporal-poc/employees"
+
+	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/workflow"
 )
 
-// SyncDataFromBob is just an example. It probably is not a good idea to break sync into two activities, as all
-// of this input/output data would be stored in Temporal's database.
-func SyncDataFromBob(ctx workflow.Context) error {
+// incrementalSyncChange syncs only employees changed since the previous run, page by page. Employees no longer pass
+// through history.
+const incrementalSyncChange = "incremental-sync"
+
+const (
+	// syncPageSize employees are fetched and stored by a single activity.
+	syncPageSize = 100
+	// syncPagesPerRun keeps history of big tenants short. After this many pages, sync continues as new.
+	syncPagesPerRun = 50
+	// watermarkOverlap re-syncs employees changed around the previous watermark. Some of them could have been changed
+	// while previous run was paging through them. Storing the same employee twice is harmless, missing one is not.
+	watermarkOverlap = 5 * time.Minute
+)
+
+type SyncDataFromBobInput struct {
+	// FullResync syncs every employee, regardless of the watermark.
+	FullResync bool
+	// Rebuild refills employees worker kept in memory and lost on restart. They are new to the store, but not to
+	// payroll, so NewStarter events are not published. Set with FullResync.
+	Rebuild bool
+	// The rest is carried over by continue-as-new. Scheduled runs start with zero values.
+	UpdatedSince time.Time
+	Cursor       string
+	Watermark    time.Time
+	Summary      employees.Summary
+}
+
+type SyncDataFromBobResult struct {
+	// Watermark is the latest update seen in Bob. Next scheduled run gets it as its last completion result.
+	Watermark time.Time
+	Summary   employees.Summary
+}
+
+// SyncDataFromBob is started by schedule every minute. Watermark is carried between runs as the last completion
+// result, so if schedule gets recreated, the next run is simply a full sync.
+func SyncDataFromBob(ctx workflow.Context, input SyncDataFromBobInput) (SyncDataFromBobResult, error) {
 	activityOptions := workflow.ActivityOptions{
+		TaskQueue:           TaskQueueBobSync,
 		StartToCloseTimeout: 10 * time.Second,
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+	var a *Activities
+
+	if workflow.GetVersion(ctx, incrementalSyncChange, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return SyncDataFromBobResult{}, legacySyncDataFromBob(ctx)
+	}
 
+	// First run of a sync. Runs that continued as new already know where they are.
+	if input.Cursor == "" && !input.FullResync && workflow.HasLastCompletionResult(ctx) {
+		var last SyncDataFromBobResult
+		if err := workflow.GetLastCompletionResult(ctx, &last); err != nil {
+			return SyncDataFromBobResult{}, err
+		}
+		input.Watermark = last.Watermark
+		if !last.Watermark.IsZero() {
+			input.UpdatedSince = last.Watermark.Add(-watermarkOverlap)
+		}
+	}
+
+	for page := 1; ; page++ {
+		var result SyncEmployeesPageResult
+		err := workflow.ExecuteActivity(ctx, a.SyncEmployeesPage, SyncEmployeesPageRequest{
+			UpdatedSince: input.UpdatedSince,
+			Cursor:       input.Cursor,
+			Limit:        syncPageSize,
+			Rebuild:      input.Rebuild,
+		}).Get(ctx, &result)
+		if err != nil {
+			return SyncDataFromBobResult{}, err
+		}
+		input.Summary = input.Summary.Add(result.Summary)
+		if result.Watermark.After(input.Watermark) {
+			input.Watermark = result.Watermark
+		}
+		if result.NextCursor == "" {
+			workflow.GetLogger(ctx).Info("Synced employees from Bob", "Created", input.Summary.Created,
+				"Updated", input.Summary.Updated, "Unchanged", input.Summary.Unchanged, "FullResync", input.FullResync)
+			return SyncDataFromBobResult{Watermark: input.Watermark, Summary: input.Summary}, nil
+		}
+		input.Cursor = result.NextCursor
+
+		if page >= syncPagesPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			return SyncDataFromBobResult{}, workflow.NewContinueAsNewError(ctx, SyncDataFromBob, input)
+		}
+	}
+}
+
+// legacySyncDataFromBob is how runs started before incrementalSyncChange sync. Remove once they are past retention.
+func legacySyncDataFromBob(ctx workflow.Context) error {
+	var a *Activities
 	var data DataFromBob
-	err := workflow.ExecuteActivity(ctx, PullData, nil).Get(ctx, &data)
+	err := workflow.ExecuteActivity(ctx, a.PullData).Get(ctx, &data)
 	if err != nil {
 		return err
 	}
+	return workflow.ExecuteActivity(ctx, a.StoreData, data).Get(ctx, nil)
+}
 
-	err = workflow.ExecuteActivity(ctx, StoreData, data).Get(ctx, &data)
-	if err != nil {
-		return err
+type DataFromBob struct {
+	Employees []bob.Employee
+}
+
+// PullData reads all employees, page by page. Used only by runs started before incrementalSyncChange.
+func (a *Activities) PullData(ctx context.Context) (DataFromBob, error) {
+	var data DataFromBob
+	req := bob.ListEmployeesRequest{}
+	for {
+		page, err := a.Bob.ListEmployees(ctx, req)
+		if err != nil {
+			return DataFromBob{}, bobError(err)
+		}
+		data.Employees = append(data.Employees, page.Employees...)
+		if page.NextCursor == "" {
+			return data, nil
+		}
+		// Progress is only for the UI. Failed attempt starts from the first page again.
+		activity.RecordHeartbeat(ctx, len(data.Employees))
+		req.Cursor = page.NextCursor
 	}
+}
 
-	return nil
+type SyncEmployeesPageRequest struct {
+	// CompanyID limits sync to employees of a single company. Scheduled sync leaves it empty, to sync everyone.
+	CompanyID    string
+	UpdatedSince time.Time
+	Cursor       string
+	Limit        int
+	// Rebuild doesn't publish NewStarter events, see SyncDataFromBobInput.
+	Rebuild bool
 }
 
-type DataFromBob struct {
-	EmployeeID string
-	Salary     int
+type SyncEmployeesPageResult struct {
+	NextCursor string
+	Summary    employees.Summary
+	// Watermark is the latest update of employees on the page.
+	Watermark time.Time
+}
+
+// SyncEmployeesPage fetches a page of employees from Bob, and stores it right away, so employees never end up in
+// history. Only counts and change events (without PII) leave the activity.
+func (a *Activities) SyncEmployeesPage(ctx context.Context, req SyncEmployeesPageRequest) (SyncEmployeesPageResult, error) {
+	page, err := a.Bob.ListEmployees(ctx, bob.ListEmployeesRequest{
+		CompanyID:    req.CompanyID,
+		UpdatedSince: req.UpdatedSince,
+		Cursor:       req.Cursor,
+		Limit:        req.Limit,
+	})
+	if err != nil {
+		return SyncEmployeesPageResult{}, bobError(err)
+	}
+	summary, err := a.saveEmployees(ctx, page.Employees, req.Rebuild)
+	if err != nil {
+		return SyncEmployeesPageResult{}, err
+	}
+
+	result := SyncEmployeesPageResult{NextCursor: page.NextCursor, Summary: summary}
+	for _, employee := range page.Employees {
+		if employee.UpdatedAt.After(result.Watermark) {
+			result.Watermark = employee.UpdatedAt
+		}
+	}
+	return result, nil
 }
 
-func PullData(_ context.Context) (DataFromBob, error) {
-	return DataFromBob{
-		EmployeeID: "employee-1",
-		Salary:     10_000_00,
-	}, nil
+func (a *Activities) StoreData(ctx context.Context, data DataFromBob) error {
+	// Salaries are PII. They're not going to logs.
+	activity.GetLogger(ctx).Info("Storing data", "Employees", len(data.Employees))
+	_, err := a.saveEmployees(ctx, data.Employees, false)
+	return err
 }
 
-func StoreData(_ context.Context, data DataFromBob) error {
-	fmt.Printf("Storing data: %v\n", data)
-	return nil
+// saveEmployees publishes what changed first. If saving fails, retry finds the same changes and publishes them again.
+// Rebuild leaves out new starters, everyone is new to a store that was just lost.
+func (a *Activities) saveEmployees(ctx context.Context, bobEmployees []bob.Employee, rebuild bool) (employees.Summary, error) {
+	changes, err := a.Employees.Changes(ctx, bobEmployees, a.now())
+	if err != nil {
+		return employees.Summary{}, err
+	}
+	events := changes.Events
+	if rebuild {
+		events = slices.DeleteFunc(slices.Clone(events), func(event employees.Event) bool {
+			return event.Type == employees.EventNewStarter
+		})
+	}
+	if err := a.Events.Publish(ctx, events); err != nil {
+		return employees.Summary{}, err
+	}
+	return changes.Summary, a.Employees.Save(ctx, changes.Versions)
 }
@@ -0,0 +1,131 @@
+package workflows
+
+import (
+	"testing"
+	"time"
+
+	"temporal-poc/bob"
+	"temporal-poc/employees"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+type SyncDataFromBobTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+
+	env *testsuite.TestWorkflowEnvironment
+}
+
+func TestSyncDataFromBob(t *testing.T) {
+	suite.Run(t, new(SyncDataFromBobTestSuite))
+}
+
+func (s *SyncDataFromBobTestSuite) SetupTest() {
+	s.env = s.NewTestWorkflowEnvironment()
+}
+
+func (s *SyncDataFromBobTestSuite) AfterTest(_, _ string) {
+	s.env.AssertExpectations(s.T())
+}
+
+var watermark = time.Date(2024, 4, 5, 12, 0, 0, 0, time.UTC)
+
+func (s *SyncDataFromBobTestSuite) Test_SyncsEveryone_OnFirstRun() {
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{NextCursor: "2", Summary: employees.Summary{Created: 100}, Watermark: watermark}, nil).Once()
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{Cursor: "2", Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{Summary: employees.Summary{Updated: 7, Unchanged: 3}, Watermark: watermark.Add(-time.Hour)}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	var result SyncDataFromBobResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal(SyncDataFromBobResult{Watermark: watermark, Summary: employees.Summary{Created: 100, Updated: 7, Unchanged: 3}}, result)
+}
+
+func (s *SyncDataFromBobTestSuite) Test_SyncsChangesSinceWatermark_OfPreviousRun() {
+	s.env.SetLastCompletionResult(SyncDataFromBobResult{Watermark: watermark, Summary: employees.Summary{Created: 110}})
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{UpdatedSince: watermark.Add(-watermarkOverlap), Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{})
+
+	s.NoError(s.env.GetWorkflowError())
+	var result SyncDataFromBobResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	// Nothing changed, watermark stays where it was.
+	s.Equal(SyncDataFromBobResult{Watermark: watermark}, result)
+}
+
+func (s *SyncDataFromBobTestSuite) Test_SyncsEveryone_WhenForcedToResync() {
+	s.env.SetLastCompletionResult(SyncDataFromBobResult{Watermark: watermark})
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{Summary: employees.Summary{Unchanged: 3}}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{FullResync: true})
+
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *SyncDataFromBobTestSuite) Test_RebuildsEveryPage_WhenWorkerLostEmployees() {
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{Limit: syncPageSize, Rebuild: true}).
+		Return(SyncEmployeesPageResult{NextCursor: "2", Summary: employees.Summary{Created: syncPageSize}}, nil).Once()
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{Cursor: "2", Limit: syncPageSize, Rebuild: true}).
+		Return(SyncEmployeesPageResult{Summary: employees.Summary{Created: 1}}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{FullResync: true, Rebuild: true})
+
+	s.NoError(s.env.GetWorkflowError())
+}
+
+func (s *SyncDataFromBobTestSuite) Test_ContinuesAsNew_AfterManyPages() {
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, mock.Anything).
+		Return(SyncEmployeesPageResult{NextCursor: "next", Summary: employees.Summary{Created: syncPageSize}, Watermark: watermark}, nil)
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+	s.env.AssertNumberOfCalls(s.T(), "SyncEmployeesPage", syncPagesPerRun)
+}
+
+func (s *SyncDataFromBobTestSuite) Test_ContinuesFromCursor_AfterContinueAsNew() {
+	input := SyncDataFromBobInput{UpdatedSince: watermark, Cursor: "next", Watermark: watermark, Summary: employees.Summary{Created: 5000}}
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, SyncEmployeesPageRequest{UpdatedSince: watermark, Cursor: "next", Limit: syncPageSize}).
+		Return(SyncEmployeesPageResult{Summary: employees.Summary{Created: 1}, Watermark: watermark.Add(time.Minute)}, nil).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, input)
+
+	var result SyncDataFromBobResult
+	s.NoError(s.env.GetWorkflowResult(&result))
+	s.Equal(SyncDataFromBobResult{Watermark: watermark.Add(time.Minute), Summary: employees.Summary{Created: 5001}}, result)
+}
+
+func (s *SyncDataFromBobTestSuite) Test_Fails_WhenBobRejectsRequest() {
+	s.env.OnActivity(a.SyncEmployeesPage, mock.Anything, mock.Anything).
+		Return(SyncEmployeesPageResult{}, temporal.NewNonRetryableApplicationError("unauthorized", ErrorTypeBobRejected, nil)).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "unauthorized")
+}
+
+func (s *SyncDataFromBobTestSuite) Test_PullsAndStoresData_WhenStartedBeforeIncrementalSync() {
+	s.env.OnGetVersion(incrementalSyncChange, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	data := DataFromBob{Employees: []bob.Employee{{ID: "employee-1", Salary: 100}}}
+	s.env.OnActivity(a.PullData, mock.Anything).Return(data, nil).Once()
+	s.env.OnActivity(a.StoreData, mock.Anything, data).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(SyncDataFromBob, SyncDataFromBobInput{})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+}